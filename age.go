@@ -0,0 +1,98 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ageRule shades the rows by the age of the date in a column, as the due
+// dates of the receivables or the tasks: yellow older than warn days, red
+// older than late days.
+type ageRule struct {
+	column     string
+	warn, late int
+	// today is the date the ages are counted to.
+	today time.Time
+	// layout is the layout the dates are printed with (DateFormat), if set.
+	layout string
+}
+
+// The shades of the aged rows.
+var (
+	ageWarnColor = RGB{255, 235, 156}
+	ageLateColor = RGB{255, 199, 206}
+)
+
+// parseAgeRule parses the "col=warn:late[:yyyy-mm-dd]" spec; the date is
+// the day the ages are counted to, today by default. layout is the layout
+// of the dates besides the usual ones, if not empty.
+func parseAgeRule(spec, layout string) (*ageRule, error) {
+	name, rule, _ := strings.Cut(strings.TrimSpace(spec), "=")
+	args := strings.Split(rule, ":")
+	if name == "" || len(args) < 2 || len(args) > 3 {
+		return nil, errors.Errorf("%s: should be col=warn:late[:yyyy-mm-dd]", spec)
+	}
+	ar := ageRule{column: name, layout: layout}
+	var err error
+	if ar.warn, err = strconv.Atoi(args[0]); err != nil {
+		return nil, errors.Wrap(err, spec)
+	}
+	if ar.late, err = strconv.Atoi(args[1]); err != nil {
+		return nil, errors.Wrap(err, spec)
+	}
+	if ar.warn < 0 || ar.warn > ar.late {
+		return nil, errors.Errorf("%s: warn should be between 0 and late days", spec)
+	}
+	now := time.Now()
+	ar.today = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if len(args) == 3 {
+		if ar.today, err = time.Parse("2006-01-02", args[2]); err != nil {
+			return nil, errors.Wrap(err, spec)
+		}
+	}
+	return &ar, nil
+}
+
+// parse returns the date of v.
+func (ar *ageRule) parse(v string) (time.Time, bool) {
+	v = strings.TrimSpace(v)
+	if ar.layout != "" {
+		if t, err := time.Parse(ar.layout, v); err == nil {
+			return t, true
+		}
+	}
+	if !dateLike.MatchString(v) {
+		return time.Time{}, false
+	}
+	date, _, _ := strings.Cut(strings.Replace(v, "T", " ", 1), " ")
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, date); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// color returns the shade of the row with the date v; ok is false for the
+// recent, the future and the missing dates.
+func (ar *ageRule) color(v string) (c RGB, ok bool) {
+	t, ok := ar.parse(v)
+	if !ok {
+		return c, false
+	}
+	t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch days := int(ar.today.Sub(t).Hours() / 24); {
+	case days > ar.late:
+		return ageLateColor, true
+	case days > ar.warn:
+		return ageWarnColor, true
+	}
+	return c, false
+}
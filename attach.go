@@ -0,0 +1,59 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"io"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/pkg/errors"
+)
+
+// maxAttachmentSize limits the size of a downloaded attachment.
+const maxAttachmentSize = 64 << 20
+
+var attachClient = &http.Client{Timeout: time.Minute}
+
+// loadAttachment reads the file or downloads the URL given in ref.
+// Relative file paths are relative to dir, the files are read by readFile.
+func loadAttachment(readFile func(string) ([]byte, error), dir, ref string) (*gofpdf.Attachment, error) {
+	if strings.HasPrefix(ref, "http://") || strings.HasPrefix(ref, "https://") {
+		resp, err := attachClient.Get(ref)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, errors.Errorf("GET %q: %s", ref, resp.Status)
+		}
+		b, err := io.ReadAll(io.LimitReader(resp.Body, maxAttachmentSize+1))
+		if err != nil {
+			return nil, errors.Wrap(err, ref)
+		}
+		if len(b) > maxAttachmentSize {
+			return nil, errors.Wrapf(ErrResourceLimit, "%q is bigger than %d bytes", ref, maxAttachmentSize)
+		}
+		name := path.Base(resp.Request.URL.Path)
+		if name == "" || name == "/" || name == "." {
+			name = "attachment"
+		}
+		return &gofpdf.Attachment{Content: b, Filename: name, Description: ref}, nil
+	}
+
+	fn := strings.TrimPrefix(ref, "file://")
+	if !filepath.IsAbs(fn) {
+		fn = filepath.Join(dir, fn)
+	}
+	b, err := readFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	return &gofpdf.Attachment{Content: b, Filename: filepath.Base(fn), Description: ref}, nil
+}
@@ -0,0 +1,141 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/pkg/errors"
+)
+
+// pageImage is an image printed on every page: the background (a rendered
+// letterhead), or a strip across the top or the bottom of the page.
+type pageImage struct {
+	name string
+	b    []byte
+	// tp is the gofpdf image type.
+	tp string
+	// w and h are the size in pixels.
+	w, h int
+}
+
+// loadPageImage reads the PNG, JPEG or GIF image fn, converted to gray if gray.
+func loadPageImage(readFile func(string) ([]byte, error), fn string, gray bool) (*pageImage, error) {
+	b, err := readFile(fn)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading %q", fn)
+	}
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(b))
+	if err != nil {
+		return nil, errors.Wrapf(err, "%q is not a PNG, JPEG or GIF image", fn)
+	}
+	img := &pageImage{name: "page:" + fn, b: b, tp: strings.ToUpper(format), w: cfg.Width, h: cfg.Height}
+	if img.tp == "JPEG" {
+		img.tp = "JPG"
+	}
+	if img.w == 0 || img.h == 0 {
+		return nil, errors.Errorf("%q is empty", fn)
+	}
+	if !gray {
+		return img, nil
+	}
+	// the transparent parts are white on paper
+	src, _, err := image.Decode(bytes.NewReader(b))
+	if err != nil {
+		return nil, errors.Wrapf(err, "decoding %q", fn)
+	}
+	dst := image.NewGray(src.Bounds())
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), src, src.Bounds().Min, draw.Over)
+	var buf bytes.Buffer
+	if err = png.Encode(&buf, dst); err != nil {
+		return nil, errors.Wrapf(err, "converting %q to gray", fn)
+	}
+	img.b, img.tp = buf.Bytes(), "PNG"
+	return img, nil
+}
+
+// heightAt returns the height of the image stretched to width.
+func (img *pageImage) heightAt(width float64) float64 {
+	return width * float64(img.h) / float64(img.w)
+}
+
+// draw prints the image in the rectangle, registering it in the document
+// at the first use (each flushed document has its own).
+func (img *pageImage) draw(pdf *gofpdf.Fpdf, x, y, w, h float64) {
+	opt := gofpdf.ImageOptions{ImageType: img.tp}
+	if pdf.GetImageInfo(img.name) == nil {
+		pdf.RegisterImageOptionsReader(img.name, opt, bytes.NewReader(img.b))
+	}
+	pdf.ImageOptions(img.name, x, y, w, h, false, opt, 0, "")
+}
+
+// setStationery sets the background image, stretched to the page, and the
+// header and footer strips, stretched to the page width; the content is
+// printed between the strips.
+func (pr *pdfRenderer) setStationery(readFile func(string) ([]byte, error), background, header, footer string) error {
+	for _, x := range []struct {
+		fn  string
+		img **pageImage
+	}{{background, &pr.background}, {header, &pr.headerImage}, {footer, &pr.footerImage}} {
+		if x.fn == "" {
+			continue
+		}
+		img, err := loadPageImage(readFile, x.fn, pr.grayscale)
+		if err != nil {
+			return err
+		}
+		*x.img = img
+	}
+	// the strips are the highest on the landscape pages
+	wd, ht := pr.defPageSize.Wd, pr.defPageSize.Ht
+	if wd < ht {
+		wd, ht = ht, wd
+	}
+	var strips float64
+	for _, img := range []*pageImage{pr.headerImage, pr.footerImage} {
+		if img != nil {
+			strips += img.heightAt(wd)
+		}
+	}
+	if strips > ht/2 {
+		return errors.Errorf("the header and footer images take %.0f mm of the %.0f mm high landscape page", strips, ht)
+	}
+	if pr.background != nil || strips != 0 {
+		pr.pageHooks = append(pr.pageHooks, pr.drawStationery)
+	}
+	return nil
+}
+
+// drawStationery prints the background and the strips of the page, and
+// moves the top margin and the page break below and above the strips.
+func (pr *pdfRenderer) drawStationery() {
+	pdf := pr.pdf
+	w, h := pdf.GetPageSize()
+	top, bottom := pr.style.Margin, pr.bottomMargin()
+	if img := pr.background; img != nil {
+		img.draw(pdf, 0, 0, w, h)
+	}
+	if img := pr.headerImage; img != nil {
+		sh := img.heightAt(w)
+		img.draw(pdf, 0, 0, w, sh)
+		top += sh
+	}
+	if img := pr.footerImage; img != nil {
+		sh := img.heightAt(w)
+		img.draw(pdf, 0, h-sh, w, sh)
+		bottom += sh
+	}
+	pdf.SetTopMargin(top)
+	pdf.SetAutoPageBreak(true, bottom)
+}
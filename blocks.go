@@ -0,0 +1,153 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import "strings"
+
+// blockKind is the kind of a text block.
+type blockKind uint8
+
+const (
+	blockParagraph blockKind = iota
+	blockHeading1
+	blockHeading2
+	blockSpacer
+	blockRule
+)
+
+// block is a text primitive of a Document: a heading, a paragraph, a
+// vertical space (of height mm) or a horizontal rule.
+type block struct {
+	kind   blockKind
+	text   string
+	height float64
+}
+
+// blockRenderer is implemented by the renderers which can print the text
+// blocks.
+type blockRenderer interface {
+	Blocks(blocks []block) error
+}
+
+// renderBlocks renders the blocks, their text as paragraphs if rend cannot
+// print them.
+func renderBlocks(rend tableRenderer, blocks []block) error {
+	if len(blocks) == 0 {
+		return nil
+	}
+	if br, ok := rend.(blockRenderer); ok {
+		return br.Blocks(blocks)
+	}
+	var text []string
+	for _, b := range blocks {
+		if b.text != "" {
+			text = append(text, b.text)
+		}
+	}
+	return renderText(rend, text)
+}
+
+// Blocks prints the blocks after the current table; the next table may
+// follow them on the same page.
+func (pr *pdfRenderer) Blocks(blocks []block) error {
+	if pr.table != nil {
+		pr.finishTable()
+		pr.table = nil
+	}
+	pdf := pr.pdf
+	if pdf.PageNo() == 0 {
+		pdf.AddPageFormat("P", pr.defPageSize)
+	}
+	left, _, right, _ := pdf.GetMargins()
+	pageWidth, _ := pdf.GetPageSize()
+	lineHt := pr.style.RowHeight * 0.8
+	align := "L"
+	if pr.rtl {
+		align = "R"
+	}
+	pdf.SetTextColor(0, 0, 0)
+	for _, b := range blocks {
+		switch b.kind {
+		case blockHeading1, blockHeading2:
+			size := pr.style.HeaderFontSize * 1.6
+			if b.kind == blockHeading2 {
+				size = pr.style.HeaderFontSize * 1.25
+			}
+			title := pr.translator(visualOrder(b.text))
+			if b.kind == blockHeading1 && pr.flushPages == 0 {
+				pdf.Bookmark(title, 0, -1)
+			}
+			pdf.SetFont(pr.font, "B", size)
+			pdf.MultiCell(0, pdf.PointConvert(size)*1.4, title, "", align, false)
+			pdf.Ln(lineHt / 2)
+		case blockParagraph:
+			pdf.SetFont(pr.font, "", pr.style.BodyFontSize+1)
+			pdf.MultiCell(0, lineHt, pr.translator(b.text), "", align, false)
+			pdf.Ln(lineHt / 2)
+		case blockSpacer:
+			pdf.Ln(b.height)
+		case blockRule:
+			y := pdf.GetY() + lineHt/2
+			cr, cg, cb := pdf.GetDrawColor()
+			pdf.SetDrawColor(0, 0, 0)
+			pdf.Line(left, y, pageWidth-right, y)
+			pdf.SetDrawColor(cr, cg, cb)
+			pdf.Ln(lineHt)
+		}
+	}
+	pr.blockPage = true
+	return pdf.Error()
+}
+
+// roomFor reports whether the table of the orientation and the style can
+// start on the current page, below the blocks.
+func (pr *pdfRenderer) roomFor(orientation string, style tableStyle) bool {
+	w, h := pr.pdf.GetPageSize()
+	if (orientation == "L") != (w > h) {
+		return false
+	}
+	return pr.pdf.GetY()+2*style.HeaderHeight+3*style.RowHeight < h-pr.bottomMargin()
+}
+
+// Blocks writes the blocks: the headings underlined (# and ## in Markdown),
+// the paragraphs wrapped at textWidth if not Markdown.
+func (tr *textRenderer) Blocks(blocks []block) error {
+	if tr.parts != 0 {
+		tr.w.WriteByte('\n')
+	}
+	tr.parts++
+	for _, b := range blocks {
+		switch b.kind {
+		case blockHeading1, blockHeading2:
+			underline, mark := "=", "# "
+			if b.kind == blockHeading2 {
+				underline, mark = "-", "## "
+			}
+			if tr.markdown {
+				tr.w.WriteString(mark + b.text + "\n\n")
+			} else {
+				tr.w.WriteString(b.text + "\n" + strings.Repeat(underline, displayWidth(b.text)) + "\n\n")
+			}
+		case blockParagraph:
+			if tr.markdown {
+				tr.w.WriteString(b.text + "\n\n")
+				continue
+			}
+			for _, line := range wrapText(b.text, textWidth) {
+				tr.w.WriteString(line + "\n")
+			}
+			tr.w.WriteByte('\n')
+		case blockSpacer:
+			tr.w.WriteByte('\n')
+		case blockRule:
+			if tr.markdown {
+				tr.w.WriteString("---\n\n")
+			} else {
+				tr.w.WriteString(strings.Repeat("-", textWidth) + "\n\n")
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import "github.com/pkg/errors"
+
+// withBorders returns the style with the borders of the -borders mode:
+// grid (around every cell), columns (between the columns, the default),
+// rows (rules under the rows), frame (around the table only) or none.
+func (st tableStyle) withBorders(mode string) (tableStyle, error) {
+	st.Borders, st.Rules, st.Grid, st.Frame, st.Borderless = false, false, false, false, false
+	switch mode {
+	case "grid":
+		st.Borders, st.Grid = true, true
+	case "columns":
+		st.Borders = true
+	case "rows":
+		st.Rules = true
+	case "frame":
+		st.Frame = true
+	case "none":
+		st.Borderless = true
+	default:
+		return st, errors.Errorf("unknown border style %q (grid, columns, rows, frame or none)", mode)
+	}
+	return st, nil
+}
+
+// width returns the width of the table.
+func (t *pdfTable) width() float64 {
+	var w float64
+	for _, cw := range t.colwidths {
+		w += cw
+	}
+	return w
+}
+
+// drawFrame draws the sides of the frame of the table from y down h, and
+// its top, too, if top is set.
+func (t *pdfTable) drawFrame(y, h float64, top bool) {
+	if !t.style.Frame {
+		return
+	}
+	pdf := t.pdf
+	x, _, _, _ := pdf.GetMargins()
+	w := t.width()
+	pdf.Line(x, y, x, y+h)
+	pdf.Line(x+w, y, x+w, y+h)
+	if top {
+		pdf.Line(x, y, x+w, y)
+	}
+}
+
+// closeRule draws the bottom rule of the table on the page, if the side
+// borders of its rows are not closed by themselves.
+func (t *pdfTable) closeRule() {
+	if !(t.style.Borders && !t.style.Grid || t.style.Frame) {
+		return
+	}
+	pdf := t.pdf
+	x, _, _, _ := pdf.GetMargins()
+	y := pdf.GetY()
+	pdf.Line(x, y, x+t.width(), y)
+}
@@ -0,0 +1,45 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import "container/list"
+
+// maxCachedLen is the length limit of the cached strings: long values are
+// rarely repeated, and would use up the cache.
+const maxCachedLen = 64
+
+// cachedTranslator returns a translator caching the results of tr for the
+// last size distinct (short) inputs, as headers and enum-like columns
+// repeat the same values over and over.
+func cachedTranslator(tr func(string) string, size int) func(string) string {
+	if size <= 0 {
+		return tr
+	}
+	type entry struct{ key, value string }
+	lru := list.New()
+	m := make(map[string]*list.Element, size)
+	return func(s string) string {
+		if len(s) > maxCachedLen {
+			return tr(s)
+		}
+		if e, ok := m[s]; ok {
+			lru.MoveToFront(e)
+			return e.Value.(*entry).value
+		}
+		v := tr(s)
+		if lru.Len() < size {
+			m[s] = lru.PushFront(&entry{key: s, value: v})
+			return v
+		}
+		// reuse the least recently used element
+		e := lru.Back()
+		ent := e.Value.(*entry)
+		delete(m, ent.key)
+		ent.key, ent.value = s, v
+		m[s] = e
+		lru.MoveToFront(e)
+		return v
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"fmt"
+	"strings"
+)
+
+// outputFormats are the formats of the capability matrix.
+var outputFormats = []string{"pdf", "txt", "md", "xlsx"}
+
+// capability is a feature (named by its flag) and the formats printing it;
+// the features of the other formats are printed by all of them.
+type capability struct {
+	feature string
+	formats []string
+	used    func(Options) bool
+}
+
+// capabilities is the capability matrix of the features not printed by all
+// the formats.
+var capabilities = []capability{
+	{"preview", []string{"pdf"}, func(o Options) bool { return o.Preview != "" }},
+	{"post", []string{"pdf"}, func(o Options) bool { return o.Post != "" }},
+	{"stationery", []string{"pdf"}, func(o Options) bool { return o.Stationery != "" }},
+	{"pdf-version", []string{"pdf"}, func(o Options) bool { return o.PDFVersion != "" }},
+	{"header-height", []string{"pdf"}, func(o Options) bool { return o.HeaderHeight != 0 }},
+	{"row-height", []string{"pdf"}, func(o Options) bool { return o.RowHeight != 0 }},
+	{"cell-padding", []string{"pdf"}, func(o Options) bool { return o.CellPadding != 0 }},
+	{"min-col-width", []string{"pdf"}, func(o Options) bool { return o.MinColWidth != 0 }},
+	{"verify", []string{"pdf"}, func(o Options) bool { return o.Verify }},
+	{"borders", []string{"pdf"}, func(o Options) bool { return o.Borders != "" }},
+	{"grayscale", []string{"pdf"}, func(o Options) bool { return o.Grayscale }},
+	{"receipt", []string{"pdf"}, func(o Options) bool { return o.Receipt != "" }},
+	{"summary", []string{"pdf"}, func(o Options) bool { return o.Summary }},
+	{"quality", []string{"pdf"}, func(o Options) bool { return o.Quality }},
+	{"index-column", []string{"pdf"}, func(o Options) bool { return o.IndexColumn != "" }},
+	{"toc-json", []string{"pdf"}, func(o Options) bool { return o.TOCJSON != "" }},
+	{"total-columns", []string{"pdf"}, func(o Options) bool { return o.TotalColumns != "" }},
+	{"form-columns", []string{"pdf"}, func(o Options) bool { return o.FormColumns != "" }},
+	{"rotate-headers", []string{"pdf"}, func(o Options) bool { return o.RotateHeaders != 0 }},
+	{"vertical-columns", []string{"pdf"}, func(o Options) bool { return o.VerticalColumns != "" }},
+	{"column-groups", []string{"pdf"}, func(o Options) bool { return o.ColumnGroups != "" }},
+	{"heatmap", []string{"pdf"}, func(o Options) bool { return o.Heatmap != "" }},
+	{"outliers", []string{"pdf"}, func(o Options) bool { return o.Outliers != "" }},
+	{"icons", []string{"pdf"}, func(o Options) bool { return o.Icons != "" }},
+	{"age-colors", []string{"pdf"}, func(o Options) bool { return o.AgeColors != "" }},
+	{"ruler", []string{"pdf"}, func(o Options) bool { return o.Ruler > 0 }},
+	{"rtl", []string{"pdf"}, func(o Options) bool { return o.RTL }},
+	{"split-wide", []string{"pdf"}, func(o Options) bool { return o.SplitWide }},
+	{"repeat-right", []string{"pdf"}, func(o Options) bool { return o.RepeatRight != "" }},
+	{"attach-column", []string{"pdf"}, func(o Options) bool { return o.AttachColumn != "" }},
+	{"qr", []string{"pdf"}, func(o Options) bool { return o.QR != "" }},
+	{"page-hmac", []string{"pdf"}, func(o Options) bool { return o.PageHMAC != "" }},
+	{"footer", []string{"pdf"}, func(o Options) bool { return o.Footer != "" }},
+	{"header-tmpl", []string{"pdf"}, func(o Options) bool { return o.HeaderTemplate != "" }},
+	{"footer-tmpl", []string{"pdf"}, func(o Options) bool { return o.FooterTemplate != "" }},
+	{"provenance", []string{"pdf"}, func(o Options) bool { return o.Provenance }},
+	{"background", []string{"pdf"}, func(o Options) bool { return o.Background != "" }},
+	{"header-image", []string{"pdf"}, func(o Options) bool { return o.HeaderImage != "" }},
+	{"footer-image", []string{"pdf"}, func(o Options) bool { return o.FooterImage != "" }},
+	{"disclaimer-every-page", []string{"pdf"}, func(o Options) bool { return o.DisclaimerEveryPage }},
+	{"flush-pages", []string{"pdf"}, func(o Options) bool { return o.FlushPages > 0 }},
+	{"pin-layout", []string{"pdf"}, func(o Options) bool { return o.PinLayout != "" }},
+	{"font-file", []string{"pdf"}, func(o Options) bool { return o.FontFile != "" }},
+	{"fallback-fonts", []string{"pdf"}, func(o Options) bool { return o.FallbackFonts != "" }},
+	{"utf8-font", []string{"pdf"}, func(o Options) bool { return o.UTF8Font }},
+	{"ligatures", []string{"pdf"}, func(o Options) bool { return o.Ligatures }},
+	{"script-markup", []string{"pdf"}, func(o Options) bool { return o.ScriptMarkup }},
+	{"embed-all-glyphs", []string{"pdf"}, func(o Options) bool { return o.EmbedAllGlyphs }},
+	{"page-size", []string{"pdf"}, func(o Options) bool { return o.PageSize != "" && !strings.EqualFold(o.PageSize, "A4") }},
+	{"orientation", []string{"pdf"}, func(o Options) bool { return o.Orientation != "" }},
+	{"compact", []string{"pdf"}, func(o Options) bool { return o.Compact }},
+	{"ink-saver", []string{"pdf"}, func(o Options) bool { return o.InkSaver }},
+	{"debug-grid", []string{"pdf"}, func(o Options) bool { return o.DebugGrid }},
+	{"truncate", []string{"pdf"}, func(o Options) bool { return o.Truncate != "" && o.Truncate != "none" }},
+	{"title", []string{"pdf"}, func(o Options) bool { return o.Title != "" }},
+	{"header-bg", []string{"pdf"}, func(o Options) bool { return o.Colors != nil && o.Colors.HeaderFill != DefaultColors().HeaderFill }},
+	{"header-fg", []string{"pdf"}, func(o Options) bool { return o.Colors != nil && o.Colors.HeaderText != DefaultColors().HeaderText }},
+	{"stripe-color", []string{"pdf"}, func(o Options) bool { return o.Colors != nil && o.Colors.Stripe != DefaultColors().Stripe }},
+	{"border-color", []string{"pdf"}, func(o Options) bool { return o.Colors != nil && o.Colors.Border != DefaultColors().Border }},
+}
+
+// conflict is a combination of the features giving a subtly wrong
+// document.
+type conflict struct {
+	features string
+	reason   string
+	found    func(o Options, encrypted bool) bool
+}
+
+// conflicts are the features not working together.
+var conflicts = []conflict{
+	{"post encrypt, pdf-version", "the AES-256 encryption needs PDF 1.7",
+		func(o Options, encrypted bool) bool { return encrypted && o.PDFVersion != "" && o.PDFVersion < "1.7" }},
+	{"pdf-version, verify", "pdfcpu cannot read the PDF 2.0 documents back",
+		func(o Options, encrypted bool) bool { return o.PDFVersion == "2.0" && o.Verify }},
+	{"post encrypt, grayscale", "the colors of the encrypted document cannot be checked",
+		func(o Options, encrypted bool) bool { return encrypted && o.Grayscale }},
+	{"post encrypt, verify", "the encrypted document cannot be verified without the password",
+		func(o Options, encrypted bool) bool { return encrypted && o.Verify }},
+	{"post encrypt, preview", "the encrypted document cannot be rendered without the password",
+		func(o Options, encrypted bool) bool { return encrypted && o.Preview != "" }},
+}
+
+// CapabilityError lists the features requested, but not supported by the
+// format, or not working together.
+type CapabilityError struct {
+	Format string
+	// Unsupported are the features not printed by the Format.
+	Unsupported []string
+	// Conflicts are the "features: reason" of the features not working
+	// together.
+	Conflicts []string
+}
+
+func (e *CapabilityError) Error() string {
+	var buf strings.Builder
+	if len(e.Unsupported) != 0 {
+		fmt.Fprintf(&buf, "the %s format does not support %s:\n", e.Format, strings.Join(e.Unsupported, ", "))
+		width := len("feature")
+		for _, f := range e.Unsupported {
+			width = maxInt(width, len(f))
+		}
+		fmt.Fprintf(&buf, "\t%-*s", width, "feature")
+		for _, format := range outputFormats {
+			fmt.Fprintf(&buf, "  %s", format)
+		}
+		buf.WriteByte('\n')
+		for _, f := range e.Unsupported {
+			row := fmt.Sprintf("\t%-*s", width, f)
+			for _, format := range outputFormats {
+				mark := "-"
+				if supports(f, format) {
+					mark = "+"
+				}
+				row += fmt.Sprintf("  %-*s", len(format), mark)
+			}
+			buf.WriteString(strings.TrimRight(row, " ") + "\n")
+		}
+	}
+	for _, c := range e.Conflicts {
+		fmt.Fprintf(&buf, "%s\n", c)
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// supports reports whether the format prints the feature.
+func supports(feature, format string) bool {
+	for _, c := range capabilities {
+		if c.feature == feature {
+			for _, f := range c.formats {
+				if f == format {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	return true
+}
+
+// checkCapabilities returns a *CapabilityError if a feature requested is
+// not printed by the format, or the features do not work together.
+func (opts Options) checkCapabilities() error {
+	e := CapabilityError{Format: opts.Format}
+	for _, c := range capabilities {
+		if c.used(opts) && !supports(c.feature, opts.Format) {
+			e.Unsupported = append(e.Unsupported, c.feature)
+		}
+	}
+	var encrypted bool
+	if steps, err := parsePostSteps(opts.Post); err == nil {
+		for _, step := range steps {
+			encrypted = encrypted || step.name == "encrypt"
+		}
+	}
+	for _, c := range conflicts {
+		if c.found(opts, encrypted) {
+			e.Conflicts = append(e.Conflicts, c.features+": "+c.reason)
+		}
+	}
+	if e.Unsupported == nil && e.Conflicts == nil {
+		return nil
+	}
+	return &e
+}
@@ -0,0 +1,382 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// pdfConcat writes the pages of the documents added to it as one document,
+// as they come, so only the last document has to be kept in memory.
+//
+// The documents are gofpdf outputs with the same default page size. The
+// first one gives the catalog and the document info; the bookmarks of all
+// are joined, the rest of the other catalogs (named files) is dropped.
+type pdfConcat struct {
+	w io.Writer
+	// pos is the number of bytes written.
+	pos int64
+	// offsets are the offsets of the objects, from object number 2;
+	// the 1st is the pages root, written by close.
+	offsets []int64
+	kids    []int
+	// mediaBox is the default page size of the pages root.
+	mediaBox []byte
+	// catalog and info are the (renumbered) dictionaries of the first document.
+	catalog, info []byte
+	// version is the PDF version of the header, maxVersion the highest
+	// version of the documents.
+	version, maxVersion string
+	// outlines is the bookmarks root of all the documents (0 if none yet),
+	// firstOutline its first top level bookmark; the last one, lastOutline
+	// is held back to link it to the first one of the next document.
+	outlines, firstOutline, lastOutline int
+	heldOutline                         []byte
+}
+
+var (
+	rPDFRef      = regexp.MustCompile(`(\d+) 0 R\b`)
+	rPDFObj      = regexp.MustCompile(`^(\d+) 0 obj\n`)
+	rPDFVersion  = regexp.MustCompile(`^%PDF-(\d\.\d)`)
+	rTrailerRoot = regexp.MustCompile(`/Root (\d+) 0 R`)
+	rTrailerInfo = regexp.MustCompile(`/Info (\d+) 0 R`)
+	rPagesRef    = regexp.MustCompile(`/Pages (\d+) 0 R`)
+	rKids        = regexp.MustCompile(`/Kids \[([^\]]*)\]`)
+	rMediaBox    = regexp.MustCompile(`/MediaBox \[[^\]]*\]`)
+	rOutlines    = regexp.MustCompile(`/Outlines (\d+) 0 R`)
+	rFirst       = regexp.MustCompile(`/First (\d+) 0 R`)
+	rLast        = regexp.MustCompile(`/Last (\d+) 0 R`)
+)
+
+func newPDFConcat(w io.Writer) *pdfConcat {
+	return &pdfConcat{w: w}
+}
+
+func (pc *pdfConcat) write(p []byte) error {
+	n, err := pc.w.Write(p)
+	pc.pos += int64(n)
+	return err
+}
+
+// writeObj writes the object num with the dictionary and the stream.
+func (pc *pdfConcat) writeObj(num int, dict, stream []byte) error {
+	pc.offsets[num-2] = pc.pos
+	if err := pc.write([]byte(strconv.Itoa(num) + " 0 obj\n")); err != nil {
+		return err
+	}
+	if err := pc.write(dict); err != nil {
+		return err
+	}
+	return pc.write(stream)
+}
+
+// writeHeldOutline writes the held back last bookmark, linked to next
+// (if not 0).
+func (pc *pdfConcat) writeHeldOutline(next int) error {
+	if pc.heldOutline == nil {
+		return nil
+	}
+	dict := pc.heldOutline
+	if next != 0 {
+		dict = insertEntry(dict, fmt.Sprintf("/Next %d 0 R", next))
+	}
+	pc.heldOutline = nil
+	return pc.writeObj(pc.lastOutline, dict, nil)
+}
+
+// insertEntry inserts the entry at the start of the dictionary dict.
+func insertEntry(dict []byte, entry string) []byte {
+	i := bytes.Index(dict, []byte("<<")) + 2
+	return append(append(append([]byte(nil), dict[:i]...), entry...), dict[i:]...)
+}
+
+// add writes the objects of the document doc, except its catalog, info and
+// pages root, renumbered after the objects written before.
+func (pc *pdfConcat) add(doc []byte) error {
+	m := rPDFVersion.FindSubmatch(doc)
+	if m == nil {
+		return errors.New("not a PDF")
+	}
+	version := string(m[1])
+	if pc.version == "" {
+		pc.version, pc.maxVersion = version, version
+		if err := pc.write([]byte("%PDF-" + version + "\n")); err != nil {
+			return err
+		}
+	} else if version > pc.maxVersion {
+		pc.maxVersion = version
+	}
+
+	xref, offsets, trailer, err := parseXref(doc)
+	if err != nil {
+		return err
+	}
+	var root, info, pages int
+	if m := rTrailerRoot.FindSubmatch(trailer); m != nil {
+		root, _ = strconv.Atoi(string(m[1]))
+	}
+	if m := rTrailerInfo.FindSubmatch(trailer); m != nil {
+		info, _ = strconv.Atoi(string(m[1]))
+	}
+	objects := make(map[int][]byte, len(offsets))
+	order := make([]int, 0, len(offsets))
+	for num := range offsets {
+		order = append(order, num)
+	}
+	sort.Slice(order, func(i, j int) bool { return offsets[order[i]] < offsets[order[j]] })
+	for i, num := range order {
+		end := xref
+		if i+1 < len(order) {
+			end = offsets[order[i+1]]
+		}
+		if offsets[num] > end || end > int64(len(doc)) {
+			return errors.Errorf("object %d is out of the document", num)
+		}
+		objects[num] = doc[offsets[num]:end]
+	}
+	if m := rPagesRef.FindSubmatch(objects[root]); m != nil {
+		pages, _ = strconv.Atoi(string(m[1]))
+	}
+	if root == 0 || info == 0 || pages == 0 {
+		return errors.New("no catalog, info or pages root in the trailer")
+	}
+	// the bookmarks are joined under one root, allocated by the first
+	// document having them
+	var outlines, first, last int
+	if m := rOutlines.FindSubmatch(objects[root]); m != nil {
+		outlines, _ = strconv.Atoi(string(m[1]))
+		if m := rFirst.FindSubmatch(objects[outlines]); m != nil {
+			first, _ = strconv.Atoi(string(m[1]))
+		}
+		if m := rLast.FindSubmatch(objects[outlines]); m != nil {
+			last, _ = strconv.Atoi(string(m[1]))
+		}
+		if first == 0 || last == 0 {
+			outlines = 0
+		} else if pc.outlines == 0 {
+			pc.outlines = len(pc.offsets) + 2
+			pc.offsets = append(pc.offsets, 0)
+		}
+	}
+
+	// the renumbering: the pages root is the 1st, the others follow the
+	// objects already written, in the order of the document
+	renum := map[int]int{pages: 1}
+	if outlines != 0 {
+		renum[outlines] = pc.outlines
+	}
+	next := len(pc.offsets) + 2
+	for _, num := range order {
+		if num != root && num != info && num != pages && num != outlines {
+			renum[num] = next
+			next++
+		}
+	}
+	pc.offsets = append(pc.offsets, make([]int64, next-len(pc.offsets)-2)...)
+	if outlines != 0 {
+		if pc.firstOutline == 0 {
+			pc.firstOutline = renum[first]
+		}
+		if err := pc.writeHeldOutline(renum[first]); err != nil {
+			return err
+		}
+	}
+	rewrite := func(b []byte) []byte {
+		return rPDFRef.ReplaceAllFunc(b, func(ref []byte) []byte {
+			num, _ := strconv.Atoi(string(ref[:bytes.IndexByte(ref, ' ')]))
+			if n, ok := renum[num]; ok {
+				num = n
+			}
+			return []byte(strconv.Itoa(num) + " 0 R")
+		})
+	}
+
+	for _, num := range order {
+		obj := objects[num]
+		loc := rPDFObj.FindIndex(obj)
+		if loc == nil {
+			return errors.Errorf("object %d: no object header", num)
+		}
+		// the stream data is kept as is, the references are in the dictionary
+		dict, stream := obj[loc[1]:], []byte(nil)
+		if i := bytes.Index(dict, []byte("\nstream\n")); i >= 0 {
+			dict, stream = dict[:i], dict[i:]
+		}
+		switch num {
+		case pages:
+			if pc.mediaBox == nil {
+				pc.mediaBox = rMediaBox.Find(dict)
+			}
+			if m := rKids.FindSubmatch(rewrite(dict)); m != nil {
+				for _, ref := range rPDFRef.FindAllSubmatch(m[1], -1) {
+					kid, _ := strconv.Atoi(string(ref[1]))
+					pc.kids = append(pc.kids, kid)
+				}
+			}
+			continue
+		case root, info:
+			if pc.catalog == nil {
+				dict = bytes.TrimSuffix(bytes.TrimRight(dict, "\n"), []byte("endobj"))
+				if num == root {
+					pc.catalog = bytes.TrimRight(rewrite(dict), "\n")
+				} else {
+					pc.info = bytes.TrimRight(dict, "\n")
+				}
+			}
+			continue
+		case outlines:
+			continue
+		}
+		dict = rewrite(dict)
+		if outlines != 0 && num == first && pc.lastOutline != 0 {
+			dict = insertEntry(dict, fmt.Sprintf("/Prev %d 0 R", pc.lastOutline))
+		}
+		if outlines != 0 && num == last {
+			pc.lastOutline, pc.heldOutline = renum[num], dict
+			continue
+		}
+		if err := pc.writeObj(renum[num], dict, stream); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// close writes the pages root, the catalog and the info of the first
+// document, and the cross-reference table.
+func (pc *pdfConcat) close() error {
+	if pc.version == "" {
+		return errors.New("no document")
+	}
+	if pc.outlines != 0 {
+		if err := pc.writeHeldOutline(0); err != nil {
+			return err
+		}
+		if err := pc.writeObj(pc.outlines, []byte(fmt.Sprintf("<</Type /Outlines /First %d 0 R /Last %d 0 R>>\nendobj\n",
+			pc.firstOutline, pc.lastOutline)), nil); err != nil {
+			return err
+		}
+	}
+	var buf bytes.Buffer
+	pagesOff := pc.pos
+	buf.WriteString("1 0 obj\n<</Type /Pages\n/Kids [")
+	for _, kid := range pc.kids {
+		fmt.Fprintf(&buf, "%d 0 R ", kid)
+	}
+	fmt.Fprintf(&buf, "]\n/Count %d\n%s\n>>\nendobj\n", len(pc.kids), pc.mediaBox)
+
+	info := len(pc.offsets) + 2
+	pc.offsets = append(pc.offsets, pagesOff+int64(buf.Len()))
+	fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", info, pc.info)
+	catalog := bytes.TrimSuffix(pc.catalog, []byte(">>"))
+	if pc.outlines != 0 && !rOutlines.Match(catalog) {
+		catalog = append(append([]byte(nil), catalog...), fmt.Sprintf("/Outlines %d 0 R\n/PageMode /UseOutlines\n", pc.outlines)...)
+	}
+	if pc.maxVersion > pc.version {
+		// a later version in the catalog overrides the header's
+		catalog = append(append([]byte(nil), catalog...), "/Version /"+pc.maxVersion+"\n"...)
+	}
+	pc.offsets = append(pc.offsets, pagesOff+int64(buf.Len()))
+	fmt.Fprintf(&buf, "%d 0 obj\n%s>>\nendobj\n", info+1, catalog)
+
+	xref := pagesOff + int64(buf.Len())
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n%010d 00000 n \n", len(pc.offsets)+2, pagesOff)
+	for _, off := range pc.offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<<\n/Size %d\n/Root %d 0 R\n/Info %d 0 R\n>>\nstartxref\n%d\n%%%%EOF\n",
+		len(pc.offsets)+2, info+1, info, xref)
+	return pc.write(buf.Bytes())
+}
+
+// parseXref returns the offset of the cross-reference table of the
+// document, the offsets of the objects in it, and the trailer.
+func parseXref(doc []byte) (xref int64, offsets map[int]int64, trailer []byte, err error) {
+	i := bytes.LastIndex(doc, []byte("startxref"))
+	if i < 0 {
+		return 0, nil, nil, errors.New("no startxref")
+	}
+	fields := bytes.Fields(doc[i+len("startxref"):])
+	if len(fields) == 0 {
+		return 0, nil, nil, errors.New("no xref offset")
+	}
+	if xref, err = strconv.ParseInt(string(fields[0]), 10, 64); err != nil || xref < 0 || xref >= int64(i) {
+		return 0, nil, nil, errors.Errorf("bad xref offset %q", fields[0])
+	}
+	section := doc[xref:i]
+	j := bytes.Index(section, []byte("trailer"))
+	if j < 0 || !bytes.HasPrefix(section, []byte("xref")) {
+		return 0, nil, nil, errors.New("no xref table")
+	}
+	trailer = section[j:]
+	lines := bytes.Split(bytes.TrimSpace(section[len("xref"):j]), []byte("\n"))
+	offsets = make(map[int]int64, len(lines))
+	var num int
+	for _, line := range lines {
+		f := bytes.Fields(line)
+		switch {
+		case len(f) == 2:
+			// subsection: first object number and count
+			if num, err = strconv.Atoi(string(f[0])); err != nil {
+				return 0, nil, nil, errors.Errorf("bad xref subsection %q", line)
+			}
+			continue
+		case len(f) == 3 && string(f[2]) == "n" && num != 0:
+			off, err := strconv.ParseInt(string(f[0]), 10, 64)
+			if err != nil {
+				return 0, nil, nil, errors.Errorf("bad xref entry %q", line)
+			}
+			offsets[num] = off
+		}
+		num++
+	}
+	return xref, offsets, trailer, nil
+}
+
+// minFlushPages is the least FlushPages: each flushed document embeds the
+// subsets of the fonts again (the glyphs used in it), as gofpdf cannot share
+// them, costing some 10 kB per font and flush.
+const minFlushPages = 50
+
+// flushDoc writes the document to concat and starts a new one in its
+// place, if it has flushPages pages.
+func (pr *pdfRenderer) flushDoc() {
+	if pr.flushPages <= 0 || pr.pdf.PageCount() < pr.flushPages {
+		return
+	}
+	pr.finishFonts()
+	err := pr.outputDoc()
+	pr.flushed += pr.pdf.PageCount()
+	pdf := pr.newDoc()
+	for _, f := range pr.fonts {
+		pdf.AddUTF8FontFromBytes(f.family, f.style, f.b)
+	}
+	if err != nil {
+		pdf.SetError(errors.Wrap(err, "flushing pages"))
+	}
+	pr.pdf = pdf
+	if pr.table != nil {
+		pr.table.pdf = pdf
+	}
+}
+
+// outputDoc writes the document to concat.
+func (pr *pdfRenderer) outputDoc() error {
+	var buf bytes.Buffer
+	if err := pr.pdf.Output(&buf); err != nil {
+		return err
+	}
+	if pr.concat == nil {
+		pr.concat = newPDFConcat(pr.w)
+	}
+	return pr.concat.add(buf.Bytes())
+}
@@ -0,0 +1,85 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/width"
+)
+
+// isWide reports whether r is an East Asian wide or fullwidth rune, printed
+// about twice as wide as a Latin letter.
+func isWide(r rune) bool {
+	if r < 0x1100 {
+		return false
+	}
+	switch width.LookupRune(r).Kind() {
+	case width.EastAsianWide, width.EastAsianFullwidth:
+		return true
+	}
+	return false
+}
+
+// cellWidth returns the width of s in characters, for the column widths:
+// its length in bytes (which makes the columns of accented text a bit
+// wider), but two for the wide runes.
+func cellWidth(s string) int {
+	n := len(s)
+	for _, r := range s {
+		if isWide(r) {
+			n -= utf8.RuneLen(r) - 2
+		}
+	}
+	return n
+}
+
+// displayWidth returns the width of s in monospace columns: one per rune,
+// two per wide rune.
+func displayWidth(s string) int {
+	var n int
+	for _, r := range s {
+		n++
+		if isWide(r) {
+			n++
+		}
+	}
+	return n
+}
+
+// noBreakBefore are the closing punctuation marks not starting a line,
+// noBreakAfter the opening ones not ending it.
+const (
+	noBreakBefore = "、。，．：；？！）」』】〕〉》ー々〜…"
+	noBreakAfter  = "（「『【〔〈《"
+)
+
+// wrapSegments splits the line into the segments that may not be broken:
+// the space separated words, and in them the wide runes one by one. The
+// glue of a segment is what joins it to the previous one on a line.
+func wrapSegments(line string) (segs, glues []string) {
+	for _, word := range strings.Fields(line) {
+		glue := " "
+		start := 0
+		for i, r := range word {
+			if !isWide(r) && (i == 0 || !isWide(lastRune(word[:i]))) {
+				continue
+			}
+			if i == start || strings.ContainsRune(noBreakBefore, r) || strings.ContainsRune(noBreakAfter, lastRune(word[:i])) {
+				continue
+			}
+			segs, glues = append(segs, word[start:i]), append(glues, glue)
+			glue, start = "", i
+		}
+		segs, glues = append(segs, word[start:]), append(glues, glue)
+	}
+	return segs, glues
+}
+
+func lastRune(s string) rune {
+	r, _ := utf8.DecodeLastRuneInString(s)
+	return r
+}
@@ -0,0 +1,168 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/tgulacsi/csv2pdf"
+	"gopkg.in/yaml.v2"
+)
+
+// runInit is the init subcommand: it inspects a sample file, asks about the
+// delimiter, the orientation and the style, and writes a -config file.
+func runInit(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	output := fs.String("o", "csv2pdf.yaml", "config file to write")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: csv2pdf init [-o csv2pdf.yaml] sample.csv")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	sample, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	// the answers tell what is found
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	in := bufio.NewScanner(stdin)
+	// ask asks the question till check accepts the answer, def is the
+	// answer of an empty line.
+	ask := func(question, def string, check func(string) error) (string, error) {
+		for {
+			fmt.Fprintf(stdout, "%s [%s]: ", question, def)
+			if !in.Scan() {
+				if err := in.Err(); err != nil {
+					return "", err
+				}
+				return "", io.ErrUnexpectedEOF
+			}
+			answer := strings.TrimSpace(in.Text())
+			if answer == "" {
+				answer = def
+			}
+			if err := check(answer); err != nil {
+				fmt.Fprintln(stdout, err)
+				continue
+			}
+			return answer, nil
+		}
+	}
+	oneOf := func(choices ...string) func(string) error {
+		return func(s string) error {
+			for _, c := range choices {
+				if s == c {
+					return nil
+				}
+			}
+			return fmt.Errorf("answer one of %s", strings.Join(choices, ", "))
+		}
+	}
+
+	var config yaml.MapSlice
+	opts := csv2pdf.DefaultOptions()
+	info, err := csv2pdf.Inspect(bytes.NewReader(sample), opts)
+	if err != nil {
+		return err
+	}
+	if !info.ValidUTF8 {
+		charset, err := ask("The sample is not UTF-8. Charset", "iso-8859-2", func(s string) error {
+			opts.Charset = s
+			info, err = csv2pdf.Inspect(bytes.NewReader(sample), opts)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		config = append(config, yaml.MapItem{Key: "charset", Value: charset})
+	}
+
+	guess := string(info.Delimiter)
+	if guess == "\t" {
+		guess = `\t`
+	}
+	delimiter, err := ask("Delimiter (a character, or \\t for tab)", guess, func(s string) error {
+		_, err := csv2pdf.ParseDelimiter(s)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if delimiter != guess {
+		if opts.Delimiter, err = csv2pdf.ParseDelimiter(delimiter); err != nil {
+			return err
+		}
+		if info, err = csv2pdf.Inspect(bytes.NewReader(sample), opts); err != nil {
+			return err
+		}
+	}
+	config = append(config, yaml.MapItem{Key: "delimiter", Value: delimiter})
+
+	var landscape int
+	for i, part := range info.Parts {
+		orientation := "portrait"
+		if part.Landscape {
+			orientation = "landscape"
+			landscape++
+		}
+		head := strings.Join(part.Head, ", ")
+		if len(head) > 60 {
+			head = head[:57] + "..."
+		}
+		fmt.Fprintf(stdout, "Part %d: %d columns (%s), %d rows, %d characters wide: %s\n",
+			i+1, len(part.Head), head, part.Rows, part.Width, orientation)
+	}
+	orientation, err := ask(fmt.Sprintf("Orientation: auto (by the width, %d of %d parts in landscape), portrait or landscape",
+		landscape, len(info.Parts)), "auto", oneOf("auto", "portrait", "landscape"))
+	if err != nil {
+		return err
+	}
+	if orientation != "auto" {
+		config = append(config, yaml.MapItem{Key: "orientation", Value: orientation})
+	}
+
+	style, err := ask("Style: normal, compact (smaller margins and fonts) or ink-saver (rules instead of fills)",
+		"normal", oneOf("normal", "compact", "ink-saver"))
+	if err != nil {
+		return err
+	}
+	if style != "normal" {
+		config = append(config, yaml.MapItem{Key: style, Value: true})
+	}
+
+	if _, err = os.Stat(*output); err == nil {
+		answer, err := ask(fmt.Sprintf("Overwrite %q?", *output), "n", oneOf("y", "n"))
+		if err != nil {
+			return err
+		}
+		if answer != "y" {
+			return fmt.Errorf("%q exists", *output)
+		}
+	}
+	b, err := yaml.Marshal(config)
+	if err != nil {
+		return err
+	}
+	b = append([]byte("# csv2pdf config, written by csv2pdf init from "+fs.Arg(0)+"\n"), b...)
+	if err = os.WriteFile(*output, b, 0o644); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Written %q. Convert with\n\tcsv2pdf -config %s input.csv\n", *output, *output)
+	return nil
+}
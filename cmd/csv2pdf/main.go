@@ -0,0 +1,372 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+// Package main of csv2pdf is a csv -> PDF printer.
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/tgulacsi/csv2pdf"
+	"gopkg.in/yaml.v2"
+)
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "selftest" {
+		fs := flag.NewFlagSet("selftest", flag.ExitOnError)
+		flagFontDir := fs.String("fontdir", "", "font directory (default: the embedded fonts)")
+		flagVerbose := fs.Bool("v", false, "verbose: print the successful checks, too")
+		fs.Parse(os.Args[2:])
+		if !*flagVerbose {
+			log.SetOutput(io.Discard)
+		}
+		err := csv2pdf.SelfTest(os.Stdout, *flagFontDir, *flagVerbose)
+		log.SetOutput(os.Stderr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "init" {
+		if err := runInit(os.Args[2:], os.Stdin, os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	opts := csv2pdf.DefaultOptions()
+	var output, inputFS string
+	flag.StringVar(&inputFS, "input-fs", "", `read the input files (csv, schema, disclaimer, attachments) from this file system: "zip:archive.zip" or "dir:path"`)
+	flag.StringVar(&output, "o", "", "output file, written to a temporary file and renamed on success only (default: stdout)")
+	flag.StringVar(&output, "output", "", "same as -o")
+	flagFollow := flag.Bool("follow", false, "follow the growing input file (as tail -f), rendering the -o output again as rows arrive, till interrupted")
+	flagFlushEvery := flag.Duration("flush-every", 10*time.Second, "with -follow or a FIFO input, render the -o output at most this often")
+	profiles := csv2pdf.InputProfiles()
+	profileHelp := make([]string, 0, len(profiles))
+	for name, desc := range profiles {
+		profileHelp = append(profileHelp, name+" ("+desc+")")
+	}
+	sort.Strings(profileHelp)
+	flag.String("config", "", "YAML file of flag settings (flag: value), as written by csv2pdf init, and the settings of the columns (columns: a list of name, title, width, align, format, truncate, as -column); overridden by the flags given")
+	flag.String("input-profile", "", "input settings (delimiter, charset, skipped lines, date format) of an exporter, overridden by the flags given: "+strings.Join(profileHelp, ", "))
+	flag.Func("delimiter", `field separator: a character, or \t for tab (default: detected from the first lines, one of ; , tab |)`, func(s string) error {
+		d, err := csv2pdf.ParseDelimiter(s)
+		opts.Delimiter = d
+		return err
+	})
+	flag.StringVar(&opts.Charset, "charset", opts.Charset, "input charset")
+	flag.StringVar(&opts.FontDir, "fontdir", opts.FontDir, "font directory")
+	flag.StringVar(&opts.Format, "format", opts.Format, "output format: pdf, txt (fixed-width text), md (Markdown table) or xlsx")
+	flag.StringVar(&opts.AlsoCSV, "also-csv", opts.AlsoCSV, "also write the rendered (transformed) rows as CSV to this file")
+	flag.StringVar(&opts.Preview, "preview", opts.Preview, "also render the first page as preview image to this file (.png, .jpg or .svg; needs pdftoppm, or pdftocairo for .svg, of poppler-utils on the PATH)")
+	flag.IntVar(&opts.PreviewSize, "preview-size", opts.PreviewSize, "preview size in pixels (longer side)")
+	flag.StringVar(&opts.FormColumns, "form-columns", opts.FormColumns, `comma-separated list of columns rendered as form fields to be completed on the printout or in a PDF viewer: "name" for a write-in box, "name:check" for a checkbox`)
+	flag.IntVar(&opts.RotateHeaders, "rotate-headers", opts.RotateHeaders, "print the heads rotated by 90 or 45 degrees, so the many narrow columns of long heads fit in portrait (pdf)")
+	flag.StringVar(&opts.VerticalColumns, "vertical-columns", opts.VerticalColumns, "comma separated list of the narrow columns to print rotated by 90° (pdf)")
+	flag.StringVar(&opts.ColumnGroups, "column-groups", opts.ColumnGroups, `| separated groups of comma separated columns, as "id,name|q1,q2,q3|total", with heavier rules between the groups (pdf)`)
+	flag.StringVar(&opts.Heatmap, "heatmap", opts.Heatmap, `comma separated list of numeric columns to shade on a green-yellow-red scale between their minimum and maximum, or fixed "col=min:max" thresholds (pdf)`)
+	flag.StringVar(&opts.Icons, "icons", opts.Icons, `comma separated list of numeric columns with threshold icons: "col=dot:lo:hi" for a red/yellow/green dot, "col=arrow[:ref]" for a green up/red down arrow; append ":only" to print the icon instead of the value (pdf)`)
+	flag.StringVar(&opts.Outliers, "outliers", opts.Outliers, `flag the outliers of the numeric columns by "z[:factor]" (z-score, default 3) or "iqr[:factor]" (interquartile range, default 1.5), printing them red and listing them on the -summary page (pdf)`)
+	flag.BoolVar(&opts.Quality, "quality", opts.Quality, "add a data-quality scorecard: empty cells, type consistency, malformed dates and duplicate keys per part (pdf)")
+	flag.StringVar(&opts.QualityKey, "quality-key", opts.QualityKey, "key column for the duplicate check of -quality (default: the first column)")
+	flag.IntVar(&opts.Sample, "sample", opts.Sample, "render only a random sample of this many rows per part, labeled as such, with the statistics of all the rows")
+	flag.Int64Var(&opts.Seed, "seed", opts.Seed, "random seed of the sampling features (-sample), for reproducible results in tests and audits (default: random, printed in the labels and the log)")
+	flag.Int64Var(&opts.Seed, "sample-seed", opts.Seed, "deprecated alias of -seed")
+	flag.IntVar(&opts.TransposeRows, "transpose-rows", opts.TransposeRows, "print the parts of at most this many rows, but more than twice as many columns, transposed: a row per column and a column per record (headed by its first value), instead of a very wide table (not with -head and -tail; 0: never)")
+	flag.IntVar(&opts.Head, "head", opts.Head, "render only the first N rows of each part (with -tail), noting the number of the omitted rows")
+	flag.IntVar(&opts.Tail, "tail", opts.Tail, "render only the last N rows of each part (with -head), noting the number of the omitted rows")
+	flag.BoolVar(&opts.OrderColumns, "order-columns", opts.OrderColumns, "reorder the columns by their information content: identifying columns first, near-constant ones last, noting the constant values above the table")
+	flag.BoolVar(&opts.CollapseConstant, "collapse-constant", opts.CollapseConstant, `leave out the columns with the same value in all rows, noting them once above the table ("Region: EU-West")`)
+	flag.StringVar(&opts.Post, "post", opts.Post, `post-processing pipeline of the PDF, run in-process with pdfcpu: "step[:args];..." of rotate:90, crop:box (pdfcpu box, in mm), stamp:text[:desc] (pdfcpu description), optimize, encrypt:user[:owner] (AES-256)`)
+	flag.BoolVar(&opts.Verify, "verify", opts.Verify, "read the generated PDF back, validating its structure, checking its page count and that it has text, failing on a corrupt output (pdf)")
+	flag.StringVar(&opts.PDFVersion, "pdf-version", opts.PDFVersion, "PDF version to write: 1.3 - 1.7 or 2.0, e.g. 1.4 for legacy archive systems; fails if the document needs a newer one; -verify cannot read 2.0 (default: as needed)")
+	flag.BoolVar(&opts.Grayscale, "grayscale", opts.Grayscale, "map all the colors to their gray equivalents (the table fills to light, the borders to dark levels, for black-and-white laser printers), and check that no color is left in the PDF (print shops, archive profiles)")
+	flag.BoolVar(&opts.InkSaver, "ink-saver", opts.InkSaver, "print economy: thin rules under the header and the rows instead of the solid header and stripe fills, for long listings (pdf)")
+	colorFlag("header-bg", "fill color of the header band, as #RRGGBB (pdf)", &opts.Colors.HeaderFill)
+	colorFlag("header-fg", "text color of the header band, as #RRGGBB (pdf)", &opts.Colors.HeaderText)
+	colorFlag("stripe-color", "fill color of every second row, as #RRGGBB (pdf)", &opts.Colors.Stripe)
+	colorFlag("border-color", "color of the cell borders, as #RRGGBB (pdf)", &opts.Colors.Border)
+	flag.StringVar(&opts.Borders, "borders", opts.Borders, "borders of the tables: grid (around every cell), columns (between the columns, the default), rows (rules under the rows), frame (around the table only) or none (pdf)")
+	flag.StringVar(&opts.Receipt, "receipt", opts.Receipt, `print the records for thermal receipt printers, as "head: value" lines on a roll of this width in mm (58 or 80): each part on one page as long as needed, or each record on its own page with "58:record" (pdf)`)
+	flag.StringVar(&opts.Split, "split", opts.Split, "write each part (column-count change) into its own file, named by this template from the part number, e.g. report-%02d.pdf; nothing is written to the standard output")
+	flag.IntVar(&opts.FlushPages, "flush-pages", opts.FlushPages, "write the finished pages to the output every this many pages (at least 50, as each flush embeds the fonts again), bounding the memory use of long documents; the bookmarks are not kept (pdf; not with -summary, -toc-json or -index-column)")
+	flag.StringVar(&opts.PageSize, "page-size", opts.PageSize, "page size: A3, A4, A5, Letter, Legal, or WxH in mm, or with a unit (cm, in or pt), as 4x6in (pdf)")
+	flag.IntVar(&opts.SkipRows, "skip-rows", opts.SkipRows, "skip this many lines at the start of the input (banner lines)")
+	flag.BoolVar(&opts.SkipBanner, "skip-banner", opts.SkipBanner, "skip the lines without the delimiter at the start of the input (after -skip-rows): the titles above the table")
+	flag.StringVar(&opts.DateFormat, "date-format", opts.DateFormat, `Go time layout of the dates in the input, as 02.01.2006, to print them as 2006-01-02; or "in=out" to print them with the out layout`)
+	// the first -orientation of the command line replaces the ones of the
+	// config, the later ones are appended
+	var replaceOrientation bool
+	flag.Func("orientation", "page orientation: portrait, landscape or auto (by the width of the table); per part as 2=landscape, repeatable (pdf)", func(s string) error {
+		if replaceOrientation {
+			opts.Orientation, replaceOrientation = "", false
+		}
+		if opts.Orientation != "" {
+			s = opts.Orientation + "," + s
+		}
+		opts.Orientation = s
+		return nil
+	})
+	flag.StringVar(&opts.Background, "background", opts.Background, "image (PNG, JPEG or GIF) printed under every page, stretched to the page: a rendered letterhead")
+	flag.StringVar(&opts.HeaderImage, "header-image", opts.HeaderImage, "image strip printed across the top of every page, the table starts below it")
+	flag.StringVar(&opts.FooterImage, "footer-image", opts.FooterImage, "image strip printed across the bottom of every page, the table ends above it")
+	flag.StringVar(&opts.Stationery, "stationery", opts.Stationery, "PDF whose first page is laid under every page, scaled to the page width: a strict corporate template")
+	flag.StringVar(&opts.QR, "qr", opts.QR, "text printed as a QR code in the bottom right corner of every page: the URL of the source data or the job ID, to trace the printed pages back")
+	flag.StringVar(&opts.PageHMAC, "page-hmac", opts.PageHMAC, "file with the key of the HMAC-SHA256 of the rows of each page, printed at the bottom of the page: a page printed again from the same input has the same code, a changed one another")
+	flag.BoolVar(&opts.SplitWide, "split-wide", opts.SplitWide, `print the tables wider than the page (even with the fonts shrunk) on sets of pages, the columns not fitting on the following "continued" pages`)
+	flag.IntVar(&opts.KeyColumns, "key-columns", opts.KeyColumns, "the number of the first columns repeated on each page of a -split-wide page set")
+	flag.StringVar(&opts.RepeatRight, "repeat-right", opts.RepeatRight, "comma separated list of the key columns repeated at the right edge of the landscape tables, not to have to trace the long rows back to the left edge")
+	flag.Float64Var(&opts.MinColWidth, "min-col-width", opts.MinColWidth, "the narrowest width of the measured columns in mm, so short values (as booleans) are still readable; per column as min_width in -column")
+	flag.Float64Var(&opts.MinFontSize, "min-font-size", opts.MinFontSize, "the tables wider than the page are printed with smaller fonts, down to this body font size in points; 0 disables the shrinking")
+	flag.BoolVar(&opts.RTL, "rtl", opts.RTL, "right to left tables, for Hebrew and Arabic: the first column on the right, the text aligned right (the right to left text is reordered and the Arabic shaped in any case, needs a UTF-8 font as -utf8-font)")
+	flag.StringVar(&opts.AgeColors, "age-colors", opts.AgeColors, `shade the rows by the age of the date in a column, as "due=14:30": yellow older than 14 days, red older than 30 days; append ":2006-01-02" to count the ages to that day instead of today (pdf)`)
+	flag.IntVar(&opts.Ruler, "ruler", opts.Ruler, `print the row numbers in the left margin every this many rows (as 5 or 10), to reference the rows of the printed listings as "row 1230 on page 17" (pdf)`)
+	flag.BoolVar(&opts.RulerLines, "ruler-lines", opts.RulerLines, "draw thin guide lines under the -ruler rows (pdf)")
+	flag.Func("column", `settings of a column (by name or 1-based number), as "3:width=30,align=right,title=Amount": title, width (mm), min_width (mm), align (left, center, right), format (of the numbers, as %.2f), truncate (as -truncate), wrap, color (#RRGGBB), bold, italic or underline; repeatable, overriding the columns of the -config`, func(s string) error {
+		c, err := csv2pdf.ParseColumn(s)
+		if err != nil {
+			return err
+		}
+		opts.Columns = append(opts.Columns, c)
+		return nil
+	})
+	flag.StringVar(&opts.AttachColumn, "attach-column", opts.AttachColumn, "column with file paths or URLs to be embedded as attachments of the rows")
+	flag.StringVar(&opts.Disclaimer, "disclaimer", opts.Disclaimer, "file with the disclaimer/legal text to print in small print at the end of the document")
+	flag.BoolVar(&opts.DisclaimerEveryPage, "disclaimer-every-page", opts.DisclaimerEveryPage, "print the -disclaimer at the bottom of every page instead of at the end (pdf)")
+	flag.StringVar(&opts.Footer, "footer", opts.Footer, `footer line of every page, a comma separated list of "page" (the page number, as "Page 3 / 12"), "date" (of the generation) and "file" (the name of the input), as "page,date,file" (pdf)`)
+	flag.StringVar(&opts.HeaderTemplate, "header-tmpl", opts.HeaderTemplate, `Go template of the header line of every page, as "{{.Title}} - {{.Date}}", with .Title, .File, .Date, .Part, .PartTitle, .Page, .Pages, .FirstRow and .LastRow (the rows of the page) (pdf)`)
+	flag.StringVar(&opts.FooterTemplate, "footer-tmpl", opts.FooterTemplate, `Go template of the footer line of every page, as "{{.File}}: rows {{.FirstRow}}-{{.LastRow}}", with the variables of -header-tmpl (pdf)`)
+	flag.StringVar(&opts.Title, "title", opts.Title, "title of the document, {{.Title}} in the -header-tmpl and -footer-tmpl (pdf)")
+	flag.BoolVar(&opts.Provenance, "provenance", opts.Provenance, "print who/where/when/how generated the report on each page, and record the command line in the metadata (pdf)")
+	flag.StringVar(&opts.FontFile, "font-file", opts.FontFile, "TrueType (or TrueType-flavoured OpenType) font file to embed and use instead of Arial, as regular[,bold]; its license is recorded in the metadata (pdf)")
+	flag.StringVar(&opts.FontFamily, "font-family", opts.FontFamily, "family name of the -font-file (default: its file name); without it, the core font to use: Arial, Helvetica, Times or Courier")
+	flag.BoolVar(&opts.UTF8Font, "utf8-font", opts.UTF8Font, "embed the bundled DejaVu Sans Condensed instead of Arial, printing the runes out of the charset, too (pdf)")
+	flag.StringVar(&opts.FallbackFonts, "fallback-fonts", opts.FallbackFonts, "comma separated list of TrueType fonts for the glyphs missing from the -font-file, tried in order (pdf)")
+	flag.StringVar(&opts.CJKFont, "cjk-font", opts.CJKFont, "TrueType font with the Chinese, Japanese and Korean glyphs (e.g. Noto Sans CJK), added to the -fallback-fonts; with the bundled DejaVu without -font-file")
+	flag.BoolVar(&opts.Symbols, "symbols", opts.Symbols, "print the common symbols and emoji (✓ ✗ ⚠ ● ★ ...) with the ZapfDingbats font")
+	flag.BoolVar(&opts.Ligatures, "ligatures", opts.Ligatures, "use the standard ligatures (fi, fl, ff, ffi, ffl) of the -font-file (pdf)")
+	flag.BoolVar(&opts.ScriptMarkup, "script-markup", opts.ScriptMarkup, "print ^{...} as superscript and _{...} as subscript (the Unicode super- and subscript characters are always printed so) (pdf)")
+	flag.BoolVar(&opts.EmbedAllGlyphs, "embed-all-glyphs", opts.EmbedAllGlyphs, "embed all the glyphs of the character map of the -font-file fonts, not just the used ones, for editing the text later (still a subset, without the original font program) (pdf)")
+	flag.BoolVar(&opts.Summary, "summary", opts.Summary, "start with a summary page linking to the parts")
+	flag.StringVar(&opts.TOCJSON, "toc-json", opts.TOCJSON, "write the page numbers of the sections, parts and index entries to this JSON file, for external navigation (pdf)")
+	flag.StringVar(&opts.IndexColumn, "index-column", opts.IndexColumn, "add an alphabetical index of the values of this column with their page numbers")
+	flag.BoolVar(&opts.RowNumbers, "row-numbers", opts.RowNumbers, "prepend a row number column, numbering continuously across the parts")
+	flag.StringVar(&opts.TotalColumns, "total-columns", opts.TotalColumns, "comma-separated list of numeric columns to be summed, with carried/brought forward lines at page breaks, and across the -split files")
+	flag.Float64Var(&opts.HeaderHeight, "header-height", opts.HeaderHeight, "height of the header row in mm (default: 7, 4.5 with -compact)")
+	flag.Float64Var(&opts.RowHeight, "row-height", opts.RowHeight, "height of the body rows in mm (default: 6, 3.6 with -compact); lower for dense reports, higher for large print")
+	flag.Float64Var(&opts.CellPadding, "cell-padding", opts.CellPadding, "space between the cell borders and the text in mm (default: 1)")
+	flag.BoolVar(&opts.Compact, "compact", opts.Compact, "paper-saving layout: smaller margins, rows and fonts, without fills and borders (pdf)")
+	flag.StringVar(&opts.Truncate, "truncate", opts.Truncate, `truncation of too long values: none, end, middle (keeps the start and the end, for long IDs) or wrap (into more lines, making the row higher); per column as "end,id=middle,notes=wrap" (pdf)`)
+	flag.BoolVar(&opts.SharedWidths, "shared-widths", opts.SharedWidths, "use the same column widths for all the parts with identical headers (default: per-part widths)")
+	flag.StringVar(&opts.Sort, "sort", opts.Sort, `sort the rows of each part by these columns: "col[:desc],..."`)
+	flag.StringVar(&opts.SortCollation, "sort-collation", opts.SortCollation, `comparison of the text values of -sort: "natural" (file2 < file10), a language tag (e.g. "hu") for its collation, or both as "natural,hu" (default: byte-wise)`)
+	flag.StringVar(&opts.MemLimit, "mem-limit", opts.MemLimit, "memory budget for sorting; above it the rows are spilled to temporary files")
+	flag.StringVar(&opts.Dialect, "dialect", opts.Dialect, "keep the input dialect (delimiter, charset, skipped rows) in the input.dialect.json file next to the input: reuse reads the input as saved there (saving the detected dialect if there is none), review reports whether the detected dialect differs from the saved one and uses the saved one, force saves the detected (or given) dialect")
+	flag.IntVar(&opts.TranslatorCache, "translator-cache", opts.TranslatorCache, "number of translated values cached (0 disables the cache)")
+	flag.BoolVar(&opts.FastCSV, "fast-csv", opts.FastCSV, "use the fast CSV reader for large, well-formed files")
+	flag.StringVar(&opts.DebugLayout, "debug-layout", opts.DebugLayout, `write a layout trace (widths, page breaks, truncated/overflowing cells) to this file ("-" for stderr)`)
+	flag.StringVar(&opts.PinLayout, "pin-layout", opts.PinLayout, "layout JSON file: use the layout (style, orientation, column widths) stored in it, or store the measured layout in it if it does not exist")
+	flag.BoolVar(&opts.DebugGrid, "debug-grid", opts.DebugGrid, "draw a mm grid and the margin boxes on every page (pdf)")
+	flag.StringVar(&opts.HeaderDetect, "header-detect", opts.HeaderDetect, "also start a new part at rows looking like a header, even with the same column count: comma separated list of repeat (same as a previous header), alpha (all-alphabetic row where numbers were)")
+	flag.StringVar(&opts.HeaderRegexp, "header-regexp", opts.HeaderRegexp, "also start a new part at rows whose first column matches this regexp")
+	flag.StringVar(&opts.ExpectSchema, "expect-schema", opts.ExpectSchema, "JSON file of the header and the column types (number, date, text) of a previous run: the differences of the input are logged before printing, catching the export format changing upstream; saved if it does not exist (remove it to accept a new format)")
+	flag.BoolVar(&opts.ExpectSchemaAbort, "expect-schema-abort", opts.ExpectSchemaAbort, "abort instead of printing if the input differs from the -expect-schema (exit status 3)")
+	flag.StringVar(&opts.Schema, "schema", opts.Schema, "Table Schema (datapackage.json, schema.json) or CSVW metadata describing the columns")
+	// the input profile is overridden by the config, that by the flags given
+	config, err := readConfig(findFlag(flag.CommandLine, os.Args[1:], "config"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	profile := findFlag(flag.CommandLine, os.Args[1:], "input-profile")
+	for _, item := range config {
+		if item.Key == "input-profile" && profile == "" {
+			profile = fmt.Sprint(item.Value)
+		}
+	}
+	if profile != "" {
+		if err := opts.ApplyInputProfile(profile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	for _, item := range config {
+		if name := fmt.Sprint(item.Key); name == "columns" {
+			if opts.Columns, err = configColumns(item.Value); err != nil {
+				log.Fatalf("config %s: %v", name, err)
+			}
+		} else if name != "input-profile" && name != "config" {
+			if err := flag.Set(name, fmt.Sprint(item.Value)); err != nil {
+				log.Fatalf("config %s: %v", name, err)
+			}
+		}
+	}
+	replaceOrientation = true
+	flag.Parse()
+
+	if inputFS != "" {
+		fsys, err := openFS(inputFS)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts.FS = fsys
+	}
+	var input io.Reader = os.Stdin
+	var fifo bool
+	if fn := flag.Arg(0); fn != "" && fn != "-" {
+		var fh io.ReadCloser
+		var err error
+		if opts.FS != nil {
+			fh, err = opts.FS.Open(fn)
+		} else {
+			fh, err = os.Open(fn)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer fh.Close()
+		input, opts.InputName = fh, fn
+		if fi, err := os.Stat(fn); err == nil && opts.FS == nil {
+			fifo = fi.Mode()&os.ModeNamedPipe != 0
+		}
+	}
+	opts.CommandLine = os.Args
+	if *flagFollow || fifo && output != "" {
+		if output == "" {
+			log.Fatal("-follow needs -o")
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		err = csv2pdf.Follow(ctx, input, output, *flagFlushEvery, *flagFollow, opts)
+		stop()
+	} else if output != "" {
+		err = csv2pdf.ConvertFile(input, output, opts)
+	} else {
+		err = csv2pdf.Convert(input, os.Stdout, opts)
+	}
+	if err != nil {
+		log.Print(err)
+		os.Exit(exitCode(err))
+	}
+}
+
+// exitCode returns the exit status of the error: 2 for the bad settings
+// (as the flag errors), 3 for the bad input, 4 for an exceeded limit.
+func exitCode(err error) int {
+	switch {
+	case errors.Is(err, csv2pdf.ErrBadDelimiter), errors.Is(err, csv2pdf.ErrCharsetUnsupported):
+		return 2
+	case errors.Is(err, csv2pdf.ErrTooManyColumns), errors.Is(err, csv2pdf.ErrSchemaChanged):
+		return 3
+	case errors.Is(err, csv2pdf.ErrResourceLimit):
+		return 4
+	}
+	return 1
+}
+
+// openFS opens the "zip:archive.zip" or "dir:path" file system.
+func openFS(spec string) (fs.FS, error) {
+	kind, arg, _ := strings.Cut(spec, ":")
+	switch kind {
+	case "zip":
+		return zip.OpenReader(arg)
+	case "dir":
+		return os.DirFS(arg), nil
+	default:
+		return nil, fmt.Errorf("unknown file system %q (zip:archive.zip or dir:path)", spec)
+	}
+}
+
+// findFlag returns the value of the flag in args, as the flag package would
+// parse it with the flags of fs (skipping the values of the other flags);
+// "" if not found.
+func findFlag(fs *flag.FlagSet, args []string, name string) string {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" || !strings.HasPrefix(arg, "-") || arg == "-" {
+			return ""
+		}
+		arg = strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-")
+		k, v, hasValue := strings.Cut(arg, "=")
+		if k == name {
+			if hasValue {
+				return v
+			}
+			if i+1 < len(args) {
+				return args[i+1]
+			}
+			return ""
+		}
+		if !hasValue && !isBoolFlag(fs, k) {
+			i++ // its value
+		}
+	}
+	return ""
+}
+
+// isBoolFlag reports whether the flag of fs is a boolean one, without value.
+func isBoolFlag(fs *flag.FlagSet, name string) bool {
+	f := fs.Lookup(name)
+	if f == nil {
+		return false
+	}
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// colorFlag defines the #RRGGBB color flag setting c.
+func colorFlag(name, usage string, c *csv2pdf.RGB) {
+	flag.Func(name, fmt.Sprintf("%s (default %s)", usage, c), func(s string) error {
+		rgb, err := csv2pdf.ParseRGB(s)
+		if err != nil {
+			return err
+		}
+		*c = rgb
+		return nil
+	})
+}
+
+// readConfig reads the flag settings from the YAML file, if fn is not empty.
+func readConfig(fn string) (yaml.MapSlice, error) {
+	if fn == "" {
+		return nil, nil
+	}
+	if strings.EqualFold(filepath.Ext(fn), ".toml") {
+		return nil, fmt.Errorf("%s: TOML is not supported, only YAML (as written by csv2pdf init)", fn)
+	}
+	b, err := os.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	var config yaml.MapSlice
+	if err = yaml.Unmarshal(b, &config); err != nil {
+		return nil, fmt.Errorf("%s: %w", fn, err)
+	}
+	return config, nil
+}
+
+// configColumns decodes the columns list of the config.
+func configColumns(v interface{}) ([]csv2pdf.Column, error) {
+	b, err := yaml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var columns []csv2pdf.Column
+	if err = yaml.Unmarshal(b, &columns); err != nil {
+		return nil, err
+	}
+	return columns, nil
+}
@@ -0,0 +1,33 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"strings"
+	"testing"
+)
+
+func TestFindFlag(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.String("delimiter", "", "")
+	fs.String("config", "", "")
+	fs.Bool("summary", false, "")
+	for _, tc := range []struct {
+		args, want string
+	}{
+		{"-config c.yaml in.csv", "c.yaml"},
+		{"-delimiter ; -config c.yaml in.csv", "c.yaml"},
+		{"-summary -config=c.yaml in.csv", "c.yaml"},
+		{"--delimiter=; --config c.yaml", "c.yaml"},
+		{"-delimiter -config in.csv", ""},
+		{"in.csv -config c.yaml", ""},
+		{"-summary -- -config c.yaml", ""},
+	} {
+		if got := findFlag(fs, strings.Fields(tc.args), "config"); got != tc.want {
+			t.Errorf("%q: got %q, wanted %q", tc.args, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,299 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Column holds the settings of a column, matched by its head or schema
+// field name, or by its (1-based) number.
+type Column struct {
+	Name string
+	// Title is printed as the head of the column.
+	Title string
+	// Width is the width of the column in mm (pdf), instead of measuring it.
+	Width float64
+	// MinWidth is the narrowest width of the measured column in mm (pdf),
+	// overriding -min-col-width.
+	MinWidth float64 `yaml:"min_width"`
+	// Align is the alignment of the values: left, center or right (or L, C, R).
+	Align string
+	// Format is the fmt verb the numbers are printed with, as %.2f; the
+	// other values are printed as is.
+	Format string
+	// Truncate is the truncation of the too long values: none, end, middle
+	// or wrap, as -truncate.
+	Truncate string
+	// Color is the #RRGGBB color of the values (pdf).
+	Color string
+	// Bold, Italic and Underline emphasize the values (pdf; no italic with
+	// the font files).
+	Bold, Italic, Underline bool
+}
+
+// columnFlags are the settings without values, ending a title.
+var columnFlags = map[string]bool{"wrap": true, "bold": true, "italic": true, "underline": true}
+
+// ParseColumn parses the "name:key=value,..." column spec, as
+// "3:width=30,align=right,title=Amount,bold"; the keys are the lowercase
+// names of the Column fields, the flags (bold, italic, underline) are
+// without values, and wrap stands for truncate=wrap.
+func ParseColumn(spec string) (Column, error) {
+	name, settings, _ := strings.Cut(spec, ":")
+	c := Column{Name: strings.TrimSpace(name)}
+	var key string
+	for _, s := range strings.Split(settings, ",") {
+		k, v, ok := strings.Cut(s, "=")
+		if !ok && key == "title" && !columnFlags[strings.ToLower(strings.TrimSpace(s))] {
+			// a comma in the title
+			c.Title += "," + s
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(k))
+		switch key {
+		case "":
+		case "title":
+			c.Title = v
+		case "width":
+			w, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return c, errors.Wrapf(err, "%s: width", spec)
+			}
+			c.Width = w
+		case "min_width":
+			w, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return c, errors.Wrapf(err, "%s: min_width", spec)
+			}
+			c.MinWidth = w
+		case "align":
+			c.Align = strings.TrimSpace(v)
+		case "format":
+			c.Format = strings.TrimSpace(v)
+		case "truncate":
+			c.Truncate = strings.TrimSpace(v)
+		case "wrap":
+			c.Truncate = "wrap"
+		case "color":
+			c.Color = strings.TrimSpace(v)
+		case "bold":
+			c.Bold = true
+		case "italic":
+			c.Italic = true
+		case "underline":
+			c.Underline = true
+		default:
+			return c, errors.Errorf("%s: unknown setting %q (title, width, min_width, align, format, truncate, wrap, color, bold, italic or underline)", spec, k)
+		}
+	}
+	return c, c.check()
+}
+
+// boldWidthFactor is the width of the bold text relative to the regular.
+const boldWidthFactor = 1.1
+
+// columnAligns maps the Column.Align values to the CellFormat alignments.
+var columnAligns = map[string]string{"left": "L", "center": "C", "right": "R", "l": "L", "c": "C", "r": "R"}
+
+// check the settings.
+func (c Column) check() error {
+	if c.Name == "" {
+		return errors.New("column without name")
+	}
+	if c.Width < 0 || c.MinWidth < 0 {
+		return errors.Errorf("column %q: negative width", c.Name)
+	}
+	if c.Align != "" && columnAligns[strings.ToLower(c.Align)] == "" {
+		return errors.Errorf("column %q: align %q is not left, center or right", c.Name, c.Align)
+	}
+	if _, err := parseTruncMode(c.Truncate); err != nil {
+		return errors.Wrapf(err, "column %q", c.Name)
+	}
+	if c.Color != "" {
+		if _, err := ParseRGB(c.Color); err != nil {
+			return errors.Wrapf(err, "column %q: color", c.Name)
+		}
+	}
+	if c.Format != "" {
+		if s := fmt.Sprintf(c.Format, 1.5); strings.Contains(s, "%!") {
+			return errors.Errorf("column %q: format %q is not a verb for numbers, as %%.2f", c.Name, c.Format)
+		}
+	}
+	return nil
+}
+
+// format returns v printed with the Format, if it is a number.
+func (c *Column) format(v string) string {
+	if c == nil || c.Format == "" {
+		return v
+	}
+	if f, _, ok := parseNumber(strings.TrimSpace(v)); ok {
+		return fmt.Sprintf(c.Format, f)
+	}
+	return v
+}
+
+// merge returns the settings of c overridden by the ones set in o.
+func (c Column) merge(o Column) Column {
+	if o.Title != "" {
+		c.Title = o.Title
+	}
+	if o.Width != 0 {
+		c.Width = o.Width
+	}
+	if o.MinWidth != 0 {
+		c.MinWidth = o.MinWidth
+	}
+	if o.Align != "" {
+		c.Align = o.Align
+	}
+	if o.Format != "" {
+		c.Format = o.Format
+	}
+	if o.Truncate != "" {
+		c.Truncate = o.Truncate
+	}
+	if o.Color != "" {
+		c.Color = o.Color
+	}
+	c.Bold, c.Italic, c.Underline = c.Bold || o.Bold, c.Italic || o.Italic, c.Underline || o.Underline
+	return c
+}
+
+// textStyle returns the font style (of B, I and U) and the color of the
+// values of the column, nil for the default; italic only if the fonts
+// have it.
+func (c *Column) textStyle(italic bool) (string, *RGB) {
+	if c == nil {
+		return "", nil
+	}
+	var fontStyle string
+	if c.Bold {
+		fontStyle += "B"
+	}
+	if c.Italic && italic {
+		fontStyle += "I"
+	}
+	if c.Underline {
+		fontStyle += "U"
+	}
+	if c.Color == "" {
+		return fontStyle, nil
+	}
+	color, err := ParseRGB(c.Color)
+	if err != nil {
+		return fontStyle, nil
+	}
+	return fontStyle, &color
+}
+
+// columnSettings are the settings of the columns of Options.Columns; the
+// later settings of the same column override the earlier ones.
+type columnSettings []Column
+
+// of returns the settings of the columns of the part, nil if none matches.
+func (cs columnSettings) of(part partDesc) []*Column {
+	var cols []*Column
+	for j := range cs {
+		name := strings.TrimSpace(cs[j].Name)
+		i := part.columnIndex(name)
+		if n, err := strconv.Atoi(name); i < 0 && err == nil && n >= 1 && n <= len(part.head) {
+			i = n - 1
+		}
+		if i < 0 {
+			continue
+		}
+		if cols == nil {
+			cols = make([]*Column, len(part.head))
+		}
+		if cols[i] == nil {
+			cols[i] = &cs[j]
+		} else {
+			c := cols[i].merge(cs[j])
+			cols[i] = &c
+		}
+	}
+	return cols
+}
+
+// apply sets the settings of the matching columns of the part. The titled
+// columns keep their names as schema field names, to be found by them.
+func (cs columnSettings) apply(part *partDesc) {
+	if part.columns = cs.of(*part); part.columns == nil {
+		return
+	}
+	for i, c := range part.columns {
+		if c == nil {
+			continue
+		}
+		if c.Align != "" {
+			if part.aligns == nil {
+				part.aligns = make([]string, len(part.head))
+			}
+			part.aligns[i] = columnAligns[strings.ToLower(c.Align)]
+		}
+		if c.Title != "" {
+			if part.fields == nil {
+				part.fields = make([]*schemaField, len(part.head))
+			}
+			if part.fields[i] == nil {
+				part.fields[i] = &schemaField{Name: strings.TrimSpace(part.head[i])}
+			}
+			part.head = append([]string(nil), part.head...)
+			part.head[i] = c.Title
+		}
+	}
+}
+
+// column returns the settings of the i-th column, nil if none.
+func (part partDesc) column(i int) *Column {
+	if i < len(part.columns) {
+		return part.columns[i]
+	}
+	return nil
+}
+
+// truncModes returns the truncation modes of the columns of the part by
+// ts, overridden by the Truncate of the column settings.
+func (part partDesc) truncModes(ts truncSpec) []truncMode {
+	modes := ts.modes(part)
+	for i, c := range part.columns {
+		if c != nil && c.Truncate != "" && i < len(modes) {
+			modes[i], _ = parseTruncMode(c.Truncate)
+		}
+	}
+	return modes
+}
+
+// formatRecord formats the values of the record in place by the column
+// settings.
+func (part partDesc) formatRecord(record []string) {
+	for i, c := range part.columns {
+		if c != nil && c.Format != "" && i < len(record) {
+			record[i] = c.format(record[i])
+		}
+	}
+}
+
+// columnFormatter formats the values of the pre-pass in place, so the
+// widths are measured on the printed values; it is the first observer.
+type columnFormatter struct {
+	settings columnSettings
+	part     partDesc
+}
+
+func (cf *columnFormatter) startPart(head []string) {
+	cf.part = partDesc{head: head}
+	cf.part.columns = cf.settings.of(cf.part)
+}
+
+func (cf *columnFormatter) observe(_ int, record []string) { cf.part.formatRecord(record) }
+
+func (cf *columnFormatter) finishPart(*partDesc) {}
@@ -0,0 +1,1211 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"io"
+	"io/fs"
+	"log"
+	"math/rand"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/pkg/errors"
+	"github.com/tgulacsi/go/text"
+)
+
+// Options of Convert; the zero value of a field means its default, except
+// for the booleans, and for MinFontSize and TranslatorCache, where it
+// disables the feature: start from DefaultOptions.
+//
+// The fields mirror the flags of the csv2pdf command, the comments give the
+// flag names.
+type Options struct {
+	// InputName is the name of the input file: the attachments and the
+	// schema are relative to it.
+	InputName string
+	// CommandLine is recorded by Provenance (default: os.Args).
+	CommandLine []string
+	// FS is the file system of the input files: InputName, Schema,
+	// Disclaimer and the attachments (default: the OS file system).
+	FS fs.FS
+
+	// Delimiter is -delimiter: the field separator of the csv (default:
+	// detected from the first lines, one of ; , tab |), see ParseDelimiter.
+	Delimiter rune
+	// PageSize is -page-size: page size: A3, A4, A5, Letter, Legal, or WxH in mm, or with a unit (cm, in or pt), as 4x6in (pdf).
+	PageSize string
+	// Colors are the colors of the tables (default: DefaultColors).
+	Colors *Colors
+	// CharWidth and HeaderCharWidth are the column width per character of
+	// the widest value and of the header, in mm (default: by the style).
+	// Without CharWidth the pdf columns are as wide as their widest values
+	// measured with the font.
+	CharWidth, HeaderCharWidth float64
+	// HeaderHeight and RowHeight are -header-height and -row-height: the
+	// heights of the header and the body rows, in mm; CellPadding is
+	// -cell-padding: the space between the cell borders and the text, in mm
+	// (pdf; default: by the style).
+	HeaderHeight, RowHeight, CellPadding float64
+
+	// Charset is -charset: input charset.
+	Charset string
+	// FontDir is -fontdir: font directory.
+	FontDir string
+	// Format is -format: output format: pdf, txt (fixed-width text), md (Markdown table) or xlsx.
+	Format string
+	// AlsoCSV is -also-csv: also write the rendered (transformed) rows as CSV to this file.
+	AlsoCSV string
+	// Preview is -preview: also render the first page as preview image to this file (.png, .jpg or .svg; needs pdftoppm, or pdftocairo for .svg, of poppler-utils on the PATH).
+	Preview string
+	// PreviewSize is -preview-size: preview size in pixels (longer side).
+	PreviewSize int
+	// FormColumns is -form-columns: comma-separated list of columns rendered as form fields to be completed on the printout or in a PDF viewer: "name" for a write-in box, "name:check" for a checkbox.
+	FormColumns string
+	// RotateHeaders is -rotate-headers: print the heads rotated by 90 or 45 degrees, so the many narrow columns of long heads fit in portrait (pdf).
+	RotateHeaders int
+	// VerticalColumns is -vertical-columns: comma separated list of the narrow columns to print rotated by 90° (pdf).
+	VerticalColumns string
+	// ColumnGroups is -column-groups: | separated groups of comma separated columns, as "id,name|q1,q2,q3|total", with heavier rules between the groups (pdf).
+	ColumnGroups string
+	// Heatmap is -heatmap: comma separated list of numeric columns to shade on a green-yellow-red scale between their minimum and maximum, or fixed "col=min:max" thresholds (pdf).
+	Heatmap string
+	// Icons is -icons: comma separated list of numeric columns with threshold icons: "col=dot:lo:hi" for a red/yellow/green dot, "col=arrow[:ref]" for a green up/red down arrow; append ":only" to print the icon instead of the value (pdf).
+	Icons string
+	// Outliers is -outliers: flag the outliers of the numeric columns by "z[:factor]" (z-score, default 3) or "iqr[:factor]" (interquartile range, default 1.5), printing them red and listing them on the -summary page (pdf).
+	Outliers string
+	// Quality is -quality: add a data-quality scorecard: empty cells, type consistency, malformed dates and duplicate keys per part (pdf).
+	Quality bool
+	// QualityKey is -quality-key: key column for the duplicate check of -quality (default: the first column).
+	QualityKey string
+	// Sample is -sample: render only a random sample of this many rows per part, labeled as such, with the statistics of all the rows.
+	Sample int
+	// Seed is -seed: random seed of the sampling features (-sample), for reproducible results in tests and audits (default: random, printed in the labels and the log).
+	Seed int64
+	// Head is -head: render only the first N rows of each part (with -tail), noting the number of the omitted rows.
+	Head int
+	// Tail is -tail: render only the last N rows of each part (with -head), noting the number of the omitted rows.
+	Tail int
+	// TransposeRows is -transpose-rows: the parts of at most this many rows, but more than twice as many columns, are printed transposed, a row per column and a column per record, noted above the table (not with Head and Tail; 0: never).
+	TransposeRows int
+	// OrderColumns is -order-columns: reorder the columns by their information content: identifying columns first, near-constant ones last, noting the constant values above the table.
+	OrderColumns bool
+	// CollapseConstant is -collapse-constant: leave out the columns with the same value in all rows, noting them once above the table ("Region: EU-West").
+	CollapseConstant bool
+	// Post is -post: post-processing pipeline of the PDF, as "step[:args];...": rotate:90, crop:box (mm), stamp:text[:desc], optimize, encrypt:user[:owner].
+	Post string
+	// Verify is -verify: read the generated PDF back, validating its structure, checking its page count and that it has text, failing on a corrupt output (pdf).
+	Verify bool
+	// PDFVersion is -pdf-version: PDF version written (1.3 - 1.7 or 2.0), e.g. 1.4 for legacy archives; it is an error if the document needs a newer version; 2.0 cannot be verified (default: as needed by the features used).
+	PDFVersion string
+	// Grayscale is -grayscale: map all the colors to gray (the table fills to light, the borders to dark levels printing well on black-and-white laser printers), and check that no color is left in the PDF, for print shops and archive profiles.
+	Grayscale bool
+	// Borders is -borders: the borders of the tables: grid (around every cell), columns (between the columns, the default), rows (rules under the rows), frame (around the table only) or none (pdf).
+	Borders string
+	// InkSaver is -ink-saver: print economy: thin rules under the header and the rows instead of the solid header and stripe fills (pdf).
+	InkSaver bool
+	// Receipt is -receipt: print the records for thermal receipt printers, as "head: value" lines on a roll of this width in mm (58 or 80): each part on one page as long as needed, or each record on its own page with "58:record" (pdf).
+	Receipt string
+	// Split is -split: write each part (column-count change) into its own file, named by this template from the part number, e.g. report-%02d.pdf; nothing is written to the standard output.
+	Split string
+	// FlushPages is -flush-pages: write the finished pages to the output every this many pages (at least 50, as each flush embeds the fonts again), bounding the memory use of long documents; the bookmarks are not kept (pdf; not with -summary, -toc-json or -index-column).
+	FlushPages int
+	// SkipRows is -skip-rows: skip this many lines at the start of the input (banner lines).
+	SkipRows int
+	// SkipBanner is -skip-banner: skip the lines without the delimiter at the start of the input (after -skip-rows): the titles above the table.
+	SkipBanner bool
+	// DateFormat is -date-format: Go time layout of the dates in the input, as 02.01.2006, to print them as 2006-01-02; or "in=out" to print them with the out layout.
+	DateFormat string
+	// Orientation is -orientation: page orientation: portrait, landscape or auto (by the width of the table); per part as 2=landscape, repeatable (pdf); the flags are joined by commas here.
+	Orientation string
+	// Background is -background: image (PNG, JPEG or GIF) printed under every page, stretched to the page: a rendered letterhead (pdf).
+	Background string
+	// HeaderImage is -header-image: image strip printed across the top of every page, the table starts below it (pdf).
+	HeaderImage string
+	// FooterImage is -footer-image: image strip printed across the bottom of every page, the table ends above it (pdf).
+	FooterImage string
+	// Stationery is -stationery: PDF whose first page is laid under every page, scaled to the page width: a strict corporate template (pdf).
+	Stationery string
+	// QR is -qr: text printed as a QR code in the bottom right corner of every page: the URL of the source data or the job ID, to trace the printed pages back (pdf).
+	QR string
+	// PageHMAC is -page-hmac: file with the key of the HMAC-SHA256 of the rows of each page, printed at the bottom of the page: a page printed again from the same input has the same code, a changed one another (pdf).
+	PageHMAC string
+	// SplitWide is -split-wide: print the tables wider than the page (even with the fonts shrunk) on sets of pages, the columns not fitting on the following "continued" pages (pdf).
+	SplitWide bool
+	// KeyColumns is -key-columns: the number of the first columns repeated on each page of a SplitWide page set.
+	KeyColumns int
+	// RepeatRight is -repeat-right: comma separated list of the key columns repeated at the right edge of the landscape tables, not to have to trace the long rows back to the left edge (pdf).
+	RepeatRight string
+	// MinFontSize is -min-font-size: the tables wider than the page are printed with smaller fonts, down to this body font size in points (pdf; 0 disables the shrinking, default 5).
+	MinFontSize float64
+	// MinColWidth is -min-col-width: the narrowest width of the measured columns in mm, so a short value under a long head is not squeezed (pdf; see Column.MinWidth).
+	MinColWidth float64
+	// RTL is -rtl: right to left tables, for Hebrew and Arabic: the first column on the right, the text aligned right (pdf; the right to left text is reordered and the Arabic shaped in any case, needs a UTF-8 font as -utf8-font).
+	RTL bool
+	// AgeColors is -age-colors: shade the rows by the age of the date in a column, as "due=14:30": yellow older than 14 days, red older than 30 days; append ":2006-01-02" to count the ages to that day instead of today (pdf).
+	AgeColors string
+	// Ruler is -ruler: print the row numbers in the left margin every Ruler rows (pdf).
+	Ruler int
+	// RulerLines is -ruler-lines: draw thin guide lines under the -ruler rows (pdf).
+	RulerLines bool
+	// blocks are the text blocks printed before the tables, by Document.
+	blocks []block
+	// carried are the totals carried from a Split file to the next one,
+	// carryOn is set if there is a next one.
+	carried *runningTotals
+	carryOn bool
+	// Columns are the settings of the columns (title, width, alignment, number format), as the columns list of the -config file.
+	Columns []Column
+	// AttachColumn is -attach-column: column with file paths or URLs to be embedded as attachments of the rows.
+	AttachColumn string
+	// Disclaimer is -disclaimer: file with the disclaimer/legal text to print in small print at the end of the document.
+	Disclaimer string
+	// DisclaimerEveryPage is -disclaimer-every-page: print the -disclaimer at the bottom of every page instead of at the end (pdf).
+	DisclaimerEveryPage bool
+	// Footer is -footer: footer line of every page, a comma separated list of page (the page number, as "Page 3 / 12"), date (of the generation) and file (the name of the input) (pdf).
+	Footer string
+	// HeaderTemplate and FooterTemplate are -header-tmpl and -footer-tmpl: Go text/template of the header and the footer line of every page, with the variables .Title, .File (the name of the input), .Date (of the generation), .Part (number), .PartTitle, .Page, .Pages (empty with FlushPages), .FirstRow and .LastRow (of the page), as "{{.Title}} - {{.Date}}" (pdf).
+	HeaderTemplate, FooterTemplate string
+	// Title is -title: title of the document, .Title of the HeaderTemplate and the FooterTemplate (pdf).
+	Title string
+	// Provenance is -provenance: print who/where/when/how generated the report on each page, and record the command line in the metadata (pdf).
+	Provenance bool
+	// FontFile is -font-file: TrueType (or TrueType-flavoured OpenType) font file to embed and use instead of Arial, as regular[,bold]; its license is recorded in the metadata (pdf).
+	FontFile string
+	// FontFamily is -font-family: family name of the -font-file (default: its file name); without it, the core font to use: Arial, Helvetica, Times or Courier (pdf).
+	FontFamily string
+	// UTF8Font is -utf8-font: embed the bundled DejaVu Sans Condensed instead of Arial, printing the runes out of the charset, too (pdf).
+	UTF8Font bool
+	// FallbackFonts is -fallback-fonts: comma separated list of TrueType fonts for the glyphs missing from the -font-file, tried in order (pdf).
+	FallbackFonts string
+	// CJKFont is -cjk-font: TrueType font with the Chinese, Japanese and Korean glyphs (e.g. Noto Sans CJK), added to the -fallback-fonts; with the bundled DejaVu without -font-file (pdf).
+	CJKFont string
+	// Symbols is -symbols: print the common symbols and emoji (✓ ✗ ⚠ ● ★ ...) with the ZapfDingbats font.
+	Symbols bool
+	// Ligatures is -ligatures: use the standard ligatures (fi, fl, ff, ffi, ffl) of the -font-file (pdf).
+	Ligatures bool
+	// ScriptMarkup is -script-markup: print ^{...} as superscript and _{...} as subscript (the Unicode super- and subscript characters are always printed so) (pdf).
+	ScriptMarkup bool
+	// EmbedAllGlyphs is -embed-all-glyphs: embed all the glyphs of the character map of the -font-file fonts, not just the used ones, for editing the text later (still a subset, without the original font program) (pdf).
+	EmbedAllGlyphs bool
+	// Summary is -summary: start with a summary page linking to the parts.
+	Summary bool
+	// TOCJSON is -toc-json: write the page numbers of the sections, parts and index entries to this JSON file, for external navigation (pdf).
+	TOCJSON string
+	// IndexColumn is -index-column: add an alphabetical index of the values of this column with their page numbers.
+	IndexColumn string
+	// RowNumbers is -row-numbers: prepend a row number column, numbering continuously across the parts.
+	RowNumbers bool
+	// TotalColumns is -total-columns: comma-separated list of numeric columns to be summed, with carried/brought forward lines at page breaks, and across the -split files.
+	TotalColumns string
+	// Compact is -compact: paper-saving layout: smaller margins, rows and fonts, without fills and borders (pdf).
+	Compact bool
+	// Truncate is -truncate: truncation of too long values: none, end, middle (keeps the start and the end, for long IDs) or wrap (into more lines, making the row higher); per column as "end,id=middle,notes=wrap" (pdf).
+	Truncate string
+	// SharedWidths is -shared-widths: use the same column widths for all the parts with identical headers (default: per-part widths).
+	SharedWidths bool
+	// Sort is -sort: sort the rows of each part by these columns: "col[:desc],...".
+	Sort string
+	// SortCollation is -sort-collation: comparison of the text values of -sort: "natural" (file2 < file10), a language tag (e.g. "hu") for its collation, or both as "natural,hu" (default: byte-wise).
+	SortCollation string
+	// MemLimit is -mem-limit: memory budget for sorting; above it the rows are spilled to temporary files.
+	MemLimit string
+	// Dialect is -dialect: keep the input dialect (delimiter, charset, skipped rows) in the input.dialect.json file next to the input: reuse reads the input as saved there (saving the detected dialect if there is none), review reports whether the detected dialect differs from the saved one and uses the saved one, force saves the detected (or given) dialect.
+	Dialect string
+	// TranslatorCache is -translator-cache: number of translated values cached (0 disables the cache, default 4096).
+	TranslatorCache int
+	// FastCSV is -fast-csv: use the fast CSV reader for large, well-formed files.
+	FastCSV bool
+	// DebugLayout is -debug-layout: write a layout trace (widths, page breaks, truncated/overflowing cells) to this file ("-" for stderr).
+	DebugLayout string
+	// PinLayout is -pin-layout: layout JSON file: use the layout (style, orientation, column widths) stored in it, or store the measured layout in it if it does not exist.
+	PinLayout string
+	// DebugGrid is -debug-grid: draw a mm grid and the margin boxes on every page (pdf).
+	DebugGrid bool
+	// HeaderDetect is -header-detect: also start a new part at rows looking like a header, even with the same column count: comma separated list of repeat (same as a previous header), alpha (all-alphabetic row where numbers were).
+	HeaderDetect string
+	// HeaderRegexp is -header-regexp: also start a new part at rows whose first column matches this regexp.
+	HeaderRegexp string
+	// ExpectSchema is -expect-schema: JSON file of the header and the column types of a previous run, compared to the input's before printing, the differences logged; saved if it does not exist.
+	ExpectSchema string
+	// ExpectSchemaAbort is -expect-schema-abort: abort with ErrSchemaChanged if the input differs from the ExpectSchema.
+	ExpectSchemaAbort bool
+	// Schema is -schema: Table Schema (datapackage.json, schema.json) or CSVW metadata describing the columns.
+	Schema string
+}
+
+// DefaultOptions returns the default Options.
+func DefaultOptions() Options {
+	colors := DefaultColors()
+	return Options{
+		PageSize:        "A4",
+		Colors:          &colors,
+		Charset:         "utf-8",
+		Format:          "pdf",
+		PreviewSize:     256,
+		Symbols:         true,
+		Truncate:        "none",
+		MemLimit:        "256M",
+		TranslatorCache: 4096,
+		MinFontSize:     5,
+	}
+}
+
+// setDefaults fills the zero fields with their defaults.
+func (opts *Options) setDefaults() {
+	def := DefaultOptions()
+	if opts.Charset == "" {
+		opts.Charset = def.Charset
+	}
+	if opts.PageSize == "" {
+		opts.PageSize = def.PageSize
+	}
+	if opts.Format == "" {
+		opts.Format = def.Format
+	}
+	if opts.PreviewSize == 0 {
+		opts.PreviewSize = def.PreviewSize
+	}
+	if opts.Truncate == "" {
+		opts.Truncate = def.Truncate
+	}
+	if opts.MemLimit == "" {
+		opts.MemLimit = def.MemLimit
+	}
+}
+
+// Convert reads the csv from r, and writes the rendered document to w.
+//
+// The input is read twice; if r is not an io.ReadSeeker, it is saved to a
+// temporary file first. The output is written to w only on success.
+func Convert(r io.Reader, w io.Writer, opts Options) error {
+	opts.setDefaults()
+	if err := opts.checkCapabilities(); err != nil {
+		return err
+	}
+	fontDir, pdfTranslator, closeFontDir, err := opts.prepareFonts()
+	if err != nil {
+		return err
+	}
+	defer closeFontDir()
+
+	// the input is read twice: save it if it cannot be rewound
+	var csvFile io.ReadSeeker
+	var start int64
+	if rs, ok := r.(io.ReadSeeker); ok {
+		if start, err = rs.Seek(0, io.SeekCurrent); err == nil {
+			csvFile = rs
+		}
+	}
+	if csvFile == nil {
+		fh, err := os.CreateTemp("", "csv2pdf-")
+		if err != nil {
+			return errors.Wrap(err, "creating tempfile")
+		}
+		defer os.Remove(fh.Name())
+		defer fh.Close()
+		if _, err = io.Copy(fh, r); err != nil {
+			return errors.Wrap(err, "saving csv")
+		}
+		if _, err = fh.Seek(0, io.SeekStart); err != nil {
+			return errors.Wrap(err, "seeking back on the input")
+		}
+		csvFile, start = fh, 0
+	}
+	csvFn := opts.InputName
+	var storedDialect *dialect
+	if opts.Dialect != "" {
+		switch opts.Dialect {
+		case dialectReuse, dialectReview, dialectForce:
+		default:
+			return errors.Errorf("unknown dialect mode %q (reuse, review or force)", opts.Dialect)
+		}
+		if csvFn == "" || opts.FS != nil {
+			return errors.New("dialect needs a named input file in the OS file system")
+		}
+		if storedDialect, err = loadDialect(dialectFile(csvFn)); err != nil {
+			return err
+		}
+		if storedDialect != nil && opts.Dialect == dialectReuse {
+			log.Printf("dialect of %q: %s", csvFn, storedDialect)
+			opts.applyDialect(*storedDialect)
+		}
+	}
+	comma := opts.Delimiter
+	if comma != 0 {
+		if err = checkDelimiter(comma); err != nil {
+			return err
+		}
+	} else {
+		head := make([]byte, 64<<10)
+		n, err := io.ReadFull(csvFile, head)
+		if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			return errors.Wrap(err, "reading csv")
+		}
+		comma = sniffDelimiter(skipHeadLines(head[:n], opts.SkipRows))
+		log.Printf("delimiter: %q", comma)
+		if _, err = csvFile.Seek(start, io.SeekStart); err != nil {
+			return errors.Wrap(err, "seeking back on the input")
+		}
+	}
+	if opts.Dialect != "" {
+		detected := opts.dialect(comma)
+		switch {
+		case storedDialect == nil || opts.Dialect == dialectForce:
+			if err = detected.save(dialectFile(csvFn)); err != nil {
+				return err
+			}
+			log.Printf("dialect of %q saved: %s", csvFn, detected)
+		case opts.Dialect == dialectReview:
+			if detected != *storedDialect {
+				log.Printf("the saved dialect of %q is %s, but now it is %s; using the saved one (-dialect=force replaces it)", csvFn, storedDialect, detected)
+			} else {
+				log.Printf("dialect of %q: %s, as saved", csvFn, storedDialect)
+			}
+			opts.applyDialect(*storedDialect)
+			comma = opts.Delimiter
+		}
+	}
+	encoding := text.GetEncoding(opts.Charset)
+	if encoding == nil {
+		return errors.Wrapf(ErrCharsetUnsupported, "%q", opts.Charset)
+	}
+	csDecoder := func(r io.Reader) io.Reader { return text.NewDecodingReader(r, encoding) }
+	var banner rune
+	if opts.SkipBanner {
+		banner = comma
+	}
+	var dateIn, dateOut string
+	if opts.DateFormat != "" {
+		if dateIn, dateOut, err = parseDateFormat(opts.DateFormat); err != nil {
+			return errors.Wrap(err, "parsing date format")
+		}
+	}
+	readRecords := func() recordReader {
+		cr := newRecordReader(skipLines(csDecoder(csvFile), opts.SkipRows, banner), comma, opts.FastCSV)
+		if dateIn != "" {
+			cr = dateReader{recordReader: cr, in: dateIn, out: dateOut}
+		}
+		return cr
+	}
+	headerDetect, err := newHeaderDetector(opts.HeaderDetect, opts.HeaderRegexp)
+	if err != nil {
+		return errors.Wrap(err, "parsing header detection")
+	}
+	var observers []partObserver
+	if len(opts.Columns) != 0 {
+		for _, c := range opts.Columns {
+			if err = c.check(); err != nil {
+				return err
+			}
+		}
+		observers = append(observers, &columnFormatter{settings: opts.Columns})
+	}
+	if opts.Heatmap != "" {
+		heat, err := parseHeatmap(opts.Heatmap)
+		if err != nil {
+			return errors.Wrapf(err, "parsing heatmap %q", opts.Heatmap)
+		}
+		observers = append(observers, &heatmapObserver{hm: heat})
+	}
+	if opts.Outliers != "" {
+		od, err := newOutlierDetector(opts.Outliers)
+		if err != nil {
+			return errors.Wrapf(err, "parsing outliers %q", opts.Outliers)
+		}
+		observers = append(observers, od)
+	}
+	if opts.Quality {
+		observers = append(observers, newQualityChecker(opts.QualityKey))
+	}
+	if opts.OrderColumns || opts.CollapseConstant {
+		observers = append(observers, &columnProfiler{})
+	}
+	var schemaRec *schemaRecorder
+	if opts.ExpectSchema != "" {
+		schemaRec = &schemaRecorder{}
+		observers = append(observers, schemaRec)
+	}
+	if opts.Format == "md" {
+		observers = append(observers, &markdownWidths{})
+	}
+	if opts.Format == "pdf" && opts.Receipt == "" && opts.CharWidth == 0 {
+		wm, err := opts.newWidthMeasurer(fontDir, pdfTranslator)
+		if err != nil {
+			return err
+		}
+		observers = append(observers, wm)
+	}
+	parts, err := parseCsv(readRecords(), comma, headerDetect, observers...)
+	if err != nil {
+		return errors.Wrapf(err, "parsing csv %q", csvFn)
+	}
+	if schemaRec != nil {
+		if err = schemaRec.checkSchema(opts.ExpectSchema, opts.ExpectSchemaAbort); err != nil {
+			return err
+		}
+	}
+	if opts.SharedWidths {
+		shareWidths(parts)
+	}
+	var schema *tableSchema
+	if opts.Schema != "" {
+		if schema, err = loadSchema(opts.readFile, opts.Schema, csvFn); err != nil {
+			return errors.Wrapf(err, "loading schema %q", opts.Schema)
+		}
+		for i := range parts {
+			schema.apply(&parts[i])
+		}
+		defer schema.report()
+	}
+	if err = opts.applyColumns(parts); err != nil {
+		return err
+	}
+	if _, err = csvFile.Seek(start, io.SeekStart); err != nil {
+		return errors.Wrap(err, "seeking back on the input")
+	}
+	cr := readRecords()
+
+	postSteps, err := parsePostSteps(opts.Post)
+	if err != nil {
+		return errors.Wrapf(err, "parsing post %q", opts.Post)
+	}
+	if opts.Stationery != "" {
+		step, cleanup, err := stationeryStep(opts.readFile, opts.Stationery, opts.FS != nil)
+		if err != nil {
+			return errors.Wrapf(err, "stationery %q", opts.Stationery)
+		}
+		defer cleanup()
+		// the table is printed on the template, then post-processed
+		postSteps = append([]postStep{step}, postSteps...)
+	}
+	if opts.Preview != "" {
+		// before the rendering, not to fail at its end
+		if _, _, err = previewCommand(opts.Preview, "", opts.PreviewSize); err != nil {
+			return err
+		}
+	}
+	if opts.Split != "" {
+		if opts.Preview != "" {
+			return errors.Errorf("Split and Preview are exclusive")
+		}
+		if err = checkSplitTemplate(opts.Split); err != nil {
+			return errors.Wrapf(err, "split")
+		}
+	}
+	if opts.PDFVersion != "" {
+		if err = checkPDFVersion(opts.PDFVersion); err != nil {
+			return err
+		}
+	}
+	disclaimer, err := opts.loadDisclaimer()
+	if err != nil {
+		return err
+	}
+
+	// the output is spooled, and written to w only on success
+	out, err := newSpool()
+	if err != nil {
+		return errors.Wrap(err, "creating output spool file")
+	}
+	defer out.Remove()
+	// pageRend is the renderer of the output, before wrapping
+	var rend, pageRend tableRenderer
+	if opts.Split != "" {
+		carried := newRunningTotals()
+		sr := &splitRenderer{
+			template: opts.Split,
+			newRenderer: func(w io.Writer, n int) (tableRenderer, func(), error) {
+				var part []partDesc
+				if n < len(parts) {
+					part = parts[n : n+1]
+				}
+				po := opts
+				// the totals run on across the files, as the row numbers
+				if opts.TotalColumns != "" {
+					po.carried, po.carryOn = carried, n+1 < len(parts)
+				}
+				// the n-th part is the first in its file
+				if o, err := parseOrientations(opts.Orientation); err == nil {
+					po.Orientation = o.spec(n + 1)
+				}
+				return po.newRenderer(w, fontDir, pdfTranslator, part, disclaimer)
+			},
+			finish: func(out *spoolFile, rend tableRenderer) error {
+				return opts.finishOutput(out, withFormFields(rend, postSteps), 0)
+			},
+		}
+		defer sr.abort()
+		rend = sr
+	} else {
+		var closeRend func()
+		if rend, closeRend, err = opts.newRenderer(out, fontDir, pdfTranslator, parts, disclaimer); err != nil {
+			return err
+		}
+		defer closeRend()
+		pageRend = rend
+	}
+	var alsoCsv *atomicFile
+	if opts.AlsoCSV != "" {
+		if alsoCsv, err = createAtomic(opts.AlsoCSV); err != nil {
+			return errors.Wrapf(err, "creating %q", opts.AlsoCSV)
+		}
+		defer alsoCsv.Abort()
+		rend = multiRenderer{rend, newCSVRenderer(alsoCsv, comma)}
+	}
+
+	if err = renderBlocks(rend, opts.blocks); err != nil {
+		return errors.Wrap(err, "rendering text")
+	}
+
+	var sortKeys []sortKey
+	if sortKeys, err = parseSortKeys(opts.Sort); err != nil {
+		return errors.Wrapf(err, "parsing sort %q", opts.Sort)
+	}
+	collation, err := newCollation(opts.SortCollation)
+	if err != nil {
+		return errors.Wrapf(err, "parsing sort collation %q", opts.SortCollation)
+	}
+	memLimit, err := parseSize(opts.MemLimit)
+	if err != nil {
+		return errors.Wrapf(err, "parsing mem limit %q", opts.MemLimit)
+	}
+
+	var ht *headTail
+	if opts.Head > 0 || opts.Tail > 0 {
+		if opts.Sample > 0 {
+			return errors.Errorf("Head/Tail and Sample are exclusive")
+		}
+		ht = &headTail{head: opts.Head, tail: opts.Tail}
+	}
+	seed := opts.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	if opts.Sample > 0 {
+		log.Printf("seed: %d", seed)
+	}
+	rnd := rand.New(rand.NewSource(seed))
+
+	n, rowNo := 0, 0
+	// order is the column order of the current part, if changed
+	var order []int
+	// formatted is the current part, its values formatted by the Columns
+	var formatted partDesc
+	// transposed collects the records of the current part, if transposed
+	var transposed *transposer
+	emit := func(record []string) error {
+		formatted.formatRecord(record)
+		if order != nil {
+			record = permute(record, order)
+		}
+		if opts.RowNumbers {
+			rowNo++
+			record = append([]string{strconv.Itoa(rowNo)}, record...)
+		}
+		if transposed != nil {
+			return transposed.Add(record)
+		}
+		return rend.Row(record)
+	}
+	if ht != nil {
+		emitAll := emit
+		emit = func(record []string) error {
+			keep, omitted := ht.next()
+			if omitted != 0 {
+				cols := len(record)
+				if opts.RowNumbers {
+					cols++
+				}
+				if err := renderNote(rend, omittedNote(omitted), cols); err != nil {
+					return err
+				}
+			}
+			if !keep {
+				rowNo++
+				return nil
+			}
+			return emitAll(record)
+		}
+	}
+	for _, part := range parts {
+		rendPart := part
+		formatted = part
+		if order = nil; opts.OrderColumns || opts.CollapseConstant {
+			if opts.OrderColumns {
+				order = part.entropyOrder()
+			}
+			if opts.CollapseConstant {
+				order = part.dropConstant(order)
+			}
+			if order != nil {
+				rendPart = part.reorder(order)
+			}
+			rendPart.caption = part.constantNote()
+		}
+		if opts.RowNumbers {
+			rendPart = rendPart.withRowNumbers()
+		}
+		log.Printf("head=%q, colwidths=%+v", rendPart.head, rendPart.widths)
+		if err = renderText(rend, part.intro); err != nil {
+			return errors.Wrap(err, "rendering text")
+		}
+		if ht != nil {
+			ht.part(part.lastLine - part.firstLine)
+		}
+		var sampler *rowSampler
+		if opts.Sample > 0 {
+			sampler = newRowSampler(part, opts.Sample, rnd)
+			rendPart.title = sampleTitle(part.title, opts.Sample, part.lastLine-part.firstLine, seed)
+		}
+		if transposed = nil; ht == nil && rendPart.transposable(opts.TransposeRows) {
+			transposed = &transposer{part: rendPart}
+		} else if err = rend.StartPart(rendPart); err != nil {
+			return errors.Wrap(err, "starting part")
+		}
+		for ; n < part.firstLine; n++ {
+			if _, err = cr.Read(); err != nil {
+				return errors.Wrapf(err, "reading head of %v", cr)
+			}
+		}
+		sorter := newRowSorter(part, sortKeys, collation, memLimit)
+		for ; n < part.lastLine; n++ {
+			record, err := cr.Read()
+			if err != nil {
+				if err == io.EOF {
+					break
+				}
+				return errors.Wrapf(err, "reading csv %v", cr)
+			}
+			if schema != nil {
+				schema.check(n+1, part, record)
+			}
+			if sampler != nil {
+				sampler.Add(n, record)
+			} else if sorter != nil {
+				err = sorter.Add(record)
+			} else {
+				err = emit(record)
+			}
+			if err != nil {
+				return errors.Wrapf(err, "rendering row %d", n+1)
+			}
+		}
+		if sampler != nil {
+			add := emit
+			if sorter != nil {
+				add = sorter.Add
+			}
+			if err = sampler.Each(add); err != nil {
+				return errors.Wrap(err, "rendering sampled rows")
+			}
+		}
+		if sorter != nil {
+			if err = sorter.Each(emit); err != nil {
+				return errors.Wrap(err, "rendering sorted rows")
+			}
+		}
+		if transposed != nil {
+			if err = transposed.render(rend); err != nil {
+				return errors.Wrap(err, "rendering transposed part")
+			}
+		}
+		if sampler != nil {
+			if err = renderText(rend, sampler.Stats(part.head)); err != nil {
+				return errors.Wrap(err, "rendering text")
+			}
+		}
+		if err = renderText(rend, part.outro); err != nil {
+			return errors.Wrap(err, "rendering text")
+		}
+	}
+	if disclaimer != "" && opts.Format != "pdf" {
+		if err = renderText(rend, strings.Split(disclaimer, "\n")); err != nil {
+			return errors.Wrap(err, "rendering disclaimer")
+		}
+	}
+	if err = rend.Close(); err != nil {
+		return errors.Wrap(err, "writing output")
+	}
+	if opts.Split == "" {
+		var pages int
+		if pc, ok := pageRend.(pageCounter); ok {
+			pages = pc.Pages()
+		}
+		if err = opts.finishOutput(out, withFormFields(pageRend, postSteps), pages); err != nil {
+			return err
+		}
+	}
+	if opts.Preview != "" {
+		if err = renderPreview(opts.Preview, out.Name(), opts.PreviewSize); err != nil {
+			return errors.Wrap(err, "rendering preview")
+		}
+	}
+	if alsoCsv != nil {
+		if err = alsoCsv.Commit(); err != nil {
+			return errors.Wrapf(err, "writing %q", opts.AlsoCSV)
+		}
+	}
+	if err = out.CopyTo(w); err != nil {
+		return errors.Wrap(err, "writing output")
+	}
+	return nil
+}
+
+// finishOutput runs the post-processing steps and the checks on the
+// complete document spooled in out, of pages pages (0 if unknown).
+func (opts Options) finishOutput(out *spoolFile, postSteps []postStep, pages int) error {
+	if err := postProcess(out, postSteps); err != nil {
+		return errors.Wrap(err, "post-processing")
+	}
+	if opts.Grayscale && opts.Format == "pdf" {
+		b, err := io.ReadAll(io.NewSectionReader(out.File, 0, 1<<62))
+		if err != nil {
+			return errors.Wrap(err, "reading output")
+		}
+		if err = checkGrayscale(b); err != nil {
+			return errors.Wrap(err, "checking grayscale")
+		}
+	}
+	// after the post-processing, which cannot read the PDF 2.0 documents
+	if opts.PDFVersion != "" {
+		if err := setPDFVersion(out, opts.PDFVersion); err != nil {
+			return errors.Wrap(err, "setting the PDF version")
+		}
+	}
+	if opts.Verify && opts.Receipt == "" {
+		fi, err := out.Stat()
+		if err != nil {
+			return errors.Wrap(err, "stating output")
+		}
+		if err = verifyPDF(io.NewSectionReader(out.File, 0, fi.Size()), pages); err != nil {
+			return errors.Wrap(err, "verifying output")
+		}
+	}
+	return nil
+}
+
+// ConvertFile converts the csv from r as Convert, writing the document to
+// the file dest: to a temporary file in its directory, renamed to dest on
+// success only, so a failed run never leaves a truncated file behind.
+func ConvertFile(r io.Reader, dest string, opts Options) error {
+	af, err := createAtomic(dest)
+	if err != nil {
+		return errors.Wrapf(err, "creating %q", dest)
+	}
+	defer af.Abort()
+	if err = Convert(r, af, opts); err != nil {
+		return err
+	}
+	return errors.Wrapf(af.Commit(), "writing %q", dest)
+}
+
+// ConvertFS converts the csv file name of fsys as Convert, reading the
+// other input files (Schema, Disclaimer, attachments) from fsys, too.
+func ConvertFS(fsys fs.FS, name string, w io.Writer, opts Options) error {
+	fh, err := fsys.Open(name)
+	if err != nil {
+		return errors.Wrapf(err, "opening %q", name)
+	}
+	defer fh.Close()
+	opts.FS, opts.InputName = fsys, name
+	return Convert(fh, w, opts)
+}
+
+// readFile reads the input file name from FS, or the OS file system.
+func (opts Options) readFile(name string) ([]byte, error) {
+	if opts.FS == nil {
+		return os.ReadFile(name)
+	}
+	return fs.ReadFile(opts.FS, path.Clean(filepath.ToSlash(name)))
+}
+
+// prepareFonts prepares the font dir, sets the FontFile of UTF8Font and the
+// FallbackFonts of CJKFont, and loads the translator of the core fonts.
+func (opts *Options) prepareFonts() (fontDir string, tr func(string) string, closeDir func() error, err error) {
+	if fontDir, closeDir, err = prepareFontDir(opts.FontDir); err != nil {
+		return "", nil, nil, errors.Wrapf(err, "preparing font dir %q", opts.FontDir)
+	}
+	if opts.CJKFont != "" {
+		// no CJK font is bundled (they are huge), it is the fallback of a UTF-8 font
+		if opts.FontFile == "" {
+			opts.UTF8Font = true
+		}
+		opts.FallbackFonts = strings.TrimPrefix(opts.FallbackFonts+","+opts.CJKFont, ",")
+	}
+	if opts.UTF8Font {
+		if opts.FontFile != "" {
+			closeDir()
+			return "", nil, nil, errors.Errorf("UTF8Font and FontFile are exclusive")
+		}
+		opts.FontFile = filepath.Join(fontDir, "DejaVuSansCondensed.ttf") + "," + filepath.Join(fontDir, "DejaVuSansCondensed-Bold.ttf")
+	}
+	if tr, err = opts.loadTranslator(fontDir); err != nil {
+		closeDir()
+		return "", nil, nil, err
+	}
+	return fontDir, tr, closeDir, nil
+}
+
+// loadTranslator loads the charset mapping of the PDF core fonts.
+func (opts Options) loadTranslator(fontDir string) (func(string) string, error) {
+	cs := opts.Charset
+	if cs == "utf-8" {
+		cs = "iso-8859-2"
+	}
+	fn := filepath.Join(fontDir, strings.ToLower(cs)+".map")
+	pdfTranslator, err := gofpdf.UnicodeTranslatorFromFile(fn)
+	if os.IsNotExist(err) {
+		return nil, errors.Wrapf(ErrCharsetUnsupported, "%q has no mapping for the PDF fonts", opts.Charset)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "loading charset mapping from %q", fn)
+	}
+	return cachedTranslator(pdfTranslator, opts.TranslatorCache), nil
+}
+
+// loadDisclaimer returns the text of the Disclaimer file, if any.
+func (opts Options) loadDisclaimer() (string, error) {
+	if opts.Disclaimer == "" {
+		return "", nil
+	}
+	b, err := opts.readFile(opts.Disclaimer)
+	if err != nil {
+		return "", errors.Wrapf(err, "reading disclaimer %q", opts.Disclaimer)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// applyColumns applies the per-column settings (forms, vertical, columns, groups, icons) to the parts.
+func (opts Options) applyColumns(parts []partDesc) error {
+	if opts.FormColumns != "" {
+		forms, err := parseFormColumns(opts.FormColumns)
+		if err != nil {
+			return errors.Wrapf(err, "parsing form columns %q", opts.FormColumns)
+		}
+		for i := range parts {
+			forms.apply(&parts[i])
+		}
+	}
+	if opts.VerticalColumns != "" {
+		vertical := parseVerticalColumns(opts.VerticalColumns)
+		for i := range parts {
+			vertical.apply(&parts[i])
+		}
+	}
+	if len(opts.Columns) != 0 {
+		for i := range parts {
+			columnSettings(opts.Columns).apply(&parts[i])
+		}
+	}
+	if opts.ColumnGroups != "" {
+		groups := parseColumnGroups(opts.ColumnGroups)
+		for i := range parts {
+			groups.apply(&parts[i])
+		}
+	}
+	if opts.Icons != "" {
+		icons, err := parseIconColumns(opts.Icons)
+		if err != nil {
+			return errors.Wrapf(err, "parsing icons %q", opts.Icons)
+		}
+		for i := range parts {
+			icons.apply(&parts[i])
+		}
+	}
+	return nil
+}
+
+// setFonts adds the fonts of the options to pr, and sets its font chain;
+// tr is the translator of the core fonts.
+func (opts Options) setFonts(pr *pdfRenderer, tr func(string) string) error {
+	if opts.FontFile != "" {
+		files := strings.SplitN(opts.FontFile, ",", 2)
+		family := opts.FontFamily
+		if family == "" {
+			family = strings.TrimSuffix(filepath.Base(files[0]), filepath.Ext(files[0]))
+		}
+		for i, fontStyle := range []string{"", "B"} {
+			fn := files[len(files)-1]
+			if i < len(files) {
+				fn = files[i]
+			}
+			b, err := os.ReadFile(fn)
+			if err != nil {
+				return errors.Wrapf(err, "reading font %q", fn)
+			}
+			if err = pr.addFontFile(family, fontStyle, b); err != nil {
+				return errors.Wrapf(err, "adding font %q", fn)
+			}
+		}
+		pr.font = family
+		pr.fallback = &fontChain{fonts: []chainFont{{family: family, has: pr.fonts[0].info.has}}}
+	} else if opts.FallbackFonts != "" {
+		return errors.Errorf("FallbackFonts needs FontFile")
+	} else if opts.FontFamily != "" {
+		if pr.font = coreFonts[strings.ToLower(opts.FontFamily)]; pr.font == "" {
+			return errors.Errorf("unknown core font %q (Arial, Helvetica, Times or Courier)", opts.FontFamily)
+		}
+	}
+	if opts.FallbackFonts != "" {
+		for _, fn := range strings.Split(opts.FallbackFonts, ",") {
+			b, err := os.ReadFile(fn)
+			if err != nil {
+				return errors.Wrapf(err, "reading font %q", fn)
+			}
+			family := strings.TrimSuffix(filepath.Base(fn), filepath.Ext(fn))
+			for _, fontStyle := range []string{"", "B"} {
+				if err = pr.addFontFile(family, fontStyle, b); err != nil {
+					return errors.Wrapf(err, "adding font %q", fn)
+				}
+			}
+			pr.fallback.fonts = append(pr.fallback.fonts, chainFont{family: family, has: pr.fonts[len(pr.fonts)-1].info.has})
+		}
+	}
+	if opts.Ligatures {
+		if opts.FontFile == "" {
+			return errors.Errorf("Ligatures needs FontFile")
+		}
+		pr.shaper = newShaper(pr.fonts[0].info.has)
+	}
+	if pr.fallback == nil {
+		has := func(rune) bool { return true }
+		if opts.Symbols {
+			has = func(r rune) bool { return !isDingbat(r) }
+		}
+		pr.fallback = &fontChain{fonts: []chainFont{{family: pr.font, has: has, encode: tr}}}
+	}
+	if opts.Symbols {
+		pr.fallback.fonts = append(pr.fallback.fonts, symbolFont)
+	}
+	pr.fallback.scriptMarkup = opts.ScriptMarkup
+	return nil
+}
+
+// newRenderer returns the renderer of opts.Format writing to out. parts are
+// the results of the pre-pass, for the summary pages.
+//
+// The returned func closes the files opened for the renderer.
+func (opts Options) newRenderer(out io.Writer, fontDir string, pdfTranslator func(string) string, parts []partDesc, disclaimer string) (rend tableRenderer, closeAll func(), err error) {
+	var closers []io.Closer
+	closeFiles := func() {
+		for _, c := range closers {
+			c.Close()
+		}
+	}
+	defer func() {
+		if err != nil {
+			closeFiles()
+		}
+	}()
+	switch opts.Format {
+	case "pdf":
+		if opts.Receipt != "" {
+			width, perRecord, err := parseReceipt(opts.Receipt)
+			if err != nil {
+				return nil, nil, errors.Wrapf(err, "parsing receipt %q", opts.Receipt)
+			}
+			return newReceiptRenderer(out, fontDir, pdfTranslator, width, perRecord), closeFiles, nil
+		}
+		style := defaultStyle
+		if opts.Compact {
+			style = compactStyle
+		}
+		var pinned *pageLayout
+		if opts.PinLayout != "" {
+			if pinned, err = loadLayout(opts.PinLayout); err != nil {
+				return nil, nil, errors.Wrapf(err, "reading layout %q", opts.PinLayout)
+			}
+			if pinned != nil {
+				style = pinned.Style
+			}
+		}
+		tr := pdfTranslator
+		if opts.FontFile != "" {
+			// UTF-8 fonts need no translation
+			tr = func(s string) string { return s }
+		}
+		if opts.CharWidth > 0 {
+			style.CharWidth = opts.CharWidth
+		}
+		if opts.HeaderCharWidth > 0 {
+			style.HeaderCharWidth = opts.HeaderCharWidth
+		}
+		for _, d := range []struct {
+			name  string
+			v     float64
+			style *float64
+		}{
+			{"header height", opts.HeaderHeight, &style.HeaderHeight},
+			{"row height", opts.RowHeight, &style.RowHeight},
+			{"cell padding", opts.CellPadding, &style.Padding},
+		} {
+			if d.v < 0 {
+				return nil, nil, errors.Errorf("%s %g: should not be negative", d.name, d.v)
+			}
+			if d.v > 0 {
+				*d.style = d.v
+			}
+		}
+		switch opts.RotateHeaders {
+		case 0, 45, 90:
+			style.HeaderAngle = float64(opts.RotateHeaders)
+		default:
+			return nil, nil, errors.Errorf("rotate headers %d: should be 90 or 45", opts.RotateHeaders)
+		}
+		if opts.InkSaver {
+			style = style.inkSaver()
+		}
+		if opts.Borders != "" {
+			if style, err = style.withBorders(opts.Borders); err != nil {
+				return nil, nil, err
+			}
+		}
+		pageSize, err := parsePageSize(opts.PageSize)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "parsing page size %q", opts.PageSize)
+		}
+		pr := newPDFRenderer(out, fontDir, tr, style, pageSize)
+		pr.compact = opts.Compact
+		if opts.Colors != nil {
+			pr.colors = *opts.Colors
+		}
+		if pr.grayscale = opts.Grayscale; pr.grayscale {
+			pr.colors = pr.colors.gray()
+		}
+		pr.embedAllGlyphs = opts.EmbedAllGlyphs
+		if opts.FlushPages > 0 {
+			// these need the page numbers or the catalog of the whole document
+			if opts.Summary || opts.TOCJSON != "" || opts.IndexColumn != "" || opts.SplitWide {
+				return nil, nil, errors.Errorf("FlushPages cannot be used with Summary, TOCJSON, IndexColumn or SplitWide")
+			}
+			if opts.FlushPages < minFlushPages {
+				return nil, nil, errors.Errorf("FlushPages %d is less than %d: each flush embeds the fonts again", opts.FlushPages, minFlushPages)
+			}
+			pr.flushPages = opts.FlushPages
+		}
+		if err = opts.setFonts(pr, tr); err != nil {
+			return nil, nil, err
+		}
+		if pr.orientation, err = parseOrientations(opts.Orientation); err != nil {
+			return nil, nil, errors.Wrapf(err, "parsing orientation %q", opts.Orientation)
+		}
+		if pr.pinned = pinned; pinned == nil {
+			pr.layoutFn = opts.PinLayout
+		}
+		pr.rtl = opts.RTL
+		pr.minFontSize = opts.MinFontSize
+		if pr.minColWidth = opts.MinColWidth; pr.minColWidth < 0 {
+			return nil, nil, errors.Errorf("min-col-width %g: should not be negative", opts.MinColWidth)
+		}
+		pr.splitWide, pr.keyColumns = opts.SplitWide, opts.KeyColumns
+		if opts.RepeatRight != "" {
+			pr.repeatRight = strings.Split(opts.RepeatRight, ",")
+		}
+		pr.attachColumn, pr.attachDir = opts.AttachColumn, filepath.Dir(opts.InputName)
+		pr.readFile = opts.readFile
+		pr.indexColumn = opts.IndexColumn
+		if pr.truncate, err = parseTruncSpec(opts.Truncate); err != nil {
+			return nil, nil, errors.Wrapf(err, "parsing truncate %q", opts.Truncate)
+		}
+		if opts.DebugLayout != "" {
+			var w io.Writer = os.Stderr
+			if opts.DebugLayout != "-" {
+				fh, err := os.Create(opts.DebugLayout)
+				if err != nil {
+					return nil, nil, errors.Wrapf(err, "creating %q", opts.DebugLayout)
+				}
+				closers = append(closers, fh)
+				w = fh
+			}
+			pr.trace = log.New(w, "layout: ", 0)
+		}
+		if err = pr.setStationery(opts.readFile, opts.Background, opts.HeaderImage, opts.FooterImage); err != nil {
+			return nil, nil, err
+		}
+		if opts.DebugGrid {
+			pr.pageHooks = append(pr.pageHooks, pr.drawDebugGrid)
+		}
+		if opts.TotalColumns != "" {
+			pr.totalColumns = strings.Split(opts.TotalColumns, ",")
+			pr.carried, pr.carryOn = opts.carried, opts.carryOn
+		}
+		if opts.FormColumns != "" {
+			pr.forms = newFormFields(func() int { return pr.flushed + pr.pdf.PageNo() })
+		}
+		if opts.AgeColors != "" {
+			var layout string
+			if opts.DateFormat != "" {
+				if _, layout, err = parseDateFormat(opts.DateFormat); err != nil {
+					return nil, nil, errors.Wrap(err, "parsing date format")
+				}
+			}
+			if pr.age, err = parseAgeRule(opts.AgeColors, layout); err != nil {
+				return nil, nil, errors.Wrapf(err, "parsing age colors %q", opts.AgeColors)
+			}
+		}
+		pr.ruler, pr.rulerLines = opts.Ruler, opts.RulerLines
+		if opts.TOCJSON != "" {
+			pr.toc, pr.tocFn = &tocDoc{}, opts.TOCJSON
+		}
+		if opts.Summary {
+			pr.addSummary(parts)
+		}
+		if opts.Quality {
+			pr.addQualityReport(parts)
+		}
+		if opts.Provenance {
+			args := opts.CommandLine
+			if args == nil {
+				args = os.Args
+			}
+			pr.setProvenance(args)
+		}
+		if opts.Footer != "" {
+			pf, err := parseFooter(opts.Footer, opts.InputName)
+			if err != nil {
+				return nil, nil, errors.Wrapf(err, "parsing footer %q", opts.Footer)
+			}
+			pr.setFooter(pf)
+		}
+		if opts.HeaderTemplate != "" || opts.FooterTemplate != "" {
+			pt, err := parsePageTemplates(opts.HeaderTemplate, opts.FooterTemplate, opts.Title, opts.InputName)
+			if err != nil {
+				return nil, nil, err
+			}
+			pr.setPageTemplates(pt)
+		}
+		if opts.PageHMAC != "" {
+			key, err := opts.readFile(opts.PageHMAC)
+			if err != nil {
+				return nil, nil, errors.Wrapf(err, "reading page HMAC key %q", opts.PageHMAC)
+			}
+			if err = pr.setPageMAC(key); err != nil {
+				return nil, nil, err
+			}
+		}
+		if opts.QR != "" {
+			if err = pr.setQR(opts.QR); err != nil {
+				return nil, nil, err
+			}
+		}
+		if disclaimer != "" {
+			pr.setDisclaimer(disclaimer, opts.DisclaimerEveryPage)
+		}
+		rend = pr
+	case "txt", "md":
+		rend = newTextRenderer(out, opts.Format == "md")
+	case "xlsx":
+		rend = newXLSXRenderer(out)
+	default:
+		return nil, nil, errors.Errorf("unknown format %q", opts.Format)
+	}
+	return rend, closeFiles, nil
+}
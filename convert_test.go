@@ -0,0 +1,479 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// rDocDate matches the creation and modification dates, which depend on
+// the time of the conversion.
+var rDocDate = regexp.MustCompile(`/(Creation|Mod)Date \([^)]*\)`)
+
+func convertSample(opts Options) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := Convert(strings.NewReader(selftestSample), &buf, opts); err != nil {
+		return nil, err
+	}
+	return rDocDate.ReplaceAll(buf.Bytes(), nil), nil
+}
+
+// TestConvertConcurrent checks that parallel conversions in one process
+// produce the same documents as a conversion alone (run it with -race).
+func TestConvertConcurrent(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	opts := DefaultOptions()
+	opts.Summary, opts.RowNumbers = true, true
+	want, err := convertSample(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const n = 8
+	var wg sync.WaitGroup
+	errs := make([]error, n)
+	docs := make([][]byte, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			docs[i], errs[i] = convertSample(opts)
+		}(i)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("%d: %+v", i, err)
+		} else if !bytes.Equal(docs[i], want) {
+			t.Errorf("%d: got a different document (%d bytes, want %d)", i, len(docs[i]), len(want))
+		}
+	}
+}
+
+// TestFormatParity checks that every renderer prints every value of the
+// sample, so switching -format never silently drops report content.
+func TestFormatParity(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	cr := csv.NewReader(strings.NewReader(selftestSample))
+	cr.Comma, cr.FieldsPerRecord = ';', -1
+	records, err := cr.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, format := range []string{"txt", "md", "xlsx"} {
+		opts := DefaultOptions()
+		opts.Format = format
+		doc, err := convertSample(opts)
+		if err != nil {
+			t.Errorf("%s: %+v", format, err)
+			continue
+		}
+		text := string(doc)
+		if format == "xlsx" {
+			if text, err = xlsxText(doc); err != nil {
+				t.Errorf("%s: %+v", format, err)
+				continue
+			}
+		}
+		for _, record := range records {
+			for _, v := range record {
+				if !strings.Contains(text, v) {
+					t.Errorf("%s: %q is missing", format, v)
+				}
+			}
+		}
+	}
+}
+
+// xlsxText returns the text of the worksheets of the xlsx document.
+func xlsxText(doc []byte) (string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(doc), int64(len(doc)))
+	if err != nil {
+		return "", err
+	}
+	var buf strings.Builder
+	for _, f := range zr.File {
+		if !strings.HasPrefix(f.Name, "xl/worksheets/") {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return "", err
+		}
+		b, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return "", err
+		}
+		buf.WriteString(html.UnescapeString(string(b)))
+	}
+	return buf.String(), nil
+}
+
+// TestXLSXText checks that the xlsx document has the text blocks and the
+// disclaimer, and that the pdf-only options are refused.
+func TestXLSXText(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	fsys := fstest.MapFS{
+		"in.csv":         {Data: []byte("#TEXT\nIntroduction\n#ENDTEXT\nid;name\n1;a\n")},
+		"disclaimer.txt": {Data: []byte("No warranty.")},
+	}
+	opts := DefaultOptions()
+	opts.Format, opts.Disclaimer = "xlsx", "disclaimer.txt"
+	var buf bytes.Buffer
+	if err := ConvertFS(fsys, "in.csv", &buf, opts); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	text, err := xlsxText(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Introduction", "No warranty."} {
+		if !strings.Contains(text, want) {
+			t.Errorf("%q is missing", want)
+		}
+	}
+	opts.Compact, opts.Title = true, "Report"
+	var ce *CapabilityError
+	if err = ConvertFS(fsys, "in.csv", io.Discard, opts); !errors.As(err, &ce) || len(ce.Unsupported) != 2 {
+		t.Errorf("got %v, wanted a capability error for compact and title", err)
+	}
+}
+
+// TestMarkdownPipes checks that the columns of the Markdown table are
+// aligned with the | escaped.
+func TestMarkdownPipes(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	opts := DefaultOptions()
+	opts.Format = "md"
+	var buf bytes.Buffer
+	if err := Convert(strings.NewReader("id;a|b\n1;x|y|z\n22;w\n"), &buf, opts); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	for _, line := range lines[1:] {
+		if len(line) != len(lines[0]) {
+			t.Errorf("%q is not as long as %q", line, lines[0])
+		}
+	}
+}
+
+// TestRecordReaderTSV checks that both readers keep the empty fields of
+// the tab separated lines.
+func TestRecordReaderTSV(t *testing.T) {
+	const input = "a\tb\tc\n1\t\t3\n \t x\t\n"
+	want := [][]string{{"a", "b", "c"}, {"1", "", "3"}, {" ", " x", ""}}
+	for _, fast := range []bool{false, true} {
+		rr := newRecordReader(strings.NewReader(input), '\t', fast)
+		for i, w := range want {
+			record, err := rr.Read()
+			if err != nil {
+				t.Fatalf("fast=%t %d: %+v", fast, i, err)
+			}
+			if strings.Join(record, "|") != strings.Join(w, "|") || len(record) != len(w) {
+				t.Errorf("fast=%t %d: got %q, wanted %q", fast, i, record, w)
+			}
+		}
+	}
+}
+
+// TestTextDelimiter checks that the text blocks and the sheet titles keep
+// the delimiter of the input.
+func TestTextDelimiter(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	const input = "### Sheet: Sales,Q1\n#TEXT\nHello,world\n#ENDTEXT\nid,name\n1,a\n"
+	opts := DefaultOptions()
+	opts.Format, opts.Delimiter = "md", ','
+	var buf bytes.Buffer
+	if err := Convert(strings.NewReader(input), &buf, opts); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	for _, want := range []string{"Sales,Q1", "Hello,world"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("%q is missing from %q", want, buf.String())
+		}
+	}
+}
+
+func TestParseColumn(t *testing.T) {
+	for _, tc := range []struct {
+		spec string
+		want Column
+	}{
+		{"3:width=30,align=right,title=Amount", Column{Name: "3", Width: 30, Align: "right", Title: "Amount"}},
+		{"notes:title=Notes, remarks", Column{Name: "notes", Title: "Notes, remarks"}},
+		{"amount:title=Amount,wrap", Column{Name: "amount", Title: "Amount", Truncate: "wrap"}},
+		{"amount:wrap,title=Amount, due", Column{Name: "amount", Title: "Amount, due", Truncate: "wrap"}},
+		{"3:width=30,align=right,title=Amount,bold", Column{Name: "3", Width: 30, Align: "right", Title: "Amount", Bold: true}},
+		{"3:title=Amount,italic,underline", Column{Name: "3", Title: "Amount", Italic: true, Underline: true}},
+	} {
+		got, err := ParseColumn(tc.spec)
+		if err != nil {
+			t.Errorf("%q: %+v", tc.spec, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%q: got %+v, wanted %+v", tc.spec, got, tc.want)
+		}
+	}
+	for _, spec := range []string{"a:width=x", "a:size=3", "a:align=top"} {
+		if _, err := ParseColumn(spec); err == nil {
+			t.Errorf("%q: no error", spec)
+		}
+	}
+}
+
+// TestVerifyPDF checks that verifyPDF accepts the document, but not with
+// another page count, nor truncated.
+func TestVerifyPDF(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	opts := DefaultOptions()
+	opts.Verify = true
+	doc, err := convertSample(opts)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if err = verifyPDF(bytes.NewReader(doc), 0); err != nil {
+		t.Errorf("verify: %+v", err)
+	}
+	if err = verifyPDF(bytes.NewReader(doc), 99); err == nil {
+		t.Error("verify accepted 99 pages")
+	}
+	if err = verifyPDF(bytes.NewReader(doc[:len(doc)/2]), 0); err == nil {
+		t.Error("verify accepted a truncated document")
+	}
+}
+
+// TestPDFVersion2 checks that the post-processing works with PDF 2.0, but
+// the verification is refused.
+func TestPDFVersion2(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	opts := DefaultOptions()
+	opts.PDFVersion, opts.Post = "2.0", "optimize"
+	doc, err := convertSample(opts)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if !bytes.HasPrefix(doc, []byte("%PDF-2.0")) {
+		t.Errorf("got header %q", doc[:8])
+	}
+	opts.Verify = true
+	var ce *CapabilityError
+	if _, err = convertSample(opts); !errors.As(err, &ce) || len(ce.Conflicts) != 1 {
+		t.Errorf("got %v, wanted a conflict of pdf-version and verify", err)
+	}
+}
+
+// TestAttachLimit checks that a too big attachment fails the conversion
+// with ErrResourceLimit.
+func TestAttachLimit(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.CopyN(w, zeroReader{}, maxAttachmentSize+1)
+	}))
+	defer srv.Close()
+	opts := DefaultOptions()
+	opts.AttachColumn = "file"
+	err := Convert(strings.NewReader("id;file\n1;"+srv.URL+"/big.bin\n"), io.Discard, opts)
+	if !errors.Is(err, ErrResourceLimit) {
+		t.Errorf("got %v, wanted ErrResourceLimit", err)
+	}
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+// TestWriterFonts checks that TableWriter embeds the UTF-8 font, as Convert.
+func TestWriterFonts(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	opts := DefaultOptions()
+	opts.UTF8Font = true
+	var buf bytes.Buffer
+	tw, err := NewWriter(&buf, []string{"id", "name"}, opts)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if err = tw.WriteRow([]string{"1", "Ελληνικά"}); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if err = tw.Close(); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("/BaseFont /utf8dejavusanscondensed")) {
+		t.Error("the UTF-8 font is not embedded")
+	}
+	opts = DefaultOptions()
+	opts.Format, opts.FormColumns = "txt", "name"
+	if _, err = NewWriter(io.Discard, []string{"id", "name"}, opts); err == nil {
+		t.Error("no capability error for form columns in txt")
+	}
+	opts = DefaultOptions()
+	opts.Sort, opts.Post = "name", "optimize"
+	var ce *CapabilityError
+	if _, err = NewWriter(io.Discard, []string{"id", "name"}, opts); !errors.As(err, &ce) || len(ce.Conflicts) != 2 {
+		t.Errorf("got %v, wanted a capability error for sort and post", err)
+	}
+}
+
+// TestFormFields checks that the form columns are written as AcroForm
+// fields, a checkbox and a text field per row.
+func TestFormFields(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	opts := DefaultOptions()
+	opts.FormColumns = "id:check,name"
+	doc, err := convertSample(opts)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	conf := model.NewDefaultConfiguration()
+	conf.ValidationMode = model.ValidationRelaxed
+	ctx, err := api.ReadContext(bytes.NewReader(doc), conf)
+	if err == nil {
+		err = api.ValidateContext(ctx)
+	}
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if ctx.AcroForm == nil {
+		t.Fatal("no AcroForm")
+	}
+	fields, err := ctx.DereferenceArray(ctx.AcroForm["Fields"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	kinds := make(map[string]int)
+	for _, o := range fields {
+		d, err := ctx.DereferenceDict(o)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ft := d.NameEntry("FT"); ft != nil {
+			kinds[*ft]++
+		}
+	}
+	if want := map[string]int{"Btn": 3, "Tx": 3}; len(kinds) != len(want) || kinds["Btn"] != want["Btn"] || kinds["Tx"] != want["Tx"] {
+		t.Errorf("got %v fields, wanted %v", kinds, want)
+	}
+}
+
+// TestDocumentBookmarks checks that the level 1 headings of all the tables
+// of a Document are bookmarked, in order.
+func TestDocumentBookmarks(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	d := NewDocument(DefaultOptions())
+	for _, title := range []string{"First", "Second", "Third"} {
+		if err := d.AddHeading(1, title); err != nil {
+			t.Fatal(err)
+		}
+		if err := d.AddTable(strings.NewReader(selftestSample)); err != nil {
+			t.Fatalf("%+v", err)
+		}
+	}
+	var buf bytes.Buffer
+	if _, err := d.WriteTo(&buf); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	conf := model.NewDefaultConfiguration()
+	conf.ValidationMode = model.ValidationRelaxed
+	ctx, err := api.ReadContext(bytes.NewReader(buf.Bytes()), conf)
+	if err == nil {
+		err = api.ValidateContext(ctx)
+	}
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	catalog, err := ctx.Catalog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := ctx.DereferenceDict(catalog["Outlines"])
+	if err != nil || root == nil {
+		t.Fatalf("no outlines: %v", err)
+	}
+	var titles []string
+	for o := root["First"]; o != nil; {
+		item, err := ctx.DereferenceDict(o)
+		if err != nil {
+			t.Fatal(err)
+		}
+		titles = append(titles, item["Title"].String())
+		o = item["Next"]
+	}
+	if got := strings.Join(titles, " "); got != "(First) (Second) (Third)" {
+		t.Errorf("got bookmarks %s", got)
+	}
+}
+
+// TestFlushPages checks that the flushed document has the pages of the
+// whole one, and that too frequent flushes are refused.
+func TestFlushPages(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	var input strings.Builder
+	input.WriteString("id;name\n")
+	for i := 0; i < 5000; i++ {
+		fmt.Fprintf(&input, "%d;name %d\n", i, i)
+	}
+	opts := DefaultOptions()
+	opts.FlushPages, opts.Verify = minFlushPages, true
+	var buf bytes.Buffer
+	if err := Convert(strings.NewReader(input.String()), &buf, opts); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	opts.FlushPages = minFlushPages - 1
+	if err := Convert(strings.NewReader(input.String()), io.Discard, opts); err == nil {
+		t.Errorf("FlushPages %d is accepted", opts.FlushPages)
+	}
+}
+
+// BenchmarkConvertParallel converts the sample in GOMAXPROCS goroutines.
+func BenchmarkConvertParallel(b *testing.B) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	opts := DefaultOptions()
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := convertSample(opts); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,398 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+// Package csv2pdf implements a csv -> PDF printer.
+//
+// The csv2pdf command (cmd/csv2pdf) is a thin wrapper around Convert.
+//
+// The conversions (Convert, ConvertFile, ConvertFS, NewWriter) are
+// independent, and can run concurrently in one process: each has its own
+// document and fonts, the embedded fonts are extracted to its own
+// temporary directory (unless Options.FontDir is set; that is only read).
+package csv2pdf
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+
+	_ "github.com/tgulacsi/csv2pdf/statik"
+	"github.com/tgulacsi/statik/fs"
+)
+
+//go:generate mkdir -p assets
+//go:generate zip -qjr9 assets/fontdir.zip font
+//go:generate go get github.com/tgulacsi/statik
+//go:generate statik -Z -f -src=./assets/
+
+func prepareFontDir(path string) (fontDir string, closeDir func() error, err error) {
+	fontDir = path
+	if fontDir != "" {
+		return fontDir, func() error { return nil }, nil
+	}
+
+	statikFS, e := fs.New()
+	if e != nil {
+		err = errors.Wrap(e, "statik")
+		return
+	}
+	fontZipData, e := fs.ReadFile(statikFS, "/fontdir.zip")
+	if e != nil {
+		err = errors.Wrap(e, "read fontdir.zip")
+		return
+	}
+	zr, e := zip.NewReader(bytes.NewReader(fontZipData), int64(len(fontZipData)))
+	if e != nil {
+		err = errors.Wrap(e, "opening zip")
+		return
+	}
+
+	if fontDir, err = os.MkdirTemp("", "csv2pdf-font-"); err != nil {
+		err = errors.Wrap(err, "create temp dir for fonts")
+		return
+	}
+	closeDir = func() error { return os.RemoveAll(fontDir) }
+	defer func() {
+		if err != nil && closeDir != nil {
+			closeDir()
+			closeDir = nil
+		}
+	}()
+
+	tokens := make(chan struct{}, 16)
+	var token struct{}
+	var grp errgroup.Group
+	for _, fi := range zr.File {
+		fi := fi
+		grp.Go(func() error {
+			tokens <- token
+			defer func() { <-tokens }()
+			src, err := fi.Open()
+			if err != nil {
+				log.Printf("error opening %q: %v", fi.Name, err)
+				return nil
+			}
+			defer src.Close()
+			dstFn := filepath.Join(fontDir, fi.Name)
+			dst, err := os.Create(dstFn)
+			if err != nil {
+				src.Close()
+				log.Printf("error creating %q: %v", dstFn, err)
+				return nil
+			}
+			defer dst.Close()
+			//log.Printf("copying %s to %s", fi.Name, dstFn)
+			if _, err = io.Copy(dst, src); err != nil {
+				log.Printf("error copying: %v", err)
+				return errors.Wrapf(err, "copy %q to %q", fi.Name, dstFn)
+			}
+			return dst.Close()
+		})
+	}
+	err = grp.Wait()
+	return
+}
+
+type partDesc struct {
+	firstLine, lastLine int
+	head                []string
+	widths              []int
+	// fontWidths are the widths of the widest values printed at font size
+	// 1, in mm, if measured; 0 for a column not measured.
+	fontWidths []float64
+	// aligns holds the CellFormat alignment per column, "" means the default.
+	aligns []string
+	// forms holds the form field kind per column, if any.
+	forms []formKind
+	// vertical marks the columns printed rotated, if any.
+	vertical []bool
+	// groups holds the column group of the columns, if grouped.
+	groups []int
+	// columns holds the settings of the columns, if any.
+	columns []*Column
+	// icons holds the icon rules per column, if any.
+	icons []*iconRule
+	// heat holds the value ranges of the columns shaded by their values.
+	heat []*numRange
+	// outliers holds the bounds of the values not flagged per numeric
+	// column, flagged lists the cells out of them.
+	outliers []*numRange
+	flagged  []outlierCell
+	// quality is the data-quality scorecard, if asked for.
+	quality *partQuality
+	// profile holds the entropy of the columns, if measured.
+	profile []columnProfile
+	// caption is a note printed once above the table.
+	caption string
+	// fields are the schema fields matched to the columns, nil if unknown.
+	fields []*schemaField
+	// title is the sheet name from the sheet marker before the part, if any.
+	title string
+	// intro is the text block before the table, outro is the one after it
+	// (set only for the last part); one paragraph per line.
+	intro, outro []string
+}
+
+// withRowNumbers returns a copy of the part with a row number column prepended.
+func (part partDesc) withRowNumbers() partDesc {
+	part.head = append([]string{"#"}, part.head...)
+	part.widths = append([]int{len(strconv.Itoa(part.lastLine))}, part.widths...)
+	if part.fontWidths != nil {
+		part.fontWidths = append([]float64{0}, part.fontWidths...)
+	}
+	part.aligns = append([]string{"R"}, part.aligns...)
+	if part.forms != nil {
+		part.forms = append([]formKind{formNone}, part.forms...)
+	}
+	if part.vertical != nil {
+		part.vertical = append([]bool{false}, part.vertical...)
+	}
+	if part.groups != nil {
+		part.groups = append([]int{0}, part.groups...)
+	}
+	if part.columns != nil {
+		part.columns = append([]*Column{nil}, part.columns...)
+	}
+	if part.icons != nil {
+		part.icons = append([]*iconRule{nil}, part.icons...)
+	}
+	if part.heat != nil {
+		part.heat = append([]*numRange{nil}, part.heat...)
+	}
+	if part.outliers != nil {
+		part.outliers = append([]*numRange{nil}, part.outliers...)
+	}
+	if part.profile != nil {
+		part.profile = append([]columnProfile{{}}, part.profile...)
+	}
+	if part.fields != nil {
+		part.fields = append([]*schemaField{nil}, part.fields...)
+	}
+	return part
+}
+
+// textWidth returns the width of the table in characters: the sum of the
+// widths of the values or the heads of the columns, whichever is wider.
+func (part partDesc) textWidth() int {
+	var width int
+	for i, h := range part.head {
+		if w := cellWidth(h); w > part.widths[i] {
+			width += w
+		} else {
+			width += part.widths[i]
+		}
+	}
+	return width
+}
+
+// columnIndex returns the index of the column with the given head or schema
+// field name, or -1 if not found.
+func (part partDesc) columnIndex(name string) int {
+	if name == "" {
+		return -1
+	}
+	for i, h := range part.head {
+		if strings.TrimSpace(h) == name || i < len(part.fields) && part.fields[i] != nil && part.fields[i].Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// parseCsv reads the records separated by comma, splitting them to parts at
+// column count changes, and where hd finds a header, feeding the parts to
+// the observers.
+func parseCsv(cr recordReader, comma rune, hd *headerDetector, observers ...partObserver) ([]partDesc, error) {
+	parts := make([]partDesc, 0, 1)
+	var part partDesc
+	var title string
+	var text []string
+	var inText bool
+	n := 0
+	for {
+		record, err := cr.Read()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		n++
+		if len(record) > MaxColumns {
+			return nil, errors.Wrapf(ErrTooManyColumns, "line %d has %d columns (at most %d)", n, len(record), MaxColumns)
+		}
+		t, isSheet := sheetTitle(record, comma)
+		if inText {
+			if inText = !isSheet && !isMarker(record, textEndMarker); inText {
+				text = append(text, strings.Join(record, string(comma)))
+				continue
+			}
+		}
+		if isSheet || isMarker(record, textMarker) {
+			if part.head != nil {
+				part.lastLine = n - 1
+				finishPart(observers, &part)
+				parts = append(parts, part)
+				part = partDesc{}
+			}
+			if isSheet {
+				title = t
+			} else {
+				inText = true
+			}
+			continue
+		}
+		if part.head == nil && isMarker(record, textEndMarker) {
+			continue
+		}
+		if part.head == nil || len(record) != len(part.head) || hd.isHeader(record) {
+			if part.head != nil {
+				if len(record) != len(part.head) {
+					log.Printf("new part with %d cols (previous part had %d)", len(record), len(part.head))
+				} else {
+					log.Printf("new part at line %d with header %q", n, record)
+				}
+				part.lastLine = n - 1
+				finishPart(observers, &part)
+				parts = append(parts, part)
+			}
+			part = partDesc{firstLine: n, title: title, intro: text, head: record, widths: make([]int, len(record))}
+			title, text = "", nil
+			hd.startPart(record)
+			for _, o := range observers {
+				o.startPart(record)
+			}
+			continue
+		}
+		hd.observe(record)
+		for _, o := range observers {
+			o.observe(n, record)
+		}
+		for i, v := range record {
+			if w := cellWidth(v); w > part.widths[i] {
+				part.widths[i] = w
+			}
+		}
+	}
+	if part.head != nil {
+		part.lastLine = n
+		finishPart(observers, &part)
+		parts = append(parts, part)
+	}
+	if len(parts) == 0 {
+		return nil, io.EOF
+	}
+	parts[len(parts)-1].outro = text
+
+	return parts, nil
+}
+
+// partObserver collects the statistics of the parts in the pre-pass.
+type partObserver interface {
+	// startPart starts a part with the head.
+	startPart(head []string)
+	// observe the data row at line.
+	observe(line int, record []string)
+	// finishPart stores the statistics of the finished part.
+	finishPart(part *partDesc)
+}
+
+func finishPart(observers []partObserver, part *partDesc) {
+	for _, o := range observers {
+		o.finishPart(part)
+	}
+}
+
+// renderText renders the text block, if rend supports it.
+func renderText(rend tableRenderer, text []string) error {
+	if pr, ok := rend.(paragraphRenderer); ok && len(text) != 0 {
+		return pr.Paragraphs(text)
+	}
+	return nil
+}
+
+// textMarker starts a block of free text, which lasts until textEndMarker.
+// Each line of the block is a paragraph; lines containing the delimiter
+// should be quoted, to keep the spaces after it.
+const textMarker, textEndMarker = "#TEXT", "#ENDTEXT"
+
+// isMarker reports whether the record is the marker, alone on its line.
+func isMarker(record []string, marker string) bool {
+	if len(record) == 0 || strings.TrimSpace(record[0]) != marker {
+		return false
+	}
+	for _, v := range record[1:] {
+		if v != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// sheetMarker starts a new sheet (part) in a CSV bundle: "### Sheet: Name".
+const sheetMarker = "### Sheet:"
+
+// sheetTitle returns the sheet name if the record (separated by comma) is a
+// sheet marker.
+func sheetTitle(record []string, comma rune) (string, bool) {
+	if len(record) == 0 || !strings.HasPrefix(record[0], sheetMarker) {
+		return "", false
+	}
+	// the name may contain the delimiter
+	title := strings.Join(record, string(comma))
+	return strings.TrimSpace(strings.TrimRight(title[len(sheetMarker):], string(comma)+" ")), true
+}
+
+// shareWidths sets the widths of the parts with identical heads
+// to the maximum of their widths.
+func shareWidths(parts []partDesc) {
+	byHead := make(map[string][]int)
+	fontByHead := make(map[string][]float64)
+	for _, part := range parts {
+		key := strings.Join(part.head, "\x00")
+		widths := byHead[key]
+		if widths == nil {
+			widths = make([]int, len(part.widths))
+			byHead[key] = widths
+		}
+		for i, w := range part.widths {
+			if w > widths[i] {
+				widths[i] = w
+			}
+		}
+		if part.fontWidths == nil {
+			continue
+		}
+		fontWidths := fontByHead[key]
+		if fontWidths == nil {
+			fontWidths = make([]float64, len(part.fontWidths))
+			fontByHead[key] = fontWidths
+		}
+		for i, w := range part.fontWidths {
+			fontWidths[i] = maxFloat(fontWidths[i], w)
+		}
+	}
+	for i, part := range parts {
+		key := strings.Join(part.head, "\x00")
+		parts[i].widths = byHead[key]
+		if part.fontWidths != nil {
+			parts[i].fontWidths = fontByHead[key]
+		}
+	}
+}
+
+func maxFloat(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,294 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"io"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// recordReader reads CSV records, as *csv.Reader does.
+type recordReader interface {
+	Read() ([]string, error)
+}
+
+// newRecordReader returns the CSV reader used for both passes:
+// encoding/csv, or the fastCSVReader if fast is set.
+func newRecordReader(r io.Reader, comma rune, fast bool) recordReader {
+	if fast {
+		return newFastCSVReader(r, comma)
+	}
+	cr := csv.NewReader(r)
+	cr.Comma = comma
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	// a whitespace delimiter would be trimmed as leading space, eating the
+	// empty fields
+	cr.TrimLeadingSpace = !unicode.IsSpace(comma)
+	return cr
+}
+
+// fastCSVReader is a CSV reader for large, well-formed files.
+//
+// Lines without quotes are simply split at the delimiter; lines with quotes
+// are scanned by hand (continuing on the next lines for quoted newlines),
+// with the lazy quote and leading space trimming rules of the encoding/csv
+// reader used otherwise.
+type fastCSVReader struct {
+	br    *bufio.Reader
+	comma string
+	// trim is the leading space trimmed from the fields, none with a
+	// whitespace delimiter
+	trim string
+	line []byte
+}
+
+func newFastCSVReader(r io.Reader, comma rune) *fastCSVReader {
+	fr := fastCSVReader{br: bufio.NewReaderSize(r, 1<<20), comma: string(comma), trim: " \t"}
+	if unicode.IsSpace(comma) {
+		fr.trim = ""
+	}
+	return &fr
+}
+
+// readLine returns the next line without the line ending,
+// which is valid till the next call.
+func (fr *fastCSVReader) readLine() ([]byte, error) {
+	line, err := fr.br.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		fr.line = append(fr.line[:0], line...)
+		for err == bufio.ErrBufferFull {
+			line, err = fr.br.ReadSlice('\n')
+			fr.line = append(fr.line, line...)
+		}
+		line = fr.line
+	}
+	if len(line) > 0 && err == io.EOF {
+		err = nil
+	}
+	if n := len(line); n > 0 && line[n-1] == '\n' {
+		line = line[:n-1]
+		if n > 1 && line[n-2] == '\r' {
+			line = line[:n-2]
+		}
+	}
+	return line, err
+}
+
+func (fr *fastCSVReader) Read() ([]string, error) {
+	var line []byte
+	var err error
+	// skip empty lines, as encoding/csv does
+	for len(line) == 0 {
+		if line, err = fr.readLine(); err != nil {
+			return nil, err
+		}
+	}
+	if bytes.IndexByte(line, '"') < 0 {
+		fields := strings.Split(string(line), fr.comma)
+		for i, f := range fields {
+			fields[i] = strings.TrimLeft(f, fr.trim)
+		}
+		return fields, nil
+	}
+	return fr.readQuoted(string(line))
+}
+
+// readQuoted scans the record starting with line, which contains quotes.
+func (fr *fastCSVReader) readQuoted(line string) ([]string, error) {
+	var fields []string
+	var field strings.Builder
+	for {
+		line = strings.TrimLeft(line, fr.trim)
+		if !strings.HasPrefix(line, `"`) {
+			// unquoted field: quotes are literal
+			i := strings.Index(line, fr.comma)
+			if i < 0 {
+				return append(fields, line), nil
+			}
+			fields = append(fields, line[:i])
+			line = line[i+len(fr.comma):]
+			continue
+		}
+
+		// quoted field
+		line = line[1:]
+		field.Reset()
+		for {
+			i := strings.IndexByte(line, '"')
+			if i < 0 {
+				// the quoted field continues on the next line
+				field.WriteString(line)
+				next, err := fr.readLine()
+				if err != nil {
+					if err == io.EOF {
+						return append(fields, field.String()), nil
+					}
+					return nil, err
+				}
+				field.WriteByte('\n')
+				line = string(next)
+				continue
+			}
+			field.WriteString(line[:i])
+			line = line[i+1:]
+			if strings.HasPrefix(line, `"`) { // escaped quote
+				field.WriteByte('"')
+				line = line[1:]
+				continue
+			}
+			if line == "" || strings.HasPrefix(line, fr.comma) {
+				break
+			}
+			// lazy quote: a quote in the middle of a quoted field is literal
+			field.WriteByte('"')
+		}
+		fields = append(fields, field.String())
+		if line == "" {
+			return fields, nil
+		}
+		line = line[len(fr.comma):]
+		if line == "" {
+			return append(fields, ""), nil
+		}
+	}
+}
+
+// ParseDelimiter parses a delimiter given as a literal character,
+// or as the \t escape (or "tab") for tab-separated files.
+func ParseDelimiter(s string) (rune, error) {
+	switch s {
+	case `\t`, "tab":
+		return '\t', nil
+	}
+	r, size := utf8.DecodeRuneInString(s)
+	if size == 0 || size != len(s) || r == utf8.RuneError {
+		return 0, errors.Wrapf(ErrBadDelimiter, "%q is not one character", s)
+	}
+	return r, checkDelimiter(r)
+}
+
+// checkDelimiter returns ErrBadDelimiter if r cannot be the delimiter.
+func checkDelimiter(r rune) error {
+	if r == '"' || r == '\r' || r == '\n' || r == utf8.RuneError || !utf8.ValidRune(r) {
+		return errors.Wrapf(ErrBadDelimiter, "%q cannot be the delimiter", r)
+	}
+	return nil
+}
+
+// delimiterCandidates are the delimiters sniffDelimiter chooses from,
+// the first is the default.
+var delimiterCandidates = []rune{';', ',', '\t', '|'}
+
+// sniffLines is the number of lines sniffDelimiter looks at.
+const sniffLines = 20
+
+// sniffDelimiter returns the delimiter splitting the first lines of head
+// into the same number of fields most consistently: the one with the most
+// lines having the most common non-zero count, then with more fields.
+// The delimiters in quoted fields are not counted.
+func sniffDelimiter(head []byte) rune {
+	lines := bytes.SplitN(head, []byte("\n"), sniffLines+1)
+	if len(lines) > sniffLines {
+		lines = lines[:sniffLines]
+	}
+	best, bestLines, bestCount := delimiterCandidates[0], 0, 0
+	for _, d := range delimiterCandidates {
+		freq := make(map[int]int)
+		for _, line := range lines {
+			var n int
+			var quoted bool
+			for _, c := range string(line) {
+				switch c {
+				case '"':
+					quoted = !quoted
+				case d:
+					if !quoted {
+						n++
+					}
+				}
+			}
+			if n != 0 {
+				freq[n]++
+			}
+		}
+		for count, k := range freq {
+			if k > bestLines || k == bestLines && count > bestCount {
+				best, bestLines, bestCount = d, k, count
+			}
+		}
+	}
+	return best
+}
+
+// skipLines returns r after its first n lines and, with banner, after the
+// following lines without the banner delimiter: the titles and empty
+// lines above the table of the ERP exports.
+func skipLines(r io.Reader, n int, banner rune) io.Reader {
+	if n <= 0 && banner == 0 {
+		return r
+	}
+	br := bufio.NewReader(r)
+	for i := 0; ; i++ {
+		line, err := br.ReadBytes('\n')
+		if err != nil || i >= n && (banner == 0 || bytes.ContainsRune(line, banner)) {
+			return io.MultiReader(bytes.NewReader(line), br)
+		}
+	}
+}
+
+// skipHeadLines returns head after its first n lines.
+func skipHeadLines(head []byte, n int) []byte {
+	for ; n > 0; n-- {
+		i := bytes.IndexByte(head, '\n')
+		if i < 0 {
+			return nil
+		}
+		head = head[i+1:]
+	}
+	return head
+}
+
+// dateReader prints the dates of the records in the in layout with the out layout.
+type dateReader struct {
+	recordReader
+	in, out string
+}
+
+// parseDateFormat parses the "in[=out]" Go time layouts of DateFormat, out
+// defaults to 2006-01-02.
+func parseDateFormat(spec string) (in, out string, err error) {
+	in, out, _ = strings.Cut(spec, "=")
+	if out == "" {
+		out = "2006-01-02"
+	}
+	ref := time.Date(2001, 11, 23, 13, 14, 15, 0, time.UTC)
+	for _, layout := range []string{in, out} {
+		if s := ref.Format(layout); s == layout {
+			return "", "", errors.Errorf("%q is not a time layout (as 02.01.2006)", layout)
+		} else if _, err = time.Parse(layout, s); err != nil {
+			return "", "", errors.Wrapf(err, "%q", layout)
+		}
+	}
+	return in, out, nil
+}
+
+func (dr dateReader) Read() ([]string, error) {
+	record, err := dr.recordReader.Read()
+	for i, v := range record {
+		if t, err := time.Parse(dr.in, strings.TrimSpace(v)); err == nil {
+			record[i] = t.Format(dr.out)
+		}
+	}
+	return record, err
+}
@@ -0,0 +1,82 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// dialect is the way an input is to be read, saved next to it (in the
+// sidecar file input.dialect.json), so the next runs read it the same way,
+// without sniffing again.
+type dialect struct {
+	Delimiter  string `json:"delimiter"`
+	Charset    string `json:"charset"`
+	SkipRows   int    `json:"skipRows,omitempty"`
+	SkipBanner bool   `json:"skipBanner,omitempty"`
+}
+
+// The Dialect modes.
+const (
+	dialectReuse  = "reuse"
+	dialectReview = "review"
+	dialectForce  = "force"
+)
+
+// dialectFile returns the name of the dialect sidecar of input.
+func dialectFile(input string) string { return input + ".dialect.json" }
+
+func (d dialect) String() string {
+	s := fmt.Sprintf("delimiter %q, charset %s", d.Delimiter, d.Charset)
+	if d.SkipRows != 0 {
+		s += fmt.Sprintf(", skipping %d rows", d.SkipRows)
+	}
+	if d.SkipBanner {
+		s += ", skipping the banner"
+	}
+	return s
+}
+
+// loadDialect loads the dialect file fn; returns nil if it does not exist.
+func loadDialect(fn string) (*dialect, error) {
+	b, err := os.ReadFile(fn)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "reading dialect %q", fn)
+	}
+	var d dialect
+	if err = json.Unmarshal(b, &d); err != nil {
+		return nil, errors.Wrapf(err, "parsing dialect %q", fn)
+	}
+	if _, err = ParseDelimiter(d.Delimiter); err != nil {
+		return nil, errors.Wrapf(err, "dialect %q", fn)
+	}
+	return &d, nil
+}
+
+func (d dialect) save(fn string) error {
+	b, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return err
+	}
+	return errors.Wrapf(os.WriteFile(fn, append(b, '\n'), 0644), "writing dialect %q", fn)
+}
+
+// dialect returns the dialect of the options, with the delimiter comma.
+func (opts *Options) dialect(comma rune) dialect {
+	return dialect{Delimiter: string(comma), Charset: opts.Charset, SkipRows: opts.SkipRows, SkipBanner: opts.SkipBanner}
+}
+
+// applyDialect sets the input options of d.
+func (opts *Options) applyDialect(d dialect) {
+	opts.Delimiter, _ = ParseDelimiter(d.Delimiter)
+	opts.Charset, opts.SkipRows, opts.SkipBanner = d.Charset, d.SkipRows, d.SkipBanner
+}
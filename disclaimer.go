@@ -0,0 +1,58 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+// disclaimerFontSize is the font size of the small print.
+const disclaimerFontSize = 5.5
+
+// setDisclaimer sets the legal text printed in small print at the end of the
+// document, or in the bottom margin of every page if everyPage is set.
+func (pr *pdfRenderer) setDisclaimer(text string, everyPage bool) {
+	pr.disclaimer = pr.translator(text)
+	if everyPage {
+		pr.footerHooks = append(pr.footerHooks, pr.drawDisclaimerFooter)
+	}
+	pr.disclaimerAtEnd = !everyPage
+}
+
+// drawDisclaimerFooter prints the disclaimer in the bottom margin,
+// pushing it up if it does not fit.
+func (pr *pdfRenderer) drawDisclaimerFooter() {
+	pdf := pr.pdf
+	w, h := pdf.GetPageSize()
+	lm, _, rm, _ := pdf.GetMargins()
+	_, bm := pdf.GetAutoPageBreak()
+	if pr.qr != nil {
+		// left of the QR code
+		rm += qrSize + 2
+	}
+	pdf.SetFont(pr.font, "", disclaimerFontSize)
+	lineHt := pdf.PointConvert(disclaimerFontSize) * 1.2
+	n := len(pdf.SplitLines([]byte(pr.disclaimer), w-lm-rm))
+	y := h - bm + 1
+	// leave room for the provenance and the footer lines
+	bottom := h - 4
+	if pr.footer != nil {
+		bottom = h - pageFooterTop
+	}
+	if y+float64(n)*lineHt > bottom {
+		y = bottom - float64(n)*lineHt
+	}
+	pdf.SetXY(lm, y)
+	pdf.SetTextColor(64, 64, 64)
+	pdf.MultiCell(w-lm-rm, lineHt, pr.disclaimer, "", "L", false)
+	pdf.SetTextColor(0, 0, 0)
+}
+
+// drawDisclaimer prints the disclaimer after the last content.
+func (pr *pdfRenderer) drawDisclaimer() {
+	pdf := pr.pdf
+	pdf.SetFont(pr.font, "", disclaimerFontSize)
+	lineHt := pdf.PointConvert(disclaimerFontSize) * 1.2
+	pdf.Ln(2 * lineHt)
+	pdf.SetTextColor(64, 64, 64)
+	pdf.MultiCell(0, lineHt, pr.disclaimer, "T", "L", false)
+	pdf.SetTextColor(0, 0, 0)
+}
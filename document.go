@@ -0,0 +1,156 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// Document builds one document of more tables and text blocks (headings,
+// paragraphs, spacers and rules), rendered with the same options and style
+// as they are added, and joined by WriteTo.
+//
+// In a pdf document the text blocks are followed by the next table on the
+// same page if it fits; each table starts on a new page otherwise.
+type Document struct {
+	opts Options
+	docs [][]byte
+	// pending are the blocks printed before the next table.
+	pending []block
+}
+
+// NewDocument returns an empty document with the options.
+func NewDocument(opts Options) *Document {
+	opts.setDefaults()
+	return &Document{opts: opts}
+}
+
+// check returns an error for the options working on a whole output.
+func (d *Document) check() error {
+	opts := d.opts
+	if opts.Format == "xlsx" {
+		return errors.New("Document cannot join xlsx workbooks")
+	}
+	if opts.Split != "" || opts.Preview != "" || opts.Post != "" || opts.Stationery != "" ||
+		opts.FlushPages > 0 || opts.Disclaimer != "" || opts.AlsoCSV != "" {
+		return errors.New("Document does not support Split, Preview, Post, Stationery, FlushPages, Disclaimer and AlsoCSV")
+	}
+	return nil
+}
+
+// AddTable renders the csv read from r, as Convert, after the text blocks
+// added since the previous table.
+func (d *Document) AddTable(r io.Reader) error {
+	if err := d.check(); err != nil {
+		return err
+	}
+	opts := d.opts
+	opts.blocks = d.pending
+	var buf bytes.Buffer
+	if err := Convert(r, &buf, opts); err != nil {
+		return err
+	}
+	d.docs, d.pending = append(d.docs, buf.Bytes()), nil
+	return nil
+}
+
+// AddHeading adds a heading of level 1 or 2; the level 1 headings are
+// bookmarked in a pdf document.
+func (d *Document) AddHeading(level int, text string) error {
+	kind := blockHeading1
+	switch level {
+	case 1:
+	case 2:
+		kind = blockHeading2
+	default:
+		return errors.Errorf("heading level %d (1 or 2)", level)
+	}
+	d.pending = append(d.pending, block{kind: kind, text: text})
+	return nil
+}
+
+// AddText adds the paragraphs of text.
+func (d *Document) AddText(text ...string) {
+	for _, p := range text {
+		d.pending = append(d.pending, block{kind: blockParagraph, text: p})
+	}
+}
+
+// AddSpacer adds a vertical space of height mm (a blank line in text).
+func (d *Document) AddSpacer(height float64) {
+	d.pending = append(d.pending, block{kind: blockSpacer, height: height})
+}
+
+// AddRule adds a horizontal rule across the page.
+func (d *Document) AddRule() {
+	d.pending = append(d.pending, block{kind: blockRule})
+}
+
+// renderBlocks renders the blocks alone.
+func (d *Document) renderBlocks(blocks []block) ([]byte, error) {
+	if err := d.check(); err != nil {
+		return nil, err
+	}
+	opts := d.opts
+	opts.Summary, opts.Quality = false, false
+	if err := opts.checkCapabilities(); err != nil {
+		return nil, err
+	}
+	fontDir, tr, closeFontDir, err := opts.prepareFonts()
+	if err != nil {
+		return nil, err
+	}
+	defer closeFontDir()
+	var buf bytes.Buffer
+	rend, closeRend, err := opts.newRenderer(&buf, fontDir, tr, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	defer closeRend()
+	if err = renderBlocks(rend, blocks); err != nil {
+		return nil, errors.Wrap(err, "rendering text")
+	}
+	if err = rend.Close(); err != nil {
+		return nil, errors.Wrap(err, "writing output")
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteTo writes the document joined from the added tables and text blocks
+// to w.
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	docs := d.docs
+	if len(d.pending) != 0 {
+		doc, err := d.renderBlocks(d.pending)
+		if err != nil {
+			return 0, err
+		}
+		docs = append(docs[:len(docs):len(docs)], doc)
+	}
+	if len(docs) == 0 {
+		return 0, errors.New("empty document")
+	}
+	if d.opts.Format != "pdf" {
+		var n int64
+		for _, doc := range docs {
+			k, err := w.Write(doc)
+			if n += int64(k); err != nil {
+				return n, err
+			}
+		}
+		return n, nil
+	}
+	pc := newPDFConcat(w)
+	for _, doc := range docs {
+		if err := pc.add(doc); err != nil {
+			return pc.pos, err
+		}
+	}
+	err := pc.close()
+	return pc.pos, err
+}
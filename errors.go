@@ -0,0 +1,30 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import "github.com/pkg/errors"
+
+// The categories of the errors returned, to be tested with errors.Is.
+var (
+	// ErrBadDelimiter is returned for a delimiter which cannot separate the
+	// fields.
+	ErrBadDelimiter = errors.New("bad delimiter")
+	// ErrCharsetUnsupported is returned for an unknown input charset, or one
+	// without a mapping for the PDF core fonts.
+	ErrCharsetUnsupported = errors.New("unsupported charset")
+	// ErrTooManyColumns is returned for a record of more than MaxColumns
+	// fields.
+	ErrTooManyColumns = errors.New("too many columns")
+	// ErrSchemaChanged is returned if the header or the column types
+	// differ from the -expect-schema, with ExpectSchemaAbort.
+	ErrSchemaChanged = errors.New("schema changed")
+	// ErrResourceLimit is returned for an input over its size limit, as a
+	// downloaded attachment.
+	ErrResourceLimit = errors.New("resource limit exceeded")
+)
+
+// MaxColumns is the maximal number of the columns of a record: more are
+// the sign of a wrong delimiter or a binary input.
+const MaxColumns = 4096
@@ -0,0 +1,158 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// expectedSchema is the header and the kinds of the columns of the parts of
+// a previous run (-expect-schema), to catch the export format changing
+// upstream.
+type expectedSchema struct {
+	Parts []expectedPart `json:"parts"`
+}
+
+type expectedPart struct {
+	Fields []expectedField `json:"fields"`
+}
+
+// expectedField is a column: its head and the kind (number, date or text)
+// of most of its values; empty for the empty columns.
+type expectedField struct {
+	Name string `json:"name"`
+	Type string `json:"type,omitempty"`
+}
+
+// schemaRecorder records the expectedSchema of the input in the pre-pass.
+type schemaRecorder struct {
+	schema  expectedSchema
+	columns []columnQuality
+}
+
+func (sr *schemaRecorder) startPart(head []string) {
+	fields := make([]expectedField, len(head))
+	for i, h := range head {
+		fields[i].Name = strings.TrimSpace(h)
+	}
+	sr.schema.Parts = append(sr.schema.Parts, expectedPart{Fields: fields})
+	sr.columns = make([]columnQuality, len(head))
+	for i := range sr.columns {
+		sr.columns[i].Kinds = make(map[string]int)
+	}
+}
+
+func (sr *schemaRecorder) observe(_ int, record []string) {
+	for i, v := range record {
+		if i >= len(sr.columns) {
+			break
+		}
+		if kind, _ := valueKind(v); kind != "" {
+			sr.columns[i].Kinds[kind]++
+		}
+	}
+}
+
+func (sr *schemaRecorder) finishPart(*partDesc) {
+	fields := sr.schema.Parts[len(sr.schema.Parts)-1].Fields
+	for i, c := range sr.columns {
+		fields[i].Type, _ = c.dominant()
+	}
+	sr.columns = nil
+}
+
+// loadExpectedSchema reads the schema from fn; nil if it does not exist.
+func loadExpectedSchema(fn string) (*expectedSchema, error) {
+	b, err := os.ReadFile(fn)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var es expectedSchema
+	if err = json.Unmarshal(b, &es); err != nil {
+		return nil, errors.Wrap(err, fn)
+	}
+	return &es, nil
+}
+
+func (es expectedSchema) save(fn string) error {
+	b, err := json.MarshalIndent(es, "", "  ")
+	if err != nil {
+		return err
+	}
+	af, err := createAtomic(fn)
+	if err != nil {
+		return err
+	}
+	defer af.Abort()
+	if _, err = af.Write(b); err != nil {
+		return err
+	}
+	return af.Commit()
+}
+
+// diff returns the differences of the schema from the expected one.
+func (es expectedSchema) diff(expected expectedSchema) []string {
+	var diffs []string
+	if len(es.Parts) != len(expected.Parts) {
+		diffs = append(diffs, fmt.Sprintf("%d parts instead of %d", len(es.Parts), len(expected.Parts)))
+	}
+	for k := 0; k < len(es.Parts) && k < len(expected.Parts); k++ {
+		have, want := es.Parts[k].Fields, expected.Parts[k].Fields
+		prefix := fmt.Sprintf("part %d: ", k+1)
+		if len(have) != len(want) {
+			diffs = append(diffs, fmt.Sprintf("%s%d columns instead of %d", prefix, len(have), len(want)))
+		}
+		for i := 0; i < len(have) || i < len(want); i++ {
+			switch {
+			case i >= len(want):
+				diffs = append(diffs, fmt.Sprintf("%scolumn %d %q is new", prefix, i+1, have[i].Name))
+			case i >= len(have):
+				diffs = append(diffs, fmt.Sprintf("%scolumn %d %q is missing", prefix, i+1, want[i].Name))
+			case have[i].Name != want[i].Name:
+				diffs = append(diffs, fmt.Sprintf("%scolumn %d is %q instead of %q", prefix, i+1, have[i].Name, want[i].Name))
+			case have[i].Type != want[i].Type && have[i].Type != "" && want[i].Type != "":
+				diffs = append(diffs, fmt.Sprintf("%scolumn %d %q is %s instead of %s", prefix, i+1, have[i].Name, have[i].Type, want[i].Type))
+			}
+		}
+	}
+	return diffs
+}
+
+// checkSchema compares the recorded schema to the one stored in fn, saving
+// it there if there is none. The differences are logged; with abort, they
+// are returned as ErrSchemaChanged.
+func (sr *schemaRecorder) checkSchema(fn string, abort bool) error {
+	expected, err := loadExpectedSchema(fn)
+	if err != nil {
+		return errors.Wrapf(err, "loading expected schema %q", fn)
+	}
+	if expected == nil {
+		if err = sr.schema.save(fn); err != nil {
+			return errors.Wrapf(err, "saving expected schema %q", fn)
+		}
+		log.Printf("schema saved to %q", fn)
+		return nil
+	}
+	diffs := sr.schema.diff(*expected)
+	if len(diffs) == 0 {
+		return nil
+	}
+	for _, d := range diffs {
+		log.Printf("schema of %q changed: %s", fn, d)
+	}
+	if abort {
+		return errors.Wrapf(ErrSchemaChanged, "%s (remove %q to accept the new schema)", strings.Join(diffs, "; "), fn)
+	}
+	return nil
+}
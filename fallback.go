@@ -0,0 +1,162 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"sort"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// fontChain is the primary font and its fallbacks: each rune is printed with
+// the first font of the chain which has a glyph for it.
+//
+// The chain also prints the super- and subscripts shifted.
+type fontChain struct {
+	fonts []chainFont
+	// scriptMarkup enables the "^{...}" and "_{...}" markup of the scripts.
+	scriptMarkup bool
+}
+
+type chainFont struct {
+	family string
+	// has reports whether the font has a glyph for the rune; nil means all.
+	has func(rune) bool
+	// encode converts the text to the font's encoding; nil means UTF-8.
+	encode func(string) string
+}
+
+// has reports whether the font has a glyph for r.
+func (ti ttfInfo) has(r rune) bool {
+	i := sort.Search(len(ti.Runes), func(i int) bool { return ti.Runes[i] >= r })
+	return i < len(ti.Runes) && ti.Runes[i] == r
+}
+
+// fontRun is a piece of text printed with one font, already encoded.
+type fontRun struct {
+	family, text string
+	level        int
+}
+
+// runs splits s to runs by the font to print them with and the script level.
+// Runes missing from all the fonts stay with the primary font.
+func (fc *fontChain) runs(s string) []fontRun {
+	pieces := []textPiece{{text: s}}
+	if hasScripts(s, fc.scriptMarkup) {
+		pieces = splitScripts(s, fc.scriptMarkup)
+	}
+	var runs []fontRun
+	for _, p := range pieces {
+		var start int
+		cur := -1
+		flush := func(end int) {
+			if cur < 0 {
+				return
+			}
+			f, text := fc.fonts[cur], p.text[start:end]
+			if f.encode != nil {
+				text = f.encode(text)
+			}
+			runs = append(runs, fontRun{family: f.family, text: text, level: p.level})
+		}
+		for i, r := range p.text {
+			f := 0
+			for j, font := range fc.fonts {
+				if font.has == nil || font.has(r) {
+					f = j
+					break
+				}
+			}
+			if f != cur {
+				flush(i)
+				cur, start = f, i
+			}
+		}
+		flush(len(p.text))
+	}
+	return runs
+}
+
+// cellFormat is pdf.CellFormat(w, h, txt, border, 0, align, fill, 0, ""),
+// printing each rune of txt with the first font of the chain having it,
+// and the scripts smaller and shifted.
+// fontStyle and size are the current font style and size.
+//
+// Without a chain txt is printed as is, with a chain it is encoded by the
+// fonts of the chain.
+func (fc *fontChain) cellFormat(pdf *gofpdf.Fpdf, fontStyle string, size, w, h float64,
+	txt, border, align string, fill bool,
+) {
+	if fc == nil {
+		pdf.CellFormat(w, h, txt, border, 0, align, fill, 0, "")
+		return
+	}
+	txt = visualOrder(txt)
+	runs := fc.runs(txt)
+	if len(runs) < 2 && (len(runs) == 0 || runs[0].family == fc.fonts[0].family && runs[0].level == scriptNone) {
+		if len(runs) != 0 {
+			txt = runs[0].text
+		}
+		pdf.CellFormat(w, h, txt, border, 0, align, fill, 0, "")
+		return
+	}
+	// draw the cell (border, fill) and let it break the page,
+	// then print the runs into it
+	pdf.CellFormat(w, h, "", border, 0, align, fill, 0, "")
+	x, y := pdf.GetXY()
+	x -= w
+	_, fontHt := pdf.GetFontSize()
+	widths := make([]float64, len(runs))
+	var total float64
+	for i, run := range runs {
+		pdf.SetFont(run.family, fontStyle, scriptSize(size, run.level))
+		widths[i] = pdf.GetStringWidth(run.text)
+		total += widths[i]
+	}
+	cm := pdf.GetCellMargin()
+	switch align {
+	case "R":
+		x += w - cm - total
+	case "C":
+		x += (w - total) / 2
+	default:
+		x += cm
+	}
+	// the baseline of CellFormat's vertically centered text
+	baseline := y + h/2 + 0.3*fontHt
+	for i, run := range runs {
+		pdf.SetFont(run.family, fontStyle, scriptSize(size, run.level))
+		dy := 0.0
+		switch run.level {
+		case scriptSuper:
+			dy = -0.35 * fontHt
+		case scriptSub:
+			dy = 0.2 * fontHt
+		}
+		pdf.Text(x, baseline+dy, run.text)
+		x += widths[i]
+	}
+	pdf.SetFont(fc.fonts[0].family, fontStyle, size)
+}
+
+// stringWidth returns the width of s printed by cellFormat; fontStyle and
+// size are the current font style and size.
+func (fc *fontChain) stringWidth(pdf *gofpdf.Fpdf, fontStyle string, size float64, s string) float64 {
+	var width float64
+	for _, run := range fc.runs(s) {
+		pdf.SetFont(run.family, fontStyle, scriptSize(size, run.level))
+		width += pdf.GetStringWidth(run.text)
+	}
+	pdf.SetFont(fc.fonts[0].family, fontStyle, size)
+	return width
+}
+
+// scriptSize returns the font size of the script level.
+func scriptSize(size float64, level int) float64 {
+	if level == scriptNone {
+		return size
+	}
+	return size * 0.65
+}
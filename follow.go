@@ -0,0 +1,112 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// followPoll is the wait before reading again at the end of a followed file.
+const followPoll = 500 * time.Millisecond
+
+// Follow converts the csv read from r to the file dest as ConvertFile, and
+// renders dest again as new rows arrive, at most once per interval: for
+// FIFOs and long-running producers, so the report grows with the input.
+//
+// Only complete lines are rendered till the end of the input. With tail, it
+// waits for more data at the end of r (as tail -f does) till ctx is
+// cancelled; otherwise it finishes at the end of r. The last rendering
+// happens at the end, or when ctx is cancelled.
+func Follow(ctx context.Context, r io.Reader, dest string, interval time.Duration, tail bool, opts Options) error {
+	spool, err := os.CreateTemp("", "csv2pdf-follow-")
+	if err != nil {
+		return errors.Wrap(err, "creating tempfile")
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	type chunk struct {
+		b   []byte
+		err error
+	}
+	chunks := make(chan chunk)
+	go func() {
+		defer close(chunks)
+		for {
+			buf := make([]byte, 64<<10)
+			n, err := r.Read(buf)
+			if err == io.EOF && tail {
+				err = nil
+				if n == 0 {
+					select {
+					case <-ctx.Done():
+						return
+					case <-time.After(followPoll):
+					}
+					continue
+				}
+			}
+			select {
+			case chunks <- chunk{b: buf[:n], err: err}:
+			case <-ctx.Done():
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	// size is the length of the spooled input, complete is that of the
+	// complete lines in it; rendered is the length rendered last.
+	var size, complete, rendered int64
+	render := func() error {
+		if complete == rendered || complete == 0 {
+			return nil
+		}
+		log.Printf("follow: rendering %d bytes to %q", complete, dest)
+		if err := ConvertFile(io.NewSectionReader(spool, 0, complete), dest, opts); err != nil {
+			return err
+		}
+		rendered = complete
+		return nil
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return render()
+		case <-ticker.C:
+			if err := render(); err != nil {
+				return err
+			}
+		case c, ok := <-chunks:
+			if ok && len(c.b) != 0 {
+				if _, err := spool.Write(c.b); err != nil {
+					return errors.Wrap(err, "saving csv")
+				}
+				if i := bytes.LastIndexByte(c.b, '\n'); i >= 0 {
+					complete = size + int64(i) + 1
+				}
+				size += int64(len(c.b))
+			}
+			if !ok || c.err != nil {
+				if ok && c.err != io.EOF {
+					return errors.Wrap(c.err, "reading csv")
+				}
+				complete = size
+				return render()
+			}
+		}
+	}
+}
@@ -0,0 +1,241 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"encoding/binary"
+	"log"
+	"sort"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/pkg/errors"
+)
+
+// ttfInfo is the license and coverage info of a TrueType font.
+type ttfInfo struct {
+	Family, Copyright, License, LicenseURL string
+	// FSType holds the embedding permission flags of the OS/2 table.
+	FSType uint16
+	// Runes are the runes mapped by the font, in ascending order.
+	Runes []rune
+}
+
+const (
+	fsTypeRestricted   = 0x0002
+	fsTypeNoSubsetting = 0x0100
+	fsTypeBitmapOnly   = 0x0200
+)
+
+// parseTTF reads the name, OS/2 and cmap tables of the TrueType font.
+func parseTTF(b []byte) (ttfInfo, error) {
+	var ti ttfInfo
+	if len(b) < 12 {
+		return ti, errors.New("too short for a TrueType font")
+	}
+	if string(b[:4]) == "OTTO" {
+		return ti, errors.New("OpenType fonts with CFF outlines are not supported, only the TrueType flavour")
+	}
+	tables := make(map[string][]byte)
+	numTables := int(binary.BigEndian.Uint16(b[4:]))
+	for i := 0; i < numTables; i++ {
+		rec := 12 + 16*i
+		if rec+16 > len(b) {
+			return ti, errors.New("truncated table directory")
+		}
+		off, length := binary.BigEndian.Uint32(b[rec+8:]), binary.BigEndian.Uint32(b[rec+12:])
+		if uint64(off)+uint64(length) > uint64(len(b)) {
+			return ti, errors.Errorf("table %q is out of the file", b[rec:rec+4])
+		}
+		tables[string(b[rec:rec+4])] = b[off : off+length]
+	}
+	if os2 := tables["OS/2"]; len(os2) >= 10 {
+		ti.FSType = binary.BigEndian.Uint16(os2[8:])
+	}
+	if name := tables["name"]; len(name) >= 6 {
+		count, strOff := int(binary.BigEndian.Uint16(name[2:])), int(binary.BigEndian.Uint16(name[4:]))
+		for i := 0; i < count && 6+12*i+12 <= len(name); i++ {
+			rec := name[6+12*i:]
+			platform, nameID := binary.BigEndian.Uint16(rec), binary.BigEndian.Uint16(rec[6:])
+			length, off := int(binary.BigEndian.Uint16(rec[8:])), strOff+int(binary.BigEndian.Uint16(rec[10:]))
+			if off+length > len(name) {
+				continue
+			}
+			var s string
+			switch platform {
+			case 0, 3: // UTF-16BE
+				u := make([]uint16, length/2)
+				for j := range u {
+					u[j] = binary.BigEndian.Uint16(name[off+2*j:])
+				}
+				s = string(utf16.Decode(u))
+			case 1: // Mac Roman, ASCII is enough here
+				s = string(name[off : off+length])
+			default:
+				continue
+			}
+			var dst *string
+			switch nameID {
+			case 0:
+				dst = &ti.Copyright
+			case 1:
+				dst = &ti.Family
+			case 13:
+				dst = &ti.License
+			case 14:
+				dst = &ti.LicenseURL
+			default:
+				continue
+			}
+			if *dst == "" || platform == 3 {
+				*dst = strings.TrimSpace(s)
+			}
+		}
+	}
+	var err error
+	if ti.Runes, err = cmapRunes(tables["cmap"]); err != nil {
+		return ti, errors.Wrap(err, "cmap")
+	}
+	return ti, nil
+}
+
+// cmapRunes returns the runes mapped by the Unicode cmap subtable
+// (format 12 or 4) of the font.
+func cmapRunes(cmap []byte) ([]rune, error) {
+	if len(cmap) < 4 {
+		return nil, errors.New("missing")
+	}
+	var sub4, sub12 []byte
+	for i := 0; i < int(binary.BigEndian.Uint16(cmap[2:])) && 4+8*i+8 <= len(cmap); i++ {
+		rec := cmap[4+8*i:]
+		platform, encoding := binary.BigEndian.Uint16(rec), binary.BigEndian.Uint16(rec[2:])
+		off := int(binary.BigEndian.Uint32(rec[4:]))
+		if off+2 > len(cmap) || !(platform == 0 || platform == 3 && (encoding == 1 || encoding == 10)) {
+			continue
+		}
+		switch binary.BigEndian.Uint16(cmap[off:]) {
+		case 4:
+			sub4 = cmap[off:]
+		case 12:
+			sub12 = cmap[off:]
+		}
+	}
+	seen := make(map[rune]struct{})
+	switch {
+	case len(sub12) >= 16:
+		n := int(binary.BigEndian.Uint32(sub12[12:]))
+		for i := 0; i < n && 16+12*i+12 <= len(sub12); i++ {
+			grp := sub12[16+12*i:]
+			start, end := binary.BigEndian.Uint32(grp), binary.BigEndian.Uint32(grp[4:])
+			for r := start; r <= end && r <= 0x10FFFF; r++ {
+				seen[rune(r)] = struct{}{}
+			}
+		}
+	case len(sub4) >= 14:
+		segs := int(binary.BigEndian.Uint16(sub4[6:])) / 2
+		if 16+8*segs > len(sub4) {
+			return nil, errors.New("truncated format 4 subtable")
+		}
+		ends, starts := sub4[14:], sub4[16+2*segs:]
+		for i := 0; i < segs; i++ {
+			start, end := binary.BigEndian.Uint16(starts[2*i:]), binary.BigEndian.Uint16(ends[2*i:])
+			for r := uint32(start); r <= uint32(end) && r != 0xFFFF; r++ {
+				seen[rune(r)] = struct{}{}
+			}
+		}
+	default:
+		return nil, errors.New("no Unicode subtable")
+	}
+	runes := make([]rune, 0, len(seen))
+	for r := range seen {
+		runes = append(runes, r)
+	}
+	sort.Slice(runes, func(i, j int) bool { return runes[i] < runes[j] })
+	return runes, nil
+}
+
+// embeddable returns an error if the font license does not allow embedding.
+func (ti ttfInfo) embeddable() error {
+	if ti.FSType&0x000f == fsTypeRestricted {
+		return errors.Errorf("the license of %q does not allow embedding (restricted license, fsType=%#04x)", ti.Family, ti.FSType)
+	}
+	if ti.FSType&fsTypeBitmapOnly != 0 {
+		return errors.Errorf("the license of %q allows bitmap embedding only (fsType=%#04x)", ti.Family, ti.FSType)
+	}
+	return nil
+}
+
+// String returns the font name with its copyright and license.
+func (ti ttfInfo) String() string {
+	parts := []string{ti.Family}
+	for _, s := range []string{ti.Copyright, ti.License, ti.LicenseURL} {
+		if s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+// embeddedFont is a TrueType font embedded into the PDF.
+type embeddedFont struct {
+	family, style string
+	info          ttfInfo
+	// b is the font file, for adding it to the next document (flushPages).
+	b []byte
+}
+
+// coreFonts are the core PDF fonts, by their lower case names.
+var coreFonts = map[string]string{"arial": "Arial", "helvetica": "Helvetica", "times": "Times", "courier": "Courier"}
+
+// addFontFile embeds the TrueType font as a UTF-8 font of the family and
+// style, if its license allows it.
+//
+// The font is subset to the used glyphs, unless embedAllGlyphs is set or
+// the license forbids subsetting: then to all the glyphs of its character
+// map (gofpdf always subsets, the original font program is not embedded).
+func (pr *pdfRenderer) addFontFile(family, style string, b []byte) error {
+	info, err := parseTTF(b)
+	if err != nil {
+		return err
+	}
+	if err = info.embeddable(); err != nil {
+		return err
+	}
+	if info.FSType&fsTypeNoSubsetting != 0 && !pr.embedAllGlyphs {
+		log.Printf("the license of %q forbids subsetting, embedding all its glyphs", info.Family)
+	}
+	pr.pdf.AddUTF8FontFromBytes(family, style, b)
+	pr.fonts = append(pr.fonts, embeddedFont{family: family, style: style, info: info, b: b})
+	return pr.pdf.Error()
+}
+
+// finishFonts marks all the glyphs of the fonts to be embedded with all
+// their glyphs as used, and records the font licenses in the metadata.
+func (pr *pdfRenderer) finishFonts() {
+	if len(pr.fonts) == 0 {
+		return
+	}
+	pdf := pr.pdf
+	licenses := make([]string, 0, len(pr.fonts))
+	for _, f := range pr.fonts {
+		licenses = append(licenses, f.info.String())
+		if !pr.embedAllGlyphs && f.info.FSType&fsTypeNoSubsetting == 0 {
+			continue
+		}
+		// the subset is built from the runes printed with the font:
+		// print all of them, invisibly, off the page.
+		pdf.SetFont(f.family, f.style, 1)
+		pdf.SetTextRenderingMode(3)
+		runes := make([]rune, 0, len(f.info.Runes))
+		for _, r := range f.info.Runes {
+			if r >= ' ' && r <= 0xFFFF { // gofpdf handles the BMP only
+				runes = append(runes, r)
+			}
+		}
+		pdf.Text(0, -10, string(runes))
+		pdf.SetTextRenderingMode(0)
+	}
+	pdf.SetKeywords("Fonts: "+strings.Join(licenses, " | "), true)
+}
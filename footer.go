@@ -0,0 +1,101 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// pageFooterFontSize is the font size of the page footer, pageFooterTop the
+// distance of its line from the bottom of the page, in mm.
+const (
+	pageFooterFontSize = 7
+	pageFooterTop      = 8
+)
+
+// pageFooter is the standard footer line of the pages: the name of the
+// source file on the left, the page number in the middle, and the date of
+// the generation on the right, each if set.
+type pageFooter struct {
+	page       bool
+	file, date string
+}
+
+// parseFooter parses the comma separated list of the footer items: page,
+// date and file; nil for the empty spec.
+func parseFooter(spec, fileName string) (*pageFooter, error) {
+	if strings.TrimSpace(spec) == "" {
+		return nil, nil
+	}
+	var pf pageFooter
+	for _, item := range strings.Split(spec, ",") {
+		switch item = strings.TrimSpace(item); item {
+		case "page":
+			pf.page = true
+		case "date":
+			pf.date = time.Now().Format("2006-01-02 15:04")
+		case "file":
+			if fileName == "" {
+				fileName = "-"
+			}
+			pf.file = filepath.Base(fileName)
+		default:
+			return nil, errors.Errorf("unknown footer item %q (page, date or file)", item)
+		}
+	}
+	return &pf, nil
+}
+
+// setFooter sets the footer printed on every page. The page numbers are
+// "Page X / N", but only "Page X" with flushPages, as the pages written are
+// not numbered again.
+func (pr *pdfRenderer) setFooter(pf *pageFooter) {
+	pr.footer = pf
+	if pf.page && pr.flushPages == 0 {
+		pr.pdf.AliasNbPages("")
+	}
+	pr.footerHooks = append(pr.footerHooks, pr.drawFooter)
+}
+
+// drawFooter prints the footer line in the bottom margin, above the
+// provenance line and the footer image, left of the QR code.
+func (pr *pdfRenderer) drawFooter() {
+	pdf := pr.pdf
+	w, h := pdf.GetPageSize()
+	lm, _, rm, _ := pdf.GetMargins()
+	if pr.qr != nil {
+		rm += qrSize + 2
+	}
+	y := h - pageFooterTop
+	if pr.footerImage != nil {
+		y -= pr.footerImage.heightAt(w)
+	}
+	lineHt := pdf.PointConvert(pageFooterFontSize) * 1.2
+	pdf.SetFont(pr.font, "", pageFooterFontSize)
+	pdf.SetTextColor(64, 64, 64)
+	for _, item := range []struct{ text, align string }{
+		{pr.footer.file, "L"},
+		{pr.footer.date, "R"},
+	} {
+		if item.text != "" {
+			pdf.SetXY(lm, y)
+			pdf.CellFormat(w-lm-rm, lineHt, pr.translator(item.text), "", 0, item.align, false, 0, "")
+		}
+	}
+	if pr.footer.page {
+		text := "Page " + strconv.Itoa(pr.flushed+pdf.PageNo())
+		if pr.flushPages == 0 {
+			text += " / {nb}"
+		}
+		pdf.SetXY(lm, y)
+		pdf.CellFormat(w-lm-rm, lineHt, text, "", 0, "C", false, 0, "")
+	}
+	pdf.SetTextColor(0, 0, 0)
+}
@@ -0,0 +1,291 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"github.com/pkg/errors"
+)
+
+// formKind is the kind of form field a column is rendered as.
+//
+// The fields are drawn as boxes to be completed by hand on the printout,
+// and are written as AcroForm fields after the rendering (gofpdf cannot
+// write them), to be completed in a PDF viewer, too.
+type formKind uint8
+
+const (
+	formNone formKind = iota
+	formText
+	formCheck
+)
+
+// formColumns maps the column names to their form kind.
+type formColumns map[string]formKind
+
+// parseFormColumns parses the "name[:text|:check],..." spec.
+func parseFormColumns(spec string) (formColumns, error) {
+	fc := make(formColumns)
+	for _, s := range strings.Split(spec, ",") {
+		name, kind := s, "text"
+		if i := strings.LastIndexByte(s, ':'); i >= 0 {
+			name, kind = s[:i], s[i+1:]
+		}
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		switch strings.ToLower(kind) {
+		case "text":
+			fc[name] = formText
+		case "check", "checkbox":
+			fc[name] = formCheck
+		default:
+			return nil, errors.Errorf("unknown form field kind %q of %q", kind, name)
+		}
+	}
+	return fc, nil
+}
+
+// apply sets the form kinds of the matching columns of the part.
+func (fc formColumns) apply(part *partDesc) {
+	for i, h := range part.head {
+		kind, ok := fc[strings.TrimSpace(h)]
+		if !ok && i < len(part.fields) && part.fields[i] != nil {
+			kind, ok = fc[part.fields[i].Name]
+		}
+		if !ok {
+			continue
+		}
+		if part.forms == nil {
+			part.forms = make([]formKind, len(part.head))
+		}
+		part.forms[i] = kind
+	}
+}
+
+// drawFormCell draws an empty write-in box, or a checkbox into the next
+// cell; the tick of a checkbox is the appearance of its AcroForm field.
+func drawFormCell(pdf *gofpdf.Fpdf, kind formKind, w, h float64, border string, fill bool) {
+	x, y := pdf.GetXY()
+	pdf.CellFormat(w, h, "", border, 0, "", fill, 0, "")
+	lw := pdf.GetLineWidth()
+	pdf.SetLineWidth(0.1)
+	bx, by, bw, bh := formBox(kind, x, y, w, h)
+	pdf.Rect(bx, by, bw, bh, "D")
+	pdf.SetLineWidth(lw)
+}
+
+// formBox returns the box of the field in the cell at x, y of width w and
+// height h.
+func formBox(kind formKind, x, y, w, h float64) (bx, by, bw, bh float64) {
+	if kind == formCheck {
+		side := h * 2 / 3
+		return x + (w-side)/2, y + h/6, side, side
+	}
+	return x + 1, y + h/8, w - 2, h * 3 / 4
+}
+
+// isChecked reports whether the value ticks a checkbox.
+func isChecked(v string) bool {
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "x", "1", "y", "yes", "true", "i", "igen":
+		return true
+	}
+	return false
+}
+
+// formField is a form field drawn on a page.
+type formField struct {
+	kind        formKind
+	name, value string
+	// page is the number of the page (from 1), rect the lower left and the
+	// upper right corners of the box on it, in points.
+	page int
+	rect [4]float64
+}
+
+// formFields collects the form fields drawn, to be written as AcroForm
+// fields after the rendering.
+type formFields struct {
+	fields []formField
+	// page returns the number of the current page of the document.
+	page func() int
+	// rows counts the fields per column, numbering their names.
+	rows map[string]int
+}
+
+func newFormFields(page func() int) *formFields {
+	return &formFields{page: page, rows: make(map[string]int)}
+}
+
+// add records the field of the column head with the value v, in the cell of
+// width w and height h at the current position.
+func (ff *formFields) add(pdf *gofpdf.Fpdf, kind formKind, head, v string, w, h float64) {
+	x, y := pdf.GetXY()
+	bx, by, bw, bh := formBox(kind, x, y, w, h)
+	_, ph := pdf.GetPageSize()
+	k := pdf.GetConversionRatio()
+	head = strings.Map(func(r rune) rune {
+		if r == '.' || r == ' ' {
+			return '_' // the dot separates the parts of the field names
+		}
+		return r
+	}, strings.TrimSpace(head))
+	ff.rows[head]++
+	ff.fields = append(ff.fields, formField{
+		kind: kind, name: fmt.Sprintf("%s_%d", head, ff.rows[head]), value: v,
+		page: ff.page(),
+		rect: [4]float64{bx * k, (ph - by - bh) * k, (bx + bw) * k, (ph - by) * k},
+	})
+}
+
+// withFormFields prepends the step writing the form fields drawn by rend, if
+// any, to the steps.
+func withFormFields(rend tableRenderer, steps []postStep) []postStep {
+	pr, ok := rend.(*pdfRenderer)
+	if !ok || pr.forms == nil || len(pr.forms.fields) == 0 {
+		return steps
+	}
+	return append([]postStep{{name: "forms", run: pr.forms.write}}, steps...)
+}
+
+// write adds the fields to the pages of the PDF read from rs as AcroForm
+// widgets, with Helvetica for the text fields, and writes it to w.
+func (ff *formFields) write(rs io.ReadSeeker, w io.Writer, conf *model.Configuration) error {
+	ctx, err := api.ReadContext(rs, conf)
+	if err == nil {
+		err = api.ValidateContext(ctx)
+	}
+	if err == nil {
+		err = api.OptimizeContext(ctx)
+	}
+	if err == nil {
+		err = ctx.EnsurePageCount()
+	}
+	if err != nil {
+		return err
+	}
+	xt := ctx.XRefTable
+	font, err := xt.IndRefForNewObject(types.Dict{
+		"Type": types.Name("Font"), "Subtype": types.Name("Type1"),
+		"BaseFont": types.Name("Helvetica"), "Encoding": types.Name("WinAnsiEncoding"),
+	})
+	if err != nil {
+		return err
+	}
+	fields := make(types.Array, 0, len(ff.fields))
+	annots := make(map[int]types.Array)
+	// the appearances of the checkboxes, by their sizes
+	checks := make(map[[2]float64]types.Dict)
+	for _, f := range ff.fields {
+		pageRef, err := xt.PageDictIndRef(f.page)
+		if err != nil {
+			return errors.Wrapf(err, "page %d", f.page)
+		}
+		name, err := types.EscapeUTF16String(f.name)
+		if err != nil {
+			return err
+		}
+		d := types.Dict{
+			"Type": types.Name("Annot"), "Subtype": types.Name("Widget"),
+			"T":    types.StringLiteral(*name),
+			"Rect": types.NewNumberArray(f.rect[:]...),
+			"F":    types.Integer(4), // print
+			"P":    *pageRef,
+		}
+		switch f.kind {
+		case formText:
+			d["FT"], d["DA"] = types.Name("Tx"), types.StringLiteral("/Helv 0 Tf 0 g")
+			if strings.TrimSpace(f.value) != "" {
+				v, err := types.EscapeUTF16String(f.value)
+				if err != nil {
+					return err
+				}
+				d["V"] = types.StringLiteral(*v)
+			}
+		case formCheck:
+			size := [2]float64{f.rect[2] - f.rect[0], f.rect[3] - f.rect[1]}
+			ap, ok := checks[size]
+			if !ok {
+				if ap, err = checkAppearance(xt, size[0], size[1]); err != nil {
+					return err
+				}
+				checks[size] = ap
+			}
+			state := types.Name("Off")
+			if isChecked(f.value) {
+				state = "Yes"
+			}
+			d["FT"], d["V"], d["AS"], d["AP"] = types.Name("Btn"), state, state, ap
+		}
+		ref, err := xt.IndRefForNewObject(d)
+		if err != nil {
+			return err
+		}
+		fields = append(fields, *ref)
+		annots[f.page] = append(annots[f.page], *ref)
+	}
+	for page, refs := range annots {
+		pd, _, _, err := xt.PageDict(page, false)
+		if err != nil {
+			return errors.Wrapf(err, "page %d", page)
+		}
+		if o, found := pd.Find("Annots"); found {
+			links, err := xt.DereferenceArray(o)
+			if err != nil {
+				return errors.Wrapf(err, "annotations of page %d", page)
+			}
+			refs = append(links, refs...)
+		}
+		pd["Annots"] = refs
+	}
+	root, err := xt.Catalog()
+	if err != nil {
+		return err
+	}
+	// the viewers draw the text fields
+	root["AcroForm"] = types.Dict{
+		"Fields":          fields,
+		"NeedAppearances": types.Boolean(true),
+		"DA":              types.StringLiteral("/Helv 0 Tf 0 g"),
+		"DR":              types.Dict{"Font": types.Dict{"Helv": *font}},
+	}
+	return api.WriteContext(ctx, w)
+}
+
+// checkAppearance returns the appearance dictionary of the checkboxes of
+// width w and height h: a cross when ticked, nothing when not.
+func checkAppearance(xt *model.XRefTable, w, h float64) (types.Dict, error) {
+	n := types.Dict{}
+	for _, state := range []struct{ name, content string }{
+		{"Yes", fmt.Sprintf("q 0 G 0.8 w 1.5 1.5 m %.2f %.2f l S 1.5 %.2f m %.2f 1.5 l S Q", w-1.5, h-1.5, h-1.5, w-1.5)},
+		{"Off", ""},
+	} {
+		sd, err := xt.NewStreamDictForBuf([]byte(state.content))
+		if err != nil {
+			return nil, err
+		}
+		sd.InsertName("Type", "XObject")
+		sd.InsertName("Subtype", "Form")
+		sd.Insert("BBox", types.NewNumberArray(0, 0, w, h))
+		if err = sd.Encode(); err != nil {
+			return nil, err
+		}
+		ref, err := xt.IndRefForNewObject(*sd)
+		if err != nil {
+			return nil, err
+		}
+		n[state.name] = *ref
+	}
+	return types.Dict{"N": n}, nil
+}
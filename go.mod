@@ -1,13 +1,23 @@
 module github.com/tgulacsi/csv2pdf
 
-go 1.19
+go 1.20
 
 require (
-	github.com/jung-kurt/gofpdf v1.0.0
-	github.com/pkg/errors v0.8.1
+	github.com/jung-kurt/gofpdf v1.16.2
+	github.com/pdfcpu/pdfcpu v0.4.2
+	github.com/pkg/errors v0.9.1
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
 	github.com/tgulacsi/go v0.2.23
 	github.com/tgulacsi/statik v0.1.3
 	golang.org/x/sync v0.0.0-20181221193216-37e7f081c4d4
+	golang.org/x/text v0.7.0
+	gopkg.in/yaml.v2 v2.4.0
 )
 
-require golang.org/x/text v0.3.1-0.20180807135948-17ff2d5776d2 // indirect
+require (
+	github.com/hhrutter/lzw v1.0.0 // indirect
+	github.com/hhrutter/tiff v1.0.0 // indirect
+	github.com/mattn/go-runewidth v0.0.14 // indirect
+	github.com/rivo/uniseg v0.4.4 // indirect
+	golang.org/x/image v0.5.0 // indirect
+)
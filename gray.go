@@ -0,0 +1,138 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+	"regexp"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// grayOf returns the gray equivalent (by luminance) of the color.
+func grayOf(r, g, b int) (int, int, int) {
+	y := int(0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b) + 0.5)
+	return y, y, y
+}
+
+// The gray levels of the fills on the black-and-white laser printers: the
+// halftone of the lighter levels vanishes, the darker ones bury the text.
+const grayFillMin, grayFillMax = 180, 235
+
+// grayLineMax is the lightest gray of the lines: the lighter thin lines are
+// printed broken.
+const grayLineMax = 96
+
+// grayContrast is the minimal difference of the gray levels of the text
+// and its fill.
+const grayContrast = 100
+
+// grayFill returns the gray fill of the color, keeping the order of their
+// luminance.
+func grayFill(c RGB) RGB {
+	y, _, _ := grayOf(c.R, c.G, c.B)
+	y = grayFillMin + y*(grayFillMax-grayFillMin)/255
+	return RGB{y, y, y}
+}
+
+// grayLine returns the gray of the lines of the color.
+func grayLine(c RGB) RGB {
+	y, _, _ := grayOf(c.R, c.G, c.B)
+	y = y * grayLineMax / 255
+	return RGB{y, y, y}
+}
+
+// gray returns the gray equivalents of the colors, calibrated for the
+// black-and-white laser printers: the fills light, the borders dark, and
+// the header text black (or white) if it would not stand out of its fill.
+func (c Colors) gray() Colors {
+	c.HeaderFill, c.Stripe = grayFill(c.HeaderFill), grayFill(c.Stripe)
+	c.Border = grayLine(c.Border)
+	y, _, _ := grayOf(c.HeaderText.R, c.HeaderText.G, c.HeaderText.B)
+	if d := y - c.HeaderFill.R; d < grayContrast && d > -grayContrast {
+		if y = 0; c.HeaderFill.R < 128 {
+			y = 255
+		}
+	}
+	c.HeaderText = RGB{y, y, y}
+	return c
+}
+
+// rgb returns the color, or its gray equivalent with -grayscale.
+func (pr *pdfRenderer) rgb(r, g, b int) (int, int, int) {
+	if pr.grayscale {
+		return grayOf(r, g, b)
+	}
+	return r, g, b
+}
+
+// rgb returns the color, or its gray equivalent with -grayscale.
+func (t *pdfTable) rgb(r, g, b int) (int, int, int) {
+	if t.grayscale {
+		return grayOf(r, g, b)
+	}
+	return r, g, b
+}
+
+var (
+	rStream = regexp.MustCompile(`(?s)<<([^>]*(?:>[^>][^>]*)*)>>\s*stream\r?\n`)
+	// rColorOp matches the RGB and CMYK color operators.
+	rColorOp = regexp.MustCompile(`(?:^|\s)((?:[\d.]+\s+){3}(?:rg|RG)|(?:[\d.]+\s+){4}(?:k|K))\b`)
+	rNum     = regexp.MustCompile(`[\d.]+`)
+)
+
+// checkGrayscale checks that the page contents of the PDF in b set gray
+// colors only, and that there are no color images.
+func checkGrayscale(b []byte) error {
+	for _, loc := range rStream.FindAllSubmatchIndex(b, -1) {
+		dict := b[loc[2]:loc[3]]
+		if bytes.Contains(dict, []byte("/Subtype /Image")) || bytes.Contains(dict, []byte("/Subtype/Image")) {
+			if bytes.Contains(dict, []byte("/DeviceRGB")) || bytes.Contains(dict, []byte("/DeviceCMYK")) {
+				return errors.Errorf("color image at %d", loc[0])
+			}
+			continue
+		}
+		// fonts and attachments
+		if bytes.Contains(dict, []byte("/Length1")) || bytes.Contains(dict, []byte("/EmbeddedFile")) {
+			continue
+		}
+		data := b[loc[1]:]
+		if end := bytes.Index(data, []byte("endstream")); end >= 0 {
+			data = data[:end]
+		}
+		if bytes.Contains(dict, []byte("/FlateDecode")) {
+			zr, err := zlib.NewReader(bytes.NewReader(data))
+			if err != nil {
+				continue
+			}
+			if data, err = io.ReadAll(zr); err != nil && len(data) == 0 {
+				continue
+			}
+		}
+		for _, m := range rColorOp.FindAllSubmatch(data, -1) {
+			if !isGrayOp(m[1]) {
+				return errors.Errorf("color operator %q at %d", m[1], loc[0])
+			}
+		}
+	}
+	return nil
+}
+
+// isGrayOp reports whether the color operator sets a gray color:
+// equal RGB components, or no CMY ink.
+func isGrayOp(op []byte) bool {
+	nums := rNum.FindAll(op, -1)
+	v := make([]float64, len(nums))
+	for i, n := range nums {
+		v[i], _ = strconv.ParseFloat(string(n), 64)
+	}
+	if len(v) == 4 {
+		return v[0] == 0 && v[1] == 0 && v[2] == 0
+	}
+	return v[0] == v[1] && v[1] == v[2]
+}
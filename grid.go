@@ -0,0 +1,54 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import "strconv"
+
+// drawDebugGrid draws a light millimeter grid, stronger at every 5 and 10 mm
+// (labeled), and the margins and the page break limit as dashed boxes.
+func (pr *pdfRenderer) drawDebugGrid() {
+	pdf := pr.pdf
+	w, h := pdf.GetPageSize()
+	lw := pdf.GetLineWidth()
+	r, g, b := pdf.GetDrawColor()
+	defer func() {
+		pdf.SetLineWidth(lw)
+		pdf.SetDrawColor(r, g, b)
+		pdf.SetDashPattern(nil, 0)
+	}()
+
+	pdf.SetFont("Arial", "", 4)
+	pdf.SetTextColor(150, 150, 150)
+	for _, step := range []struct {
+		mm    int
+		gray  int
+		width float64
+	}{{1, 235, 0.02}, {5, 210, 0.05}, {10, 170, 0.08}} {
+		pdf.SetDrawColor(step.gray, step.gray, step.gray)
+		pdf.SetLineWidth(step.width)
+		for x := step.mm; float64(x) < w; x += step.mm {
+			pdf.Line(float64(x), 0, float64(x), h)
+		}
+		for y := step.mm; float64(y) < h; y += step.mm {
+			pdf.Line(0, float64(y), w, float64(y))
+		}
+	}
+	for x := 10; float64(x) < w; x += 10 {
+		pdf.Text(float64(x)+0.3, 2, strconv.Itoa(x))
+	}
+	for y := 10; float64(y) < h; y += 10 {
+		pdf.Text(0.3, float64(y)-0.3, strconv.Itoa(y))
+	}
+
+	lm, tm, rm, _ := pdf.GetMargins()
+	_, bm := pdf.GetAutoPageBreak()
+	pdf.SetDashPattern([]float64{1, 1}, 0)
+	pdf.SetLineWidth(0.15)
+	pdf.SetDrawColor(pr.rgb(0, 0, 255))
+	pdf.Rect(lm, tm, w-lm-rm, h-tm-bm, "D")
+	pdf.SetDrawColor(pr.rgb(255, 0, 255))
+	pdf.Line(0, h-bm, w, h-bm)
+	pdf.SetTextColor(0, 0, 0)
+}
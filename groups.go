@@ -0,0 +1,75 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import "strings"
+
+// columnGroups maps the names of the columns to their (logical) group.
+type columnGroups map[string]int
+
+// groupRuleWidth is the width of the rules between the column groups, in mm.
+const groupRuleWidth = 0.8
+
+// parseColumnGroups parses the "col,col|col,col,col|col" spec: | separated
+// groups of comma separated column names.
+func parseColumnGroups(spec string) columnGroups {
+	cg := make(columnGroups)
+	for g, group := range strings.Split(spec, "|") {
+		for _, name := range strings.Split(group, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				cg[name] = g + 1
+			}
+		}
+	}
+	return cg
+}
+
+// apply sets the groups of the matching columns of the part; the other
+// columns are in group 0.
+func (cg columnGroups) apply(part *partDesc) {
+	for i, h := range part.head {
+		g, ok := cg[strings.TrimSpace(h)]
+		if !ok && i < len(part.fields) && part.fields[i] != nil {
+			g, ok = cg[part.fields[i].Name]
+		}
+		if !ok {
+			continue
+		}
+		if part.groups == nil {
+			part.groups = make([]int, len(part.head))
+		}
+		part.groups[i] = g
+	}
+}
+
+// group returns the group of the i-th column, 0 for none.
+func (part partDesc) group(i int) int {
+	if i < len(part.groups) {
+		return part.groups[i]
+	}
+	return 0
+}
+
+// drawGroupRules draws the heavier vertical rules between the column
+// groups, from y down h.
+func (t *pdfTable) drawGroupRules(y, h float64) {
+	if t.part.groups == nil {
+		return
+	}
+	pdf := t.pdf
+	x, _, _, _ := pdf.GetMargins()
+	lw := pdf.GetLineWidth()
+	pdf.SetLineWidth(groupRuleWidth)
+	cols := t.columns(len(t.colwidths))
+	for k, i := range cols {
+		if k > 0 {
+			if g, prev := t.part.group(i), t.part.group(cols[k-1]); g != prev {
+				pdf.Line(x, y, x, y+h)
+			}
+		}
+		x += t.colwidths[i]
+	}
+	pdf.SetLineWidth(lw)
+}
@@ -0,0 +1,138 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// headerDetector finds the header rows of back-to-back tables with the same
+// column count, which would not start a new part otherwise.
+type headerDetector struct {
+	// repeat: the row is the same as one of the previous headers.
+	repeat bool
+	// alpha: the row is all-alphabetic, and has text where the part has
+	// only numbers so far.
+	alpha bool
+	// re matches the first column of the header rows.
+	re *regexp.Regexp
+
+	heads   [][]string
+	numeric []bool
+	rows    int
+}
+
+// newHeaderDetector returns the detector for the comma separated modes
+// ("repeat", "alpha") and regexp; nil if both are empty.
+func newHeaderDetector(modes, re string) (*headerDetector, error) {
+	if modes == "" && re == "" {
+		return nil, nil
+	}
+	var hd headerDetector
+	if modes != "" {
+		for _, m := range strings.Split(modes, ",") {
+			switch m = strings.TrimSpace(m); m {
+			case "repeat":
+				hd.repeat = true
+			case "alpha":
+				hd.alpha = true
+			default:
+				return nil, errors.Errorf("unknown header detection mode %q", m)
+			}
+		}
+	}
+	if re != "" {
+		var err error
+		if hd.re, err = regexp.Compile(re); err != nil {
+			return nil, err
+		}
+	}
+	return &hd, nil
+}
+
+// startPart records the header of a new part.
+func (hd *headerDetector) startPart(head []string) {
+	if hd == nil {
+		return
+	}
+	hd.heads = append(hd.heads, head)
+	hd.numeric, hd.rows = make([]bool, len(head)), 0
+	for i := range hd.numeric {
+		hd.numeric[i] = true
+	}
+}
+
+// observe records the data row of the current part.
+func (hd *headerDetector) observe(record []string) {
+	if hd == nil {
+		return
+	}
+	hd.rows++
+	for i, v := range record {
+		if i < len(hd.numeric) && hd.numeric[i] {
+			_, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			hd.numeric[i] = err == nil
+		}
+	}
+}
+
+// isHeader reports whether the record (with the same column count as the
+// current part) looks like the header of a new table.
+func (hd *headerDetector) isHeader(record []string) bool {
+	if hd == nil || len(record) == 0 {
+		return false
+	}
+	if hd.re != nil && hd.re.MatchString(record[0]) {
+		return true
+	}
+	if hd.repeat {
+		for _, head := range hd.heads {
+			if equalStrings(head, record) {
+				return true
+			}
+		}
+	}
+	if !hd.alpha || hd.rows == 0 {
+		return false
+	}
+	var textForNumber bool
+	for i, v := range record {
+		if !isAlphabetic(v) {
+			return false
+		}
+		textForNumber = textForNumber || hd.numeric[i]
+	}
+	return textForNumber
+}
+
+// isAlphabetic reports whether v is not empty and has letters (and spaces,
+// punctuation), but no digits.
+func isAlphabetic(v string) bool {
+	var letter bool
+	for _, r := range v {
+		if unicode.IsDigit(r) {
+			return false
+		}
+		letter = letter || unicode.IsLetter(r)
+	}
+	return letter
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if strings.TrimSpace(a[i]) != strings.TrimSpace(b[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,121 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"strconv"
+	"strings"
+)
+
+// noteRenderer is implemented by the renderers which can print a note row
+// spanning the table, such as the omitted rows separator.
+type noteRenderer interface {
+	Note(text string) error
+}
+
+func (mr multiRenderer) Note(text string) error {
+	for _, r := range mr {
+		if nr, ok := r.(noteRenderer); ok {
+			if err := nr.Note(text); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// renderNote renders the note row, as the first cell of a row if rend
+// cannot span it.
+func renderNote(rend tableRenderer, text string, cols int) error {
+	if nr, ok := rend.(noteRenderer); ok {
+		return nr.Note(text)
+	}
+	record := make([]string, cols)
+	if cols != 0 {
+		record[0] = text
+	}
+	return rend.Row(record)
+}
+
+// Note prints the text in a row spanning the table.
+func (pr *pdfRenderer) Note(text string) error {
+	if pr.table != nil {
+		pr.table.note(text)
+	}
+	return pr.pdf.Error()
+}
+
+func (t *pdfTable) note(text string) {
+	h := t.style.RowHeight
+	t.breakPage(h)
+	var w float64
+	for _, cw := range t.colwidths {
+		w += cw
+	}
+	text = strings.ReplaceAll(text, "…", t.ellipsis)
+	if t.fallback == nil {
+		text = t.translator(text)
+	}
+	t.fallback.cellFormat(t.pdf, "", t.style.BodyFontSize, w, h, text, t.style.headerBorder(), "C", false)
+	t.pdf.Ln(-1)
+}
+
+// Note prints the text as a line of the table.
+func (tr *textRenderer) Note(text string) error {
+	if tr.markdown {
+		cells := make([]string, len(tr.widths))
+		if len(cells) != 0 {
+			cells[0] = text
+		}
+		return tr.writeRow(cells, false)
+	}
+	_, err := tr.w.WriteString(text + "\n")
+	return err
+}
+
+// headTail selects the first head and the last tail rows of a part of total
+// rows, to be separated by a note of the omitted rows.
+type headTail struct {
+	head, tail int
+	total, i   int
+}
+
+// part starts a part of total rows.
+func (ht *headTail) part(total int) {
+	ht.total, ht.i = total, 0
+}
+
+// next reports whether the next row is to be printed, and the number of
+// the omitted rows to be noted before it, if it is the first omitted.
+func (ht *headTail) next() (keep bool, omitted int) {
+	i := ht.i
+	ht.i++
+	omitted = ht.total - ht.head - ht.tail
+	if omitted <= 0 || i < ht.head {
+		return true, 0
+	}
+	if i == ht.head {
+		return false, omitted
+	}
+	return i >= ht.head+omitted, 0
+}
+
+// omittedNote returns the separator text of n omitted rows.
+func omittedNote(n int) string {
+	return "… " + groupThousands(n) + " rows omitted …"
+}
+
+// groupThousands formats n with space separated thousands: "1 234 567".
+func groupThousands(n int) string {
+	s := strconv.Itoa(n)
+	var buf strings.Builder
+	for i, c := range s {
+		if i != 0 && (len(s)-i)%3 == 0 && s[i-1] != '-' {
+			buf.WriteByte(' ')
+		}
+		buf.WriteRune(c)
+	}
+	return buf.String()
+}
@@ -0,0 +1,143 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// heatmap maps the names of the columns shaded by their values to their
+// fixed ranges; nil means the range of the column's values.
+type heatmap map[string]*numRange
+
+// numRange is the range of the numbers of a column.
+type numRange struct {
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Fixed bool    `json:"fixed,omitempty"`
+	set   bool
+}
+
+// parseHeatmap parses the "col,col=min:max" spec.
+func parseHeatmap(spec string) (heatmap, error) {
+	hm := make(heatmap)
+	for _, item := range strings.Split(spec, ",") {
+		name, rng, hasRange := strings.Cut(strings.TrimSpace(item), "=")
+		if name == "" {
+			continue
+		}
+		if !hasRange {
+			hm[name] = nil
+			continue
+		}
+		lo, hi, ok := strings.Cut(rng, ":")
+		if !ok {
+			return nil, errors.Errorf("%s: range should be min:max", item)
+		}
+		var r numRange
+		var err error
+		if r.Min, err = strconv.ParseFloat(lo, 64); err != nil {
+			return nil, errors.Wrap(err, item)
+		}
+		if r.Max, err = strconv.ParseFloat(hi, 64); err != nil {
+			return nil, errors.Wrap(err, item)
+		}
+		if r.Min >= r.Max {
+			return nil, errors.Errorf("%s: min should be less than max", item)
+		}
+		r.Fixed, r.set = true, true
+		hm[name] = &r
+	}
+	return hm, nil
+}
+
+// ranges returns the ranges of the shaded columns of the head, nil for the
+// others, or nil if there is no such column.
+func (hm heatmap) ranges(head []string) []*numRange {
+	var ranges []*numRange
+	for i, h := range head {
+		r, ok := hm[strings.TrimSpace(h)]
+		if !ok {
+			continue
+		}
+		if ranges == nil {
+			ranges = make([]*numRange, len(head))
+		}
+		if r == nil {
+			r = &numRange{}
+		} else {
+			c := *r
+			r = &c
+		}
+		ranges[i] = r
+	}
+	return ranges
+}
+
+// heatmapObserver collects the value ranges of the heatmap columns.
+type heatmapObserver struct {
+	hm     heatmap
+	ranges []*numRange
+}
+
+func (ho *heatmapObserver) startPart(head []string) { ho.ranges = ho.hm.ranges(head) }
+
+// observe extends the (not fixed) ranges with the numbers of the record.
+func (ho *heatmapObserver) observe(line int, record []string) {
+	for i, r := range ho.ranges {
+		if r == nil || r.Fixed || i >= len(record) {
+			continue
+		}
+		f, _, ok := parseNumber(record[i])
+		if !ok {
+			continue
+		}
+		if !r.set || f < r.Min {
+			r.Min = f
+		}
+		if !r.set || f > r.Max {
+			r.Max = f
+		}
+		r.set = true
+	}
+}
+
+func (ho *heatmapObserver) finishPart(part *partDesc) { part.heat, ho.ranges = ho.ranges, nil }
+
+// heatStops are the colors of the minimum, the middle and the maximum.
+var heatStops = [3][3]float64{{99, 190, 123}, {255, 235, 132}, {248, 105, 107}}
+
+// heatColor returns the shade of the i-th column's value v, if it is shaded.
+func (part partDesc) heatColor(i int, v string) (r, g, b int, ok bool) {
+	if i >= len(part.heat) || part.heat[i] == nil {
+		return 0, 0, 0, false
+	}
+	f, _, ok := parseNumber(v)
+	if !ok {
+		return 0, 0, 0, false
+	}
+	rng := part.heat[i]
+	var pos float64
+	if rng.Max > rng.Min {
+		pos = (f - rng.Min) / (rng.Max - rng.Min)
+	}
+	pos = 2 * maxFloat(0, minFloat(1, pos))
+	lo, hi := heatStops[0], heatStops[1]
+	if pos > 1 {
+		lo, hi, pos = heatStops[1], heatStops[2], pos-1
+	}
+	c := func(j int) int { return int(lo[j] + (hi[j]-lo[j])*pos + 0.5) }
+	return c(0), c(1), c(2), true
+}
+
+func minFloat(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,186 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"fmt"
+	"strings"
+)
+
+// pageSet prints a table too wide for the page on sets of pages: the rows
+// fitting on a page are printed with the first slice of the columns, then
+// with the next slices on the following ("continued") pages, each slice
+// starting with the key columns, so the rows stay identifiable.
+type pageSet struct {
+	part        partDesc
+	colwidths   []float64
+	orientation string
+	style       tableStyle
+	// slices are the indexes of the columns of the slices.
+	slices [][]int
+	tables []*pdfTable
+	// pending are the rows not printed yet; they are printed when there
+	// are more than maxRows, the rows of an empty page.
+	pending [][]string
+	maxRows int
+	// fresh is set while the first page of the first slice has no rows.
+	fresh bool
+}
+
+// sliceColumns returns the slices of the columns of the widths, each
+// fitting the pages of the orientation with the key columns, if the table
+// is split and does not fit; nil otherwise.
+func (pr *pdfRenderer) sliceColumns(colwidths []float64, orientation string) [][]int {
+	width := pr.printableWidth(orientation)
+	if !pr.splitWide || sumFloats(colwidths) <= width {
+		return nil
+	}
+	keys := minInt(pr.keyColumns, len(colwidths)-1)
+	keyWidth := sumFloats(colwidths[:keys])
+	var slices [][]int
+	var cur []int
+	curWidth := keyWidth
+	for i := keys; i < len(colwidths); i++ {
+		if cur != nil && curWidth+colwidths[i] > width {
+			slices = append(slices, cur)
+			cur, curWidth = nil, keyWidth
+		}
+		if cur == nil {
+			for k := 0; k < keys; k++ {
+				cur = append(cur, k)
+			}
+		}
+		cur = append(cur, i)
+		curWidth += colwidths[i]
+	}
+	if slices = append(slices, cur); len(slices) == 1 {
+		return nil
+	}
+	return slices
+}
+
+// newPageSet returns the page set of the part, with the columns in slices.
+func (pr *pdfRenderer) newPageSet(part partDesc, colwidths []float64, orientation string, style tableStyle, slices [][]int) *pageSet {
+	h := pr.defPageSize.Ht
+	if orientation == "L" {
+		h = pr.defPageSize.Wd
+	}
+	return &pageSet{part: part, colwidths: colwidths, orientation: orientation, style: style,
+		slices: slices, tables: make([]*pdfTable, len(slices)), maxRows: int(h/style.RowHeight) + 1}
+}
+
+// pageSetTable returns the table of the k-th slice on a new page, with
+// its header printed; the first table starts on the current page.
+func (pr *pdfRenderer) pageSetTable(k int) *pdfTable {
+	ps := pr.pageSet
+	pdf := pr.pdf
+	t := ps.tables[k]
+	if k == 0 && t == nil {
+		ps.fresh = true
+	} else {
+		pdf.AddPageFormat(ps.orientation, pr.defPageSize)
+	}
+	if k > 0 {
+		cols := ps.slices[k]
+		pdf.SetFont(pr.font, "", ps.style.BodyFontSize)
+		pdf.SetTextColor(0, 0, 0)
+		align := "L"
+		if pr.rtl {
+			align = "R"
+		}
+		note := fmt.Sprintf("(continued: columns %d-%d of %d)", cols[minInt(pr.keyColumns, len(cols)-1)]+1, cols[len(cols)-1]+1, len(ps.colwidths))
+		pdf.CellFormat(0, ps.style.RowHeight*0.8, pr.translator(note), "", 1, align, false, 0, "")
+	}
+	if t == nil {
+		cols := ps.slices[k]
+		t = makeTable(pdf, pr.translator, ps.part.reorder(cols), permute(ps.colwidths, cols), ps.orientation, pr.defPageSize,
+			ps.style, pr.colors, pr.font, pr.fallback, pr.rtl)
+		pr.setupTable(t)
+		ps.tables[k] = t
+		return t
+	}
+	t.drawHeader()
+	if len(t.totalIdx) != 0 {
+		t.totalRow("Brought forward")
+	}
+	return t
+}
+
+// pageSetRow adds the record to the pending rows of the page set, printing
+// a page set if there are enough.
+func (pr *pdfRenderer) pageSetRow(record []string) error {
+	ps := pr.pageSet
+	ps.pending = append(ps.pending, append([]string(nil), record...))
+	if len(ps.pending) > ps.maxRows {
+		pr.flushPageSet(false)
+	}
+	return pr.pdf.Error()
+}
+
+// flushPageSet prints the page sets of the pending rows: the rows fitting on
+// the page of the first slice, then the same rows with the other slices;
+// all of them if last, and then the totals, too.
+func (pr *pdfRenderer) flushPageSet(last bool) {
+	ps := pr.pageSet
+	for len(ps.pending) != 0 && (last || len(ps.pending) > ps.maxRows) {
+		var n int
+		for k, cols := range ps.slices {
+			t := ps.tables[k]
+			if k != 0 || !ps.fresh {
+				t = pr.pageSetTable(k)
+			}
+			ps.fresh = false
+			pr.table = t
+			if k == 0 {
+				for n < len(ps.pending) && (n == 0 || t.fits(t.style.RowHeight)) {
+					pr.drawRow(t, ps.pending[n], cols, true)
+					n++
+				}
+			} else {
+				for _, record := range ps.pending[:n] {
+					pr.drawRow(t, record, cols, false)
+				}
+			}
+			if last && n == len(ps.pending) {
+				t.finish()
+			} else {
+				t.closeRule()
+				if len(t.totalIdx) != 0 {
+					t.totalRow("Carried forward")
+				}
+			}
+		}
+		ps.pending = ps.pending[n:]
+	}
+}
+
+// withRightKeys returns the part with its columns named in keys repeated
+// after its last column, and their indexes; nil if there is none.
+func (part partDesc) withRightKeys(keys []string) (partDesc, []int) {
+	var idx []int
+	for _, name := range keys {
+		if i := part.columnIndex(strings.TrimSpace(name)); i >= 0 {
+			idx = append(idx, i)
+		}
+	}
+	if idx == nil {
+		return part, nil
+	}
+	order := make([]int, len(part.head), len(part.head)+len(idx))
+	for i := range order {
+		order[i] = i
+	}
+	return part.reorder(append(order, idx...)), idx
+}
+
+// indexOf returns the index of the first i in s, or -1.
+func indexOf(s []int, i int) int {
+	for j, v := range s {
+		if v == i {
+			return j
+		}
+	}
+	return -1
+}
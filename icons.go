@@ -0,0 +1,139 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/pkg/errors"
+)
+
+// iconRule draws an icon by thresholds into the cells of a numeric column:
+// a colored dot (red below lo, yellow below hi, green above), or an arrow
+// (green up above lo, red down below it).
+type iconRule struct {
+	arrow  bool
+	lo, hi float64
+	// only prints the icon instead of the value.
+	only bool
+}
+
+// iconColumns maps the column names to their icon rules.
+type iconColumns map[string]*iconRule
+
+// parseIconColumns parses the "col=dot:lo:hi[:only],col=arrow[:ref][:only],..." spec.
+func parseIconColumns(spec string) (iconColumns, error) {
+	ic := make(iconColumns)
+	for _, item := range strings.Split(spec, ",") {
+		name, rule, _ := strings.Cut(strings.TrimSpace(item), "=")
+		if name == "" {
+			continue
+		}
+		args := strings.Split(rule, ":")
+		var ir iconRule
+		if n := len(args); n > 1 && args[n-1] == "only" {
+			ir.only, args = true, args[:n-1]
+		}
+		nums := make([]float64, len(args)-1)
+		for i, a := range args[1:] {
+			var err error
+			if nums[i], err = strconv.ParseFloat(a, 64); err != nil {
+				return nil, errors.Wrap(err, item)
+			}
+		}
+		switch args[0] {
+		case "dot":
+			if len(nums) != 2 || nums[0] > nums[1] {
+				return nil, errors.Errorf("%s: dot needs lo:hi thresholds", item)
+			}
+			ir.lo, ir.hi = nums[0], nums[1]
+		case "arrow":
+			if len(nums) > 1 {
+				return nil, errors.Errorf("%s: arrow needs at most one reference value", item)
+			}
+			ir.arrow = true
+			if len(nums) == 1 {
+				ir.lo = nums[0]
+			}
+		default:
+			return nil, errors.Errorf("%s: unknown icon %q (dot or arrow)", item, args[0])
+		}
+		ic[name] = &ir
+	}
+	return ic, nil
+}
+
+// apply sets the icon rules of the matching columns of the part.
+func (ic iconColumns) apply(part *partDesc) {
+	for i, h := range part.head {
+		ir, ok := ic[strings.TrimSpace(h)]
+		if !ok && i < len(part.fields) && part.fields[i] != nil {
+			ir, ok = ic[part.fields[i].Name]
+		}
+		if !ok {
+			continue
+		}
+		if part.icons == nil {
+			part.icons = make([]*iconRule, len(part.head))
+		}
+		part.icons[i] = ir
+	}
+}
+
+// iconRule returns the icon rule of the i-th column, or nil.
+func (part partDesc) iconRule(i int) *iconRule {
+	if i < len(part.icons) {
+		return part.icons[i]
+	}
+	return nil
+}
+
+// drawIconCell draws the next cell with the icon of v at its left, and the
+// (encoded) text after it, unless the rule prints the icon only.
+func (t *pdfTable) drawIconCell(ir *iconRule, w, h float64, v, text, border, align string, fill bool) {
+	pdf := t.pdf
+	x, y := pdf.GetXY()
+	pdf.CellFormat(w, h, "", border, 0, "", fill, 0, "")
+	f, _, ok := parseNumber(v)
+	if !ok {
+		pdf.SetXY(x, y)
+		t.fallback.cellFormat(pdf, "", t.style.BodyFontSize, w, h, text, "", align, false)
+		return
+	}
+	size := h / 2
+	ix := x + pdf.GetCellMargin()
+	if ir.only {
+		ix = x + (w-size)/2
+	}
+	cy := y + h/2
+	switch {
+	case !ir.arrow:
+		switch {
+		case f < ir.lo:
+			pdf.SetFillColor(t.rgb(220, 50, 47))
+		case f < ir.hi:
+			pdf.SetFillColor(t.rgb(240, 190, 40))
+		default:
+			pdf.SetFillColor(t.rgb(60, 170, 70))
+		}
+		pdf.Circle(ix+size/2, cy, size/2, "F")
+	case f > ir.lo:
+		pdf.SetFillColor(t.rgb(60, 170, 70))
+		pdf.Polygon([]gofpdf.PointType{{X: ix, Y: cy + size/2}, {X: ix + size, Y: cy + size/2}, {X: ix + size/2, Y: cy - size/2}}, "F")
+	case f < ir.lo:
+		pdf.SetFillColor(t.rgb(220, 50, 47))
+		pdf.Polygon([]gofpdf.PointType{{X: ix, Y: cy - size/2}, {X: ix + size, Y: cy - size/2}, {X: ix + size/2, Y: cy + size/2}}, "F")
+	}
+	t.resetFill(true)
+	if ir.only {
+		pdf.SetXY(x+w, y)
+		return
+	}
+	iw := size + pdf.GetCellMargin()
+	pdf.SetXY(x+iw, y)
+	t.fallback.cellFormat(pdf, "", t.style.BodyFontSize, w-iw, h, text, "", align, false)
+}
@@ -0,0 +1,87 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// addIndexEntry records that v is on the current page.
+func (pr *pdfRenderer) addIndexEntry(v string) {
+	if v = strings.TrimSpace(v); v == "" {
+		return
+	}
+	if pr.index == nil {
+		pr.index = make(map[string][]int)
+	}
+	page := pr.pdf.PageNo()
+	pages := pr.index[v]
+	if len(pages) == 0 || pages[len(pages)-1] != page {
+		pr.index[v] = append(pages, page)
+	}
+}
+
+// addIndex adds the alphabetical index of the collected values,
+// as "value .... 1, 3-5, 8".
+func (pr *pdfRenderer) addIndex() {
+	keys := make([]string, 0, len(pr.index))
+	for k := range pr.index {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pdf := pr.pdf
+	pdf.AddPageFormat("P", pr.defPageSize)
+	pr.tocSection("Index")
+	pdf.SetFont(pr.font, "B", 14)
+	pdf.SetTextColor(0, 0, 0)
+	pdf.CellFormat(0, 10, pr.translator("Index: "+pr.indexColumn), "", 1, "L", false, 0, "")
+	pdf.Ln(2)
+	pdf.SetFont(pr.font, "", 8)
+	lm, _, rm, _ := pdf.GetMargins()
+	pw, _ := pdf.GetPageSize()
+	width := pw - lm - rm
+	var initial rune
+	for _, k := range keys {
+		if r := []rune(strings.ToUpper(k))[0]; r != initial {
+			initial = r
+			pdf.SetFont(pr.font, "B", 10)
+			pdf.CellFormat(0, 7, pr.translator(string(r)), "", 1, "L", false, 0, "")
+			pdf.SetFont(pr.font, "", 8)
+		}
+		key := pr.translator(k)
+		pages := pageRanges(pr.index[k])
+		kw, pw := pdf.GetStringWidth(key), pdf.GetStringWidth(pages)
+		dots := " "
+		// MultiCell keeps the cell margin on both sides
+		if n := int((width - 2*pdf.GetCellMargin() - kw - pw - 2*pdf.GetStringWidth(" ")) / pdf.GetStringWidth(".")); n > 0 {
+			dots = " " + strings.Repeat(".", n) + " "
+		}
+		pdf.MultiCell(0, 4.5, key+dots+pages, "", "L", false)
+	}
+}
+
+// pageRanges formats the sorted page numbers, collapsing runs: "1, 3-5, 8".
+func pageRanges(pages []int) string {
+	var buf strings.Builder
+	for i := 0; i < len(pages); i++ {
+		j := i
+		for j+1 < len(pages) && pages[j+1] == pages[j]+1 {
+			j++
+		}
+		if buf.Len() != 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(strconv.Itoa(pages[i]))
+		if j > i {
+			buf.WriteByte('-')
+			buf.WriteString(strconv.Itoa(pages[j]))
+		}
+		i = j
+	}
+	return buf.String()
+}
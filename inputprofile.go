@@ -0,0 +1,61 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// inputProfile bundles the input settings of an exporter.
+type inputProfile struct {
+	desc       string
+	delimiter  rune
+	charset    string
+	skipRows   int
+	skipBanner bool
+	dateFormat string
+}
+
+// inputProfiles are the profiles of the common ERP exports.
+var inputProfiles = map[string]inputProfile{
+	"sap": {
+		desc:      "SAP list exports: ; delimited ISO-8859-2, with title lines above the table, dd.mm.yyyy dates",
+		delimiter: ';', charset: "iso-8859-2", skipBanner: true, dateFormat: "02.01.2006",
+	},
+	"oracle-reports": {
+		desc:      "Oracle Reports delimited output: , delimited Windows-1250, DD-MON-RR dates",
+		delimiter: ',', charset: "windows-1250", dateFormat: "02-Jan-06",
+	},
+}
+
+// InputProfiles returns the names and the descriptions of the input profiles.
+func InputProfiles() map[string]string {
+	m := make(map[string]string, len(inputProfiles))
+	for name, p := range inputProfiles {
+		m[name] = p.desc
+	}
+	return m
+}
+
+// ApplyInputProfile sets the input options (Delimiter, Charset, SkipRows,
+// SkipBanner, DateFormat) of the named profile; set the options to
+// override after this.
+func (opts *Options) ApplyInputProfile(name string) error {
+	p, ok := inputProfiles[name]
+	if !ok {
+		names := make([]string, 0, len(inputProfiles))
+		for name := range inputProfiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return errors.Errorf("unknown input profile %q (%q)", name, names)
+	}
+	opts.Delimiter, opts.Charset = p.delimiter, p.charset
+	opts.SkipRows, opts.SkipBanner = p.skipRows, p.skipBanner
+	opts.DateFormat = p.dateFormat
+	return nil
+}
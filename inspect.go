@@ -0,0 +1,72 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"bytes"
+	"io"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"github.com/tgulacsi/go/text"
+)
+
+// SampleInfo is what Inspect finds in a sample of the input.
+type SampleInfo struct {
+	// Delimiter is the delimiter of the options, or the detected one.
+	Delimiter rune
+	// ValidUTF8 reports whether the sample is valid UTF-8.
+	ValidUTF8 bool
+	Parts     []SamplePart
+}
+
+// SamplePart describes a part of the sample.
+type SamplePart struct {
+	Head []string
+	Rows int
+	// Width is the width of the table in characters, Landscape reports
+	// whether it is printed in landscape with the default orientation.
+	Width     int
+	Landscape bool
+}
+
+// Inspect reads the sample as Convert reads its input (with the Charset,
+// Delimiter, SkipRows and SkipBanner of the options), and describes it.
+func Inspect(r io.Reader, opts Options) (*SampleInfo, error) {
+	opts.setDefaults()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading sample")
+	}
+	pageSize, err := parsePageSize(opts.PageSize)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing page size %q", opts.PageSize)
+	}
+	enc := text.GetEncoding(opts.Charset)
+	if enc == nil {
+		return nil, errors.Errorf("unknown charset %q", opts.Charset)
+	}
+	info := SampleInfo{Delimiter: opts.Delimiter, ValidUTF8: utf8.Valid(b)}
+	if info.Delimiter == 0 {
+		info.Delimiter = sniffDelimiter(skipHeadLines(b, opts.SkipRows))
+	}
+	var banner rune
+	if opts.SkipBanner {
+		banner = info.Delimiter
+	}
+	dr := text.NewDecodingReader(bytes.NewReader(b), enc)
+	parts, err := parseCsv(newRecordReader(skipLines(dr, opts.SkipRows, banner), info.Delimiter, false), info.Delimiter, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing sample")
+	}
+	for _, part := range parts {
+		width := part.textWidth()
+		info.Parts = append(info.Parts, SamplePart{
+			Head: part.head, Rows: part.lastLine - part.firstLine,
+			Width: width, Landscape: float64(width) > portraitChars(pageSize),
+		})
+	}
+	return &info, nil
+}
@@ -0,0 +1,137 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// pageLayout is the exact layout of a rendered document: the style and the
+// orientation and column widths of each part.
+//
+// A layout saved by one run can be pinned for the following runs, so the
+// output stays the same even if the data (and so the measured widths) varies.
+type pageLayout struct {
+	Style tableStyle   `json:"style"`
+	Parts []partLayout `json:"parts"`
+}
+
+type partLayout struct {
+	Head        []string  `json:"head"`
+	Orientation string    `json:"orientation"`
+	Widths      []float64 `json:"widths"`
+	// Scale is the scale of the fonts shrunk to fit the page, if not 1.
+	Scale float64 `json:"scale,omitempty"`
+}
+
+// loadLayout reads the pinned layout from fn; returns nil if fn does not exist.
+func loadLayout(fn string) (*pageLayout, error) {
+	b, err := os.ReadFile(fn)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var pl pageLayout
+	if err = json.Unmarshal(b, &pl); err != nil {
+		return nil, err
+	}
+	return &pl, nil
+}
+
+// part returns the pinned layout of the i-th part, if it has the same number
+// of columns as part; nil otherwise.
+func (pl *pageLayout) part(i int, part partDesc) *partLayout {
+	if pl == nil {
+		return nil
+	}
+	if i >= len(pl.Parts) || len(pl.Parts[i].Widths) != len(part.head) {
+		log.Printf("pinned layout does not match part %d with head %q, measuring it", i+1, part.head)
+		return nil
+	}
+	return &pl.Parts[i]
+}
+
+// save the layout to fn.
+func (pl *pageLayout) save(fn string) error {
+	b, err := json.MarshalIndent(pl, "", "  ")
+	if err != nil {
+		return err
+	}
+	af, err := createAtomic(fn)
+	if err != nil {
+		return err
+	}
+	defer af.Abort()
+	if _, err = af.Write(b); err != nil {
+		return err
+	}
+	return af.Commit()
+}
+
+// orientations are the forced page orientations ("P" or "L", "" is auto):
+// def of all the parts, parts of the numbered parts (from 1).
+type orientations struct {
+	def   string
+	parts map[int]string
+}
+
+var orientationNames = map[string]string{"portrait": "P", "landscape": "L", "auto": ""}
+
+// parseOrientations parses the "portrait|landscape|auto,N=...,..." spec.
+func parseOrientations(spec string) (orientations, error) {
+	var o orientations
+	for _, item := range strings.Split(spec, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		k, v, perPart := strings.Cut(item, "=")
+		if !perPart {
+			v = k
+		}
+		orientation, ok := orientationNames[strings.ToLower(strings.TrimSpace(v))]
+		if !ok {
+			return o, errors.Errorf("%s: unknown orientation %q (portrait, landscape or auto)", item, v)
+		}
+		if !perPart {
+			o.def = orientation
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(k))
+		if err != nil || n < 1 {
+			return o, errors.Errorf("%s: bad part number %q", item, k)
+		}
+		if o.parts == nil {
+			o.parts = make(map[int]string)
+		}
+		o.parts[n] = orientation
+	}
+	return o, nil
+}
+
+// of returns the orientation of the n-th part (from 1), "" for auto.
+func (o orientations) of(n int) string {
+	if orientation, ok := o.parts[n]; ok {
+		return orientation
+	}
+	return o.def
+}
+
+// spec returns the spec of the n-th part (from 1) as the only part.
+func (o orientations) spec(n int) string {
+	for name, orientation := range orientationNames {
+		if orientation == o.of(n) {
+			return name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,53 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"io"
+)
+
+// widthMeasurer measures the widest value of each column in the pre-pass,
+// with the fonts the pdf is printed with: the proportional fonts make the
+// byte length a poor estimate (iii is narrower than WWW, and the accented
+// letters are longer in UTF-8 than wide).
+type widthMeasurer struct {
+	// pr is an unused renderer, for its document and font chain.
+	pr     *pdfRenderer
+	widths []float64
+}
+
+// newWidthMeasurer returns the measurer of the fonts of the options;
+// tr is the translator of the core fonts.
+func (opts Options) newWidthMeasurer(fontDir string, tr func(string) string) (*widthMeasurer, error) {
+	if opts.FontFile != "" {
+		tr = func(s string) string { return s }
+	}
+	pageSize, err := parsePageSize(opts.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	pr := newPDFRenderer(io.Discard, fontDir, tr, defaultStyle, pageSize)
+	// the renderer logs the font licenses
+	pr.embedAllGlyphs = true
+	if err = opts.setFonts(pr, tr); err != nil {
+		return nil, err
+	}
+	return &widthMeasurer{pr: pr}, nil
+}
+
+func (wm *widthMeasurer) startPart(head []string) { wm.widths = make([]float64, len(head)) }
+
+func (wm *widthMeasurer) observe(_ int, record []string) {
+	for i, v := range record {
+		if v == "" {
+			continue
+		}
+		if w := wm.pr.fallback.stringWidth(wm.pr.pdf, "", 1, v); w > wm.widths[i] {
+			wm.widths[i] = w
+		}
+	}
+}
+
+func (wm *widthMeasurer) finishPart(part *partDesc) { part.fontWidths = wm.widths }
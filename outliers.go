@@ -0,0 +1,204 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// outlierDetector flags the outliers of the numeric columns in the pre-pass,
+// by z-score (farther than factor standard deviations from the mean) or IQR
+// (farther than factor interquartile ranges from the quartiles).
+//
+// It keeps the numeric values of the current part in memory.
+type outlierDetector struct {
+	iqr    bool
+	factor float64
+
+	// values and lines are the numbers per column and their line numbers;
+	// a nil values marks a non-numeric column.
+	values [][]float64
+	lines  [][]int
+}
+
+// minOutlierValues is the minimal count of numbers in a column to look for
+// outliers in it.
+const minOutlierValues = 5
+
+// newOutlierDetector returns the detector for the "z[:factor]" or
+// "iqr[:factor]" spec.
+func newOutlierDetector(spec string) (*outlierDetector, error) {
+	method, factor, hasFactor := strings.Cut(spec, ":")
+	od := outlierDetector{factor: 3}
+	switch method {
+	case "z":
+	case "iqr":
+		od.iqr, od.factor = true, 1.5
+	default:
+		return nil, errors.Errorf("unknown outlier detection method %q (z or iqr)", method)
+	}
+	if hasFactor {
+		var err error
+		if od.factor, err = strconv.ParseFloat(factor, 64); err != nil {
+			return nil, errors.Wrap(err, spec)
+		}
+		if od.factor <= 0 {
+			return nil, errors.Errorf("%s: the factor should be positive", spec)
+		}
+	}
+	return &od, nil
+}
+
+// startPart starts collecting the numbers of a part with the head.
+func (od *outlierDetector) startPart(head []string) {
+	if od == nil {
+		return
+	}
+	od.values, od.lines = make([][]float64, len(head)), make([][]int, len(head))
+	for i := range od.values {
+		od.values[i] = []float64{}
+	}
+}
+
+// observe collects the numbers of the record at line.
+func (od *outlierDetector) observe(line int, record []string) {
+	if od == nil {
+		return
+	}
+	for i, v := range record {
+		if i >= len(od.values) || od.values[i] == nil || strings.TrimSpace(v) == "" {
+			continue
+		}
+		f, _, ok := parseNumber(v)
+		if !ok {
+			od.values[i], od.lines[i] = nil, nil
+			continue
+		}
+		od.values[i] = append(od.values[i], f)
+		od.lines[i] = append(od.lines[i], line)
+	}
+}
+
+// finishPart sets the outlier bounds and the flagged cells of the part.
+func (od *outlierDetector) finishPart(part *partDesc) {
+	if od == nil || len(od.values) != len(part.head) {
+		return
+	}
+	for i, values := range od.values {
+		if len(values) < minOutlierValues {
+			continue
+		}
+		bounds := od.bounds(values)
+		if part.outliers == nil {
+			part.outliers = make([]*numRange, len(part.head))
+		}
+		part.outliers[i] = &bounds
+		for j, f := range values {
+			if f < bounds.Min || f > bounds.Max {
+				part.flagged = append(part.flagged, outlierCell{Line: od.lines[i][j], Column: i, Value: f})
+			}
+		}
+	}
+	sort.SliceStable(part.flagged, func(i, j int) bool { return part.flagged[i].Line < part.flagged[j].Line })
+	od.values, od.lines = nil, nil
+}
+
+// bounds returns the range of the values not flagged.
+func (od *outlierDetector) bounds(values []float64) numRange {
+	if od.iqr {
+		sorted := append([]float64(nil), values...)
+		sort.Float64s(sorted)
+		q1, q3 := quantile(sorted, 0.25), quantile(sorted, 0.75)
+		d := od.factor * (q3 - q1)
+		return numRange{Min: q1 - d, Max: q3 + d, Fixed: true}
+	}
+	var mean, m2 float64
+	for i, f := range values {
+		delta := f - mean
+		mean += delta / float64(i+1)
+		m2 += delta * (f - mean)
+	}
+	d := od.factor * math.Sqrt(m2/float64(len(values)))
+	return numRange{Min: mean - d, Max: mean + d, Fixed: true}
+}
+
+// quantile returns the q quantile of the sorted values, interpolated.
+func quantile(sorted []float64, q float64) float64 {
+	pos := q * float64(len(sorted)-1)
+	i := int(pos)
+	if i+1 >= len(sorted) {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[i] + (sorted[i+1]-sorted[i])*(pos-float64(i))
+}
+
+// outlierCell is a flagged cell of a part.
+type outlierCell struct {
+	Line   int     `json:"line"`
+	Column int     `json:"column"`
+	Value  float64 `json:"value"`
+}
+
+// isOutlier reports whether v is an outlier of the i-th column.
+func (part partDesc) isOutlier(i int, v string) bool {
+	if i >= len(part.outliers) || part.outliers[i] == nil {
+		return false
+	}
+	f, _, ok := parseNumber(v)
+	return ok && (f < part.outliers[i].Min || f > part.outliers[i].Max)
+}
+
+// maxListedOutliers is the maximal number of outliers listed on the summary page.
+const maxListedOutliers = 100
+
+// addOutlierSummary lists the flagged cells of the parts on the summary page.
+func (pr *pdfRenderer) addOutlierSummary(parts []partDesc) {
+	var n int
+	for _, part := range parts {
+		n += len(part.flagged)
+	}
+	if n == 0 {
+		return
+	}
+	pdf := pr.pdf
+	pdf.Ln(6)
+	pdf.SetFont(pr.font, "B", 12)
+	pdf.CellFormat(0, 8, pr.translator("Outliers"), "", 1, "L", false, 0, "")
+	pdf.SetFont(pr.font, "B", 10)
+	pr.colors.setHeader(pdf)
+	widths := []float64{20, 25, 100, 45}
+	for i, h := range []string{"Part", "Line", "Column", "Value"} {
+		pdf.CellFormat(widths[i], 7, pr.translator(h), "1", 0, "C", true, 0, "")
+	}
+	pdf.Ln(-1)
+	pdf.SetTextColor(0, 0, 0)
+	pdf.SetFont(pr.font, "", 8)
+	var listed int
+	for i, part := range parts {
+		for _, c := range part.flagged {
+			if listed == maxListedOutliers {
+				pdf.CellFormat(0, 6, pr.translator("... and "+strconv.Itoa(n-listed)+" more"), "", 1, "L", false, 0, "")
+				return
+			}
+			listed++
+			for j, v := range []string{
+				strconv.Itoa(i + 1), strconv.Itoa(c.Line), pr.translator(part.head[c.Column]),
+				strconv.FormatFloat(c.Value, 'g', -1, 64),
+			} {
+				align := "R"
+				if j == 2 {
+					align = "L"
+				}
+				pdf.CellFormat(widths[j], 6, v, "1", 0, align, false, 0, "")
+			}
+			pdf.Ln(-1)
+		}
+	}
+}
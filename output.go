@@ -0,0 +1,124 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// atomicFile is written under a temporary name in the target directory,
+// and renamed to its final name on Commit only, so a failed run never
+// leaves a truncated file behind.
+type atomicFile struct {
+	*os.File
+	name string
+	done bool
+}
+
+func createAtomic(name string) (*atomicFile, error) {
+	fh, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*")
+	if err != nil {
+		return nil, err
+	}
+	af := &atomicFile{File: fh, name: name}
+	// CreateTemp uses 0600
+	if err = fh.Chmod(0o644); err != nil {
+		af.Abort()
+		return nil, err
+	}
+	return af, nil
+}
+
+// Commit closes the file and renames it to its final name.
+func (af *atomicFile) Commit() error {
+	if af.done {
+		return errors.Errorf("%q is already closed", af.name)
+	}
+	af.done = true
+	if err := af.File.Sync(); err != nil {
+		af.File.Close()
+		os.Remove(af.File.Name())
+		return err
+	}
+	if err := af.File.Close(); err != nil {
+		os.Remove(af.File.Name())
+		return err
+	}
+	if err := os.Rename(af.File.Name(), af.name); err != nil {
+		os.Remove(af.File.Name())
+		return err
+	}
+	return nil
+}
+
+// Abort closes and removes the file, if not committed yet.
+func (af *atomicFile) Abort() {
+	if af.done {
+		return
+	}
+	af.done = true
+	af.File.Close()
+	os.Remove(af.File.Name())
+}
+
+// spoolFile collects the output in a temporary file, to be copied to the
+// real destination only when complete.
+type spoolFile struct {
+	*os.File
+}
+
+func newSpool() (*spoolFile, error) {
+	fh, err := os.CreateTemp("", "csv2pdf-out-")
+	if err != nil {
+		return nil, err
+	}
+	return &spoolFile{File: fh}, nil
+}
+
+// CopyTo copies the spooled output to w. If this fails and w is a regular
+// file, it is truncated, not to leave a partial output behind.
+func (sf *spoolFile) CopyTo(w io.Writer) error {
+	if _, err := sf.File.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	_, err := io.Copy(w, sf.File)
+	if err != nil {
+		if fh, ok := w.(*os.File); ok {
+			if fi, statErr := fh.Stat(); statErr == nil && fi.Mode().IsRegular() {
+				fh.Truncate(0)
+			}
+		}
+	}
+	return err
+}
+
+// Rewrite replaces the spooled output with what f writes reading it.
+func (sf *spoolFile) Rewrite(f func(rs io.ReadSeeker, w io.Writer) error) error {
+	if _, err := sf.File.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	fh, err := os.CreateTemp("", "csv2pdf-out-")
+	if err != nil {
+		return err
+	}
+	if err = f(sf.File, fh); err != nil {
+		fh.Close()
+		os.Remove(fh.Name())
+		return err
+	}
+	sf.Remove()
+	sf.File = fh
+	return nil
+}
+
+// Remove closes and removes the spool file.
+func (sf *spoolFile) Remove() {
+	sf.File.Close()
+	os.Remove(sf.File.Name())
+}
@@ -0,0 +1,71 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// pageMACDigits is the number of the hex digits of the page HMAC printed,
+// pageMACWidth the width of the code in the footer, in mm.
+const (
+	pageMACDigits = 16
+	pageMACWidth  = 25
+)
+
+// setPageMAC sets the key of the per-page HMAC printed in the footer: the
+// HMAC-SHA256 of the rows printed on the page and the page number, so
+// a page printed again from the same input has the same code, and a
+// changed or swapped page has another.
+func (pr *pdfRenderer) setPageMAC(key []byte) error {
+	if key = bytes.TrimSpace(key); len(key) == 0 {
+		return errors.New("empty page HMAC key")
+	}
+	pr.pageMAC = hmac.New(sha256.New, key)
+	pr.footerHooks = append(pr.footerHooks, pr.drawPageMAC)
+	return nil
+}
+
+// macRow adds the record to the HMAC of the page.
+func (pr *pdfRenderer) macRow(record []string) {
+	if pr.pageMAC == nil {
+		return
+	}
+	for i, v := range record {
+		if i != 0 {
+			pr.pageMAC.Write([]byte{0x1f})
+		}
+		pr.pageMAC.Write([]byte(v))
+	}
+	pr.pageMAC.Write([]byte{0x1e})
+}
+
+// pageMACCode returns the code of the page, and starts the next one.
+func pageMACCode(mac hash.Hash, page int) string {
+	mac.Write([]byte("page " + strconv.Itoa(page)))
+	code := hex.EncodeToString(mac.Sum(nil))[:pageMACDigits]
+	mac.Reset()
+	return code
+}
+
+// drawPageMAC prints the code of the page in the bottom right corner.
+func (pr *pdfRenderer) drawPageMAC() {
+	pdf := pr.pdf
+	code := "HMAC " + pageMACCode(pr.pageMAC, pr.flushed+pdf.PageNo())
+	w, h := pdf.GetPageSize()
+	_, _, rm, _ := pdf.GetMargins()
+	pdf.SetFont("Courier", "", 5)
+	pdf.SetTextColor(96, 96, 96)
+	pdf.SetXY(w-rm-pageMACWidth, h-3)
+	pdf.CellFormat(pageMACWidth, 2, code, "", 0, "R", false, 0, "")
+	pdf.SetTextColor(0, 0, 0)
+}
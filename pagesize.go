@@ -0,0 +1,55 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/pkg/errors"
+)
+
+// pageSizes are the named page sizes, in points (as gofpdf has them).
+var pageSizes = map[string]gofpdf.SizeType{
+	"a3":     {Wd: 841.89, Ht: 1190.55},
+	"a4":     {Wd: 595.28, Ht: 841.89},
+	"a5":     {Wd: 420.94, Ht: 595.28},
+	"letter": {Wd: 612, Ht: 792},
+	"legal":  {Wd: 612, Ht: 1008},
+}
+
+// pageUnits are the units of the custom page sizes, in mm.
+var pageUnits = map[string]float64{"mm": 1, "cm": 10, "in": 25.4, "pt": 25.4 / 72}
+
+// parsePageSize parses the page size: A3, A4, A5, Letter, Legal, or WxH in
+// mm, or with a unit (cm, in or pt), as 4x6in. The size is returned in mm.
+func parsePageSize(s string) (gofpdf.SizeType, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if size, ok := pageSizes[s]; ok {
+		k := pageUnits["pt"]
+		return gofpdf.SizeType{Wd: size.Wd * k, Ht: size.Ht * k}, nil
+	}
+	k := 1.0
+	for unit, f := range pageUnits {
+		if strings.HasSuffix(s, unit) {
+			s, k = strings.TrimSpace(strings.TrimSuffix(s, unit)), f
+			break
+		}
+	}
+	w, h, ok := strings.Cut(s, "x")
+	if !ok {
+		return gofpdf.SizeType{}, errors.Errorf("unknown page size (A3, A4, A5, Letter, Legal or WxH)")
+	}
+	wd, err := strconv.ParseFloat(strings.TrimSpace(w), 64)
+	if err != nil || wd <= 0 {
+		return gofpdf.SizeType{}, errors.Errorf("bad width %q", w)
+	}
+	ht, err := strconv.ParseFloat(strings.TrimSpace(h), 64)
+	if err != nil || ht <= 0 {
+		return gofpdf.SizeType{}, errors.Errorf("bad height %q", h)
+	}
+	return gofpdf.SizeType{Wd: wd * k, Ht: ht * k}, nil
+}
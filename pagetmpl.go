@@ -0,0 +1,134 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"io"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// pageVars are the variables of the HeaderTemplate and FooterTemplate.
+type pageVars struct {
+	// Title is the -title, File the name of the input, Date the time of the
+	// generation ("2006-01-02 15:04").
+	Title, File, Date string
+	// Part is the number of the current part, PartTitle its sheet name.
+	Part      int
+	PartTitle string
+	// Page is the number of the page, Pages the number of the pages (empty
+	// with -flush-pages).
+	Page  int
+	Pages string
+	// FirstRow and LastRow are the numbers of the first and the last rows
+	// printed on the page, 0 if there is none.
+	FirstRow, LastRow int
+}
+
+// pageTemplates are the header and the footer text templates of the pages.
+type pageTemplates struct {
+	header, footer *template.Template
+	vars           pageVars
+	// startRows is the number of the rows printed before the page.
+	startRows int
+}
+
+// parsePageTemplates parses the header and the footer templates; nil if
+// both are empty.
+func parsePageTemplates(header, footer, title, fileName string) (*pageTemplates, error) {
+	if header == "" && footer == "" {
+		return nil, nil
+	}
+	pt := pageTemplates{vars: pageVars{
+		Title: title, File: "-",
+		Date: time.Now().Format("2006-01-02 15:04"),
+	}}
+	if fileName != "" {
+		pt.vars.File = filepath.Base(fileName)
+	}
+	for _, t := range []struct {
+		name, text string
+		tmpl       **template.Template
+	}{{"header", header, &pt.header}, {"footer", footer, &pt.footer}} {
+		if t.text == "" {
+			continue
+		}
+		tmpl, err := template.New(t.name).Parse(t.text)
+		if err == nil {
+			// the unknown variables are found before the first page
+			err = tmpl.Execute(io.Discard, pt.vars)
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, t.name+" template")
+		}
+		*t.tmpl = tmpl
+	}
+	return &pt, nil
+}
+
+// setPageTemplates sets the templates of the header and the footer line of
+// the pages. Both are printed at the end of the page, when its rows are
+// known.
+func (pr *pdfRenderer) setPageTemplates(pt *pageTemplates) {
+	pr.pageTmpl = pt
+	if pr.flushPages == 0 {
+		pt.vars.Pages = "{nb}"
+		pr.pdf.AliasNbPages("")
+	}
+	pr.pageHooks = append(pr.pageHooks, func() { pt.startRows = pr.rows })
+	pr.footerHooks = append(pr.footerHooks, pr.drawPageTemplates)
+}
+
+// drawPageTemplates prints the header line above the top margin, and the
+// footer line in the bottom margin, above the -footer line.
+func (pr *pdfRenderer) drawPageTemplates() {
+	pdf, pt := pr.pdf, pr.pageTmpl
+	vars := pt.vars
+	vars.Part, vars.Page = pr.partIdx, pr.flushed+pdf.PageNo()
+	if pr.table != nil {
+		vars.PartTitle = pr.table.part.title
+	}
+	if pr.rows > pt.startRows {
+		vars.FirstRow, vars.LastRow = pt.startRows+1, pr.rows
+	}
+	w, h := pdf.GetPageSize()
+	lm, top, rm, _ := pdf.GetMargins()
+	if pr.qr != nil {
+		rm += qrSize + 2
+	}
+	lineHt := pdf.PointConvert(pageFooterFontSize) * 1.2
+	footerY := h - pageFooterTop
+	if pr.footer != nil {
+		footerY -= lineHt
+	}
+	if pr.footerImage != nil {
+		footerY -= pr.footerImage.heightAt(w)
+	}
+	pdf.SetFont(pr.font, "", pageFooterFontSize)
+	pdf.SetTextColor(64, 64, 64)
+	for _, line := range []struct {
+		tmpl *template.Template
+		y    float64
+	}{
+		{pt.header, maxFloat(top-lineHt-1, 0)},
+		{pt.footer, footerY},
+	} {
+		if line.tmpl == nil {
+			continue
+		}
+		var buf strings.Builder
+		if err := line.tmpl.Execute(&buf, vars); err != nil {
+			pdf.SetError(errors.Wrap(err, line.tmpl.Name()+" template"))
+			return
+		}
+		pdf.SetXY(lm, line.y)
+		pdf.CellFormat(w-lm-rm, lineHt, pr.translator(buf.String()), "", 0, "C", false, 0, "")
+	}
+	pdf.SetTextColor(0, 0, 0)
+}
@@ -0,0 +1,993 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"hash"
+	"io"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/pkg/errors"
+)
+
+// tableRenderer renders the parts of the table to some output format.
+type tableRenderer interface {
+	// StartPart starts a new table with the head of the part.
+	StartPart(part partDesc) error
+	// Row renders a record of the current part.
+	Row(record []string) error
+	// Close finishes the output.
+	Close() error
+}
+
+// paragraphRenderer is implemented by the renderers which can print the
+// free text blocks between the tables.
+type paragraphRenderer interface {
+	Paragraphs(text []string) error
+}
+
+// multiRenderer renders to all of its members.
+type multiRenderer []tableRenderer
+
+func (mr multiRenderer) StartPart(part partDesc) error {
+	for _, r := range mr {
+		if err := r.StartPart(part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (mr multiRenderer) Row(record []string) error {
+	for _, r := range mr {
+		if err := r.Row(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (mr multiRenderer) Paragraphs(text []string) error {
+	for _, r := range mr {
+		if pr, ok := r.(paragraphRenderer); ok {
+			if err := pr.Paragraphs(text); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (mr multiRenderer) Close() error {
+	var firstErr error
+	for _, r := range mr {
+		if err := r.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+type pdfRenderer struct {
+	w           io.Writer
+	pdf         *gofpdf.Fpdf
+	fontDir     string
+	translator  func(string) string
+	defPageSize gofpdf.SizeType
+	table       *pdfTable
+	style       tableStyle
+	colors      Colors
+	// grayscale maps all the colors to gray.
+	grayscale bool
+	// defaultPages estimates the page count with the default style, logged
+	// with the saving of the compact style.
+	defaultPages int
+	compact      bool
+	rows         int
+
+	// truncate is the truncation of too long values.
+	truncate truncSpec
+
+	// pageHooks are called at the start of each page (before the content),
+	// footerHooks at the end of each page.
+	pageHooks, footerHooks []func()
+
+	// background is printed under every page, headerImage and footerImage
+	// across the top and the bottom of every page.
+	background, headerImage, footerImage *pageImage
+	// qr is the QR code of qrContent printed on every page, if not nil.
+	qr        [][]bool
+	qrContent string
+	// pageMAC is the HMAC of the rows of the page, if printed in the footer.
+	pageMAC hash.Hash
+	// footer is the footer line of the pages, if printed.
+	footer *pageFooter
+	// pageTmpl are the header and footer templates of the pages, if any.
+	pageTmpl *pageTemplates
+
+	// disclaimer is the (translated) small print, printed at the end if
+	// disclaimerAtEnd, or on every page by a footer hook.
+	disclaimer      string
+	disclaimerAtEnd bool
+	// provenance is the generation info printed on every page.
+	provenance string
+
+	// font is the font family of the text, fonts are the embedded TrueType
+	// fonts, with all their glyphs if embedAllGlyphs.
+	font           string
+	fonts          []embeddedFont
+	embedAllGlyphs bool
+	// fallback is the chain of the fallback fonts of font, if any.
+	fallback *fontChain
+	shaper   *shaper
+
+	// trace is the layout trace log (-debug-layout), if not nil.
+	trace *log.Logger
+
+	// totalColumns are the names of the columns to be summed; their totals
+	// are brought forward from carried, and put back to it, if set.
+	totalColumns []string
+	carried      *runningTotals
+	carryOn      bool
+	// forms collects the form fields drawn, if any.
+	forms *formFields
+	// age shades the rows by the age of a date, if set.
+	age *ageRule
+	// blockPage is set if the current page ends with text blocks, the
+	// next table may start on it.
+	blockPage bool
+	// ruler is the row number marking interval (0: none), rulerLines adds
+	// guide lines.
+	ruler      int
+	rulerLines bool
+
+	// attachColumn names the column with the paths/URLs of files to be
+	// attached to the rows, relative paths are resolved from attachDir.
+	attachColumn, attachDir string
+	// readFile reads the attached files.
+	readFile  func(string) ([]byte, error)
+	attachIdx int
+
+	// indexColumn names the column whose values are collected into the index.
+	indexColumn string
+	indexIdx    int
+	index       map[string][]int
+
+	// partLinks are the link IDs of the parts on the summary page.
+	partLinks []int
+	partIdx   int
+
+	// rtl draws the tables from right to left.
+	rtl bool
+	// splitWide prints the tables too wide for the page on page sets,
+	// repeating the first keyColumns columns on each page of a set.
+	splitWide  bool
+	keyColumns int
+	pageSet    *pageSet
+	// repeatRight are the names of the key columns repeated at the right
+	// edge of the landscape tables; rightKeys are their indexes in the
+	// current part.
+	repeatRight []string
+	rightKeys   []int
+	// minFontSize is the smallest body font size the tables too wide for
+	// the page are shrunk to; 0 disables the shrinking.
+	minFontSize float64
+	// minColWidth is the narrowest width of the measured columns, in mm.
+	minColWidth float64
+
+	// orientation is the forced orientation of the parts.
+	orientation orientations
+
+	// pinned is the layout to use instead of measuring, layout is the layout
+	// used, saved to layoutFn if not empty.
+	pinned   *pageLayout
+	layout   pageLayout
+	layoutFn string
+
+	// toc is the navigation saved to tocFn, if not nil.
+	toc   *tocDoc
+	tocFn string
+
+	// flushPages is the number of pages after which the document is written
+	// to concat, and a new one is started; flushed is the number of pages
+	// written so.
+	flushPages int
+	concat     *pdfConcat
+	flushed    int
+}
+
+// newPDFRenderer returns a renderer with pages of pageSize (in mm).
+func newPDFRenderer(w io.Writer, fontDir string, translator func(string) string, style tableStyle, pageSize gofpdf.SizeType) *pdfRenderer {
+	pr := &pdfRenderer{
+		w: w, translator: translator, style: style, font: "Arial",
+		colors:  DefaultColors(),
+		shaper:  newShaper(nil),
+		fontDir: fontDir, defPageSize: pageSize,
+	}
+	pr.pdf = pr.newDoc()
+	return pr
+}
+
+// newDoc returns a new document, calling the page hooks.
+func (pr *pdfRenderer) newDoc() *gofpdf.Fpdf {
+	pdf := gofpdf.NewCustom(&gofpdf.InitType{
+		OrientationStr: "P", UnitStr: "mm", Size: pr.defPageSize, FontDirStr: pr.fontDir,
+	})
+	pdf.SetMargins(pr.style.Margin, pr.style.Margin, pr.style.Margin)
+	if pr.style.Padding > 0 {
+		pdf.SetCellMargin(pr.style.Padding)
+	}
+	pdf.SetAutoPageBreak(true, pr.bottomMargin())
+	// reproducible output: the fonts and images in a stable order
+	pdf.SetCatalogSort(true)
+	pdf.SetHeaderFuncMode(func() {
+		for _, f := range pr.pageHooks {
+			f()
+		}
+	}, true)
+	pdf.SetFooterFunc(func() {
+		for _, f := range pr.footerHooks {
+			f()
+		}
+	})
+	return pdf
+}
+
+// bottomMargin returns the bottom margin of the pages, without the
+// stationery strips: room for the QR code above the provenance line.
+func (pr *pdfRenderer) bottomMargin() float64 {
+	if pr.qr != nil {
+		return maxFloat(2*pr.style.Margin, qrSize+5)
+	}
+	return 2 * pr.style.Margin
+}
+
+func (pr *pdfRenderer) StartPart(part partDesc) error {
+	totalWidth := float64(part.textWidth())
+	colwidths := pr.columnWidths(part, pr.style)
+	if part.fontWidths != nil {
+		// measured: the table is as wide as its columns
+		totalWidth = sumFloats(colwidths)
+	}
+	orientation := "P"
+	if totalWidth > portraitChars(pr.defPageSize) {
+		orientation = "L"
+	}
+	forced := pr.orientation.of(len(pr.layout.Parts) + 1)
+	pr.rightKeys = nil
+	if len(pr.repeatRight) != 0 && (forced == "L" || forced == "" && orientation == "L") {
+		part, pr.rightKeys = part.withRightKeys(pr.repeatRight)
+		colwidths = pr.columnWidths(part, pr.style)
+	}
+	scale := 1.0
+	if pl := pr.pinned.part(len(pr.layout.Parts), part); pl != nil {
+		orientation, colwidths = pl.Orientation, pl.Widths
+		if pl.Scale != 0 {
+			scale = pl.Scale
+		}
+	} else if modes := part.truncModes(pr.truncate); hasWrap(modes) {
+		orientation, colwidths = pr.fitWrapped(part, colwidths, modes, forced)
+	} else {
+		if forced != "" {
+			orientation = forced
+		}
+		colwidths, scale = pr.shrinkToFit(part, colwidths, orientation)
+	}
+	if forced != "" {
+		orientation = forced
+	}
+	style := pr.style
+	pl := partLayout{Head: part.head, Orientation: orientation, Widths: colwidths}
+	if scale != 1 {
+		style, pl.Scale = style.scaled(scale), scale
+	}
+	pr.layout.Parts = append(pr.layout.Parts, pl)
+	if pr.table != nil {
+		pr.finishTable()
+	}
+	if !pr.blockPage || !pr.roomFor(orientation, style) {
+		pr.pdf.AddPageFormat(orientation, pr.defPageSize)
+	}
+	pr.blockPage = false
+	pr.linkPart()
+	pr.tocPart(part)
+	if part.title != "" {
+		title := pr.translator(visualOrder(part.title))
+		// the bookmarks are in the catalog, which is not kept on flushing
+		if pr.flushPages == 0 {
+			pr.pdf.Bookmark(title, 0, -1)
+		}
+		pr.pdf.SetFont(pr.font, "B", pr.style.HeaderFontSize+2)
+		align := "L"
+		if pr.rtl {
+			align = "R"
+		}
+		pr.pdf.CellFormat(0, pr.style.HeaderHeight+1, title, "", 1, align, false, 0, "")
+	}
+	if part.caption != "" {
+		pr.pdf.SetFont(pr.font, "", pr.style.BodyFontSize)
+		pr.pdf.MultiCell(0, pr.style.RowHeight*0.8, pr.translator(part.caption), "", "L", false)
+		pr.pdf.Ln(1)
+	}
+
+	tablePart := part
+	tablePart.head = pr.shaper.shapeRecord(part.head)
+	if slices := pr.sliceColumns(colwidths, orientation); slices != nil {
+		pr.pageSet = pr.newPageSet(tablePart, colwidths, orientation, style, slices)
+		pr.table = pr.pageSetTable(0)
+	} else {
+		pr.table = makeTable(pr.pdf, pr.translator, tablePart, colwidths, orientation, pr.defPageSize, style, pr.colors, pr.font, pr.fallback, pr.rtl)
+		pr.setupTable(pr.table)
+	}
+	if pr.trace != nil {
+		pageWidth, _ := pr.pdf.GetPageSize()
+		lm, _, rm, _ := pr.pdf.GetMargins()
+		var sum float64
+		for _, w := range pr.table.colwidths {
+			sum += w
+		}
+		pr.trace.Printf("page %d: part %d %q: orientation=%s (content width %.0f), column widths=%.1f mm, total=%.1f mm of %.1f mm",
+			pr.pdf.PageNo(), pr.partIdx, part.head, orientation, totalWidth, pr.table.colwidths, sum, pageWidth-lm-rm)
+		if sum > pageWidth-lm-rm {
+			pr.trace.Printf("page %d: table overflows the right margin by %.1f mm", pr.pdf.PageNo(), sum-(pageWidth-lm-rm))
+		}
+	}
+	pr.attachIdx = part.columnIndex(pr.attachColumn)
+	pr.indexIdx = part.columnIndex(pr.indexColumn)
+	return pr.pdf.Error()
+}
+
+// setupTable sets the settings of the renderer for the columns of the
+// table of the part (or of a slice of it).
+func (pr *pdfRenderer) setupTable(t *pdfTable) {
+	part := t.part
+	t.trace = pr.trace
+	if pr.flushPages > 0 {
+		t.beforeBreak = pr.flushDoc
+	}
+	t.grayscale = pr.grayscale
+	t.truncs = part.truncModes(pr.truncate)
+	// the translator replaces the unknown runes with a substitute
+	if t.ellipsis = pr.translator("…"); t.ellipsis == pr.translator("\uffff") {
+		t.ellipsis = "..."
+	} else if pr.fallback != nil {
+		t.ellipsis = "…" // encoded by the chain
+	}
+	if len(pr.totalColumns) != 0 {
+		idx := make([]int, 0, len(pr.totalColumns))
+		names := make([]string, 0, len(pr.totalColumns))
+		for _, c := range pr.totalColumns {
+			if i := part.columnIndex(c); i >= 0 {
+				idx, names = append(idx, i), append(names, c)
+			}
+		}
+		t.setTotals(idx)
+		if pr.carried != nil {
+			t.carried, t.totalNames, t.carryOn = pr.carried, names, pr.carryOn
+			// the header is drawn already
+			if pr.carried.bring(t) {
+				t.totalRow("Brought forward")
+			}
+		}
+	}
+	if pr.age != nil {
+		t.age, t.ageIdx = pr.age, part.columnIndex(pr.age.column)
+	}
+	t.ruler, t.rulerLines = pr.ruler, pr.rulerLines
+	t.forms = pr.forms
+	// only the regular and the bold styles of the font files are added
+	t.noItalic = len(pr.fonts) != 0
+}
+
+func (pr *pdfRenderer) Row(record []string) error {
+	if pr.rightKeys != nil {
+		record = append(record[:len(record):len(record)], permute(record, pr.rightKeys)...)
+	}
+	if pr.pageSet != nil {
+		return pr.pageSetRow(record)
+	}
+	return pr.drawRow(pr.table, record, nil, true)
+}
+
+// drawRow draws the record into t: its cols columns, if not nil. The first
+// drawing of a record (primary) is counted, indexed and authenticated.
+func (pr *pdfRenderer) drawRow(t *pdfTable, record []string, cols []int, primary bool) error {
+	full := record
+	if cols != nil {
+		record = permute(full, cols)
+	}
+	t.row(pr.shaper.shapeRecord(record))
+	if primary {
+		pr.macRow(full[:len(full)-len(pr.rightKeys)])
+		pr.rows++
+		if pr.indexIdx >= 0 && pr.indexIdx < len(full) {
+			pr.addIndexEntry(full[pr.indexIdx])
+		}
+	}
+	j := pr.attachIdx
+	if cols != nil {
+		j = indexOf(cols, pr.attachIdx)
+	}
+	if j >= 0 && pr.attachIdx < len(full) && full[pr.attachIdx] != "" {
+		ref := full[pr.attachIdx]
+		a, err := loadAttachment(pr.readFile, pr.attachDir, ref)
+		if errors.Is(err, ErrResourceLimit) {
+			return err
+		} else if err != nil {
+			log.Printf("cannot attach %q: %v", ref, err)
+			return nil
+		}
+		x, _, _, _ := pr.pdf.GetMargins()
+		x += t.columnX(j)
+		pr.pdf.AddAttachmentAnnotation(a, x, t.rowY, t.colwidths[j], t.rowHeight)
+	}
+	return pr.pdf.Error()
+}
+
+// Paragraphs prints the text after the current table, wrapped.
+func (pr *pdfRenderer) Paragraphs(text []string) error {
+	if pr.table != nil {
+		pr.finishTable()
+		pr.table = nil
+	}
+	pdf := pr.pdf
+	if pdf.PageNo() == 0 {
+		pdf.AddPageFormat("P", pr.defPageSize)
+	}
+	pdf.SetFont(pr.font, "", pr.style.BodyFontSize+1)
+	pdf.SetTextColor(0, 0, 0)
+	lineHt := pr.style.RowHeight * 0.8
+	pdf.Ln(lineHt)
+	for _, p := range text {
+		pdf.MultiCell(0, lineHt, pr.translator(p), "", "L", false)
+		pdf.Ln(lineHt / 2)
+	}
+	return pdf.Error()
+}
+
+func (pr *pdfRenderer) Close() error {
+	if pr.table != nil {
+		pr.finishTable()
+	}
+	if pr.compact {
+		log.Printf("%d pages (about %d with the default style)", pr.Pages(), pr.defaultPages)
+	}
+	if pr.index != nil {
+		pr.addIndex()
+	}
+	if pr.disclaimerAtEnd {
+		pr.drawDisclaimer()
+	}
+	pr.finishFonts()
+	if pr.layoutFn != "" {
+		pr.layout.Style = pr.style
+		if err := pr.layout.save(pr.layoutFn); err != nil {
+			return errors.Wrap(err, pr.layoutFn)
+		}
+	}
+	if pr.flushPages > 0 {
+		if err := pr.outputDoc(); err != nil {
+			return err
+		}
+		if err := pr.concat.close(); err != nil {
+			return err
+		}
+	} else if err := pr.pdf.Output(pr.w); err != nil {
+		return err
+	}
+	if pr.toc != nil {
+		return errors.Wrap(pr.saveTOC(), pr.tocFn)
+	}
+	return nil
+}
+
+// finishTable finishes the current table.
+func (pr *pdfRenderer) finishTable() {
+	if pr.pageSet != nil {
+		pr.flushPageSet(true)
+		pr.pageSet = nil
+	} else {
+		pr.table.finish()
+	}
+	pr.tocFinishPart()
+	pr.countDefaultPages()
+}
+
+// countDefaultPages adds the estimated page count of the current table
+// with the default style to defaultPages.
+func (pr *pdfRenderer) countDefaultPages() {
+	h := pr.defPageSize.Ht
+	if pr.table.orientation == "L" {
+		h = pr.defPageSize.Wd
+	}
+	perPage := defaultStyle.rowsPerPage(h)
+	pr.defaultPages += (pr.rows + perPage - 1) / perPage
+	if pr.rows == 0 {
+		pr.defaultPages++
+	}
+	pr.rows = 0
+}
+
+// portraitChars is the width of the widest table (in characters) printed
+// on portrait pages: as many chars fit as the text width in mm at the
+// default margins, 190 on A4.
+func portraitChars(pageSize gofpdf.SizeType) float64 {
+	return pageSize.Wd - 2*defaultStyle.Margin
+}
+
+// pdfTable renders the rows of a part as a table, repeating the header on
+// each new page.
+type pdfTable struct {
+	pdf         *gofpdf.Fpdf
+	translator  func(string) string
+	part        partDesc
+	colwidths   []float64
+	orientation string
+	pageSize    gofpdf.SizeType
+	style       tableStyle
+	colors      Colors
+	grayscale   bool
+	font        string
+	fallback    *fontChain
+	fill        bool
+	// rtl draws the columns from right to left, the text aligned right.
+	rtl bool
+	// age shades the rows by the age of the date in the ageIdx-th column.
+	age    *ageRule
+	ageIdx int
+	// ruler prints the row numbers in the margin every ruler rows, with
+	// guide lines if rulerLines.
+	ruler      int
+	rulerLines bool
+	trace      *log.Logger
+	// beforeBreak is called before starting a new page, and may change pdf.
+	beforeBreak func()
+	rows        int
+	// rowY and rowHeight are the position and the height of the last row.
+	rowY, rowHeight float64
+	// truncs is the truncation mode per column.
+	truncs   []truncMode
+	ellipsis string
+	// noItalic is set if the fonts have no italic style.
+	noItalic bool
+
+	// totals are the running sums of the total columns (totalIdx),
+	// printed as carried/brought forward at page breaks.
+	totalIdx []int
+	totals   []float64
+	decimals []int
+	// carried gets the totals at the end, by totalNames, to be brought
+	// forward in the next file; carryOn is set if there is one.
+	carried    *runningTotals
+	totalNames []string
+	carryOn    bool
+	// forms collects the form fields drawn.
+	forms *formFields
+}
+
+// columnWidths returns the column widths (in mm) of the part: of the values
+// measured with the fonts in the pre-pass when known (then the heads are
+// measured, too), else by their widths in characters.
+func (pr *pdfRenderer) columnWidths(part partDesc, style tableStyle) []float64 {
+	cm := pr.pdf.GetCellMargin()
+	colwidths := make([]float64, len(part.widths))
+	for i, w := range part.widths {
+		if c := part.column(i); c != nil && c.Width > 0 {
+			colwidths[i] = c.Width
+			continue
+		}
+		width := float64(w) * style.CharWidth
+		if part.fontWidths != nil && part.fontWidths[i] > 0 {
+			size := style.BodyFontSize
+			if c := part.column(i); c != nil && c.Bold {
+				// measured with the regular font
+				size *= boldWidthFactor
+			}
+			width = part.fontWidths[i]*size + 2*cm
+		}
+		if part.isVertical(i) {
+			colwidths[i] = maxFloat(width, style.verticalWidth())
+			continue
+		}
+		head := float64(cellWidth(part.head[i])) * style.HeaderCharWidth
+		if style.HeaderAngle != 0 {
+			head = style.verticalWidth()
+		} else if part.fontWidths != nil {
+			head = pr.fallback.stringWidth(pr.pdf, "B", style.HeaderFontSize, part.head[i]) + 2*cm
+		}
+		minWidth := pr.minColWidth
+		if c := part.column(i); c != nil && c.MinWidth > 0 {
+			minWidth = c.MinWidth
+		}
+		colwidths[i] = maxFloat(maxFloat(width, head), minWidth)
+	}
+	return colwidths
+}
+
+// makeTable prepares a table and draws its header.
+func makeTable(pdf *gofpdf.Fpdf, pdfTranslator func(string) string, part partDesc,
+	colwidths []float64, orientation string, pageSize gofpdf.SizeType, style tableStyle, colors Colors, font string, fallback *fontChain,
+	rtl bool,
+) *pdfTable {
+	t := pdfTable{
+		pdf: pdf, translator: pdfTranslator, part: part,
+		orientation: orientation, pageSize: pageSize, style: style, colors: colors, font: font, fallback: fallback,
+		colwidths: colwidths, rtl: rtl,
+	}
+	t.drawHeader()
+	return &t
+}
+
+func (t *pdfTable) drawHeader() {
+	pdf := t.pdf
+	// Colors, line width and bold font
+	t.colors.setHeader(pdf)
+	pdf.SetLineWidth(.3)
+	pdf.SetFont(t.font, "B", t.style.HeaderFontSize)
+
+	// Header
+	hh := t.headerHeight()
+	x := pdf.GetX()
+	for _, i := range t.columns(len(t.part.head)) {
+		v := t.part.head[i]
+		if t.part.isVertical(i) {
+			t.verticalCell("B", t.style.HeaderFontSize, t.colwidths[i], hh, t.encode(v), t.style.headerBorder(), "L", t.style.Fill)
+			continue
+		}
+		if t.style.HeaderAngle != 0 {
+			// the heads are drawn over all the cells
+			v = ""
+		}
+		t.fallback.cellFormat(pdf, "B", t.style.HeaderFontSize, t.colwidths[i], hh, t.encode(v), t.style.headerBorder(), "C", t.style.Fill)
+	}
+	if t.style.HeaderAngle != 0 {
+		t.rotatedHeads(x, pdf.GetY(), hh)
+	}
+	t.drawGroupRules(pdf.GetY(), hh)
+	t.drawFrame(pdf.GetY(), hh, true)
+	pdf.Ln(hh)
+
+	// Color and font restoration
+	t.resetFill(true)
+	pdf.SetTextColor(0, 0, 0)
+	pdf.SetFont(t.font, "", t.style.BodyFontSize)
+	if t.style.Rules {
+		pdf.SetLineWidth(.1)
+	}
+}
+
+// resetFill restores the row stripe fill color after a shaded cell.
+func (t *pdfTable) resetFill(shaded bool) {
+	if shaded {
+		t.pdf.SetFillColor(t.colors.Stripe.R, t.colors.Stripe.G, t.colors.Stripe.B)
+	}
+}
+
+// resetText restores the text color after a highlighted cell, and the
+// font style after an emphasized one.
+func (t *pdfTable) resetText(highlighted bool, fontStyle string) {
+	if highlighted {
+		t.pdf.SetTextColor(0, 0, 0)
+	}
+	if fontStyle != "" {
+		t.pdf.SetFontStyle("")
+	}
+}
+
+// encode translates the text, unless the font chain does it.
+func (t *pdfTable) encode(s string) string {
+	if t.fallback != nil {
+		return s
+	}
+	return t.translator(s)
+}
+
+// textWidth returns the width of the (encoded) text with the current font.
+func (t *pdfTable) textWidth(s string) float64 {
+	if t.fallback != nil {
+		s = t.translator(s)
+	}
+	return t.pdf.GetStringWidth(s)
+}
+
+// truncate the (encoded) text to fit into width.
+func (t *pdfTable) truncate(s string, width float64, mode truncMode) string {
+	if t.fallback == nil {
+		return truncateText(t.pdf, s, width, mode, t.ellipsis)
+	}
+	return truncateMeasured(s, width, mode, t.ellipsis, t.textWidth, true)
+}
+
+// row draws the record, breaking the page before it if it would not fit.
+func (t *pdfTable) row(record []string) {
+	h := t.style.RowHeight
+	wrapped := make([][]string, len(record))
+	for i, v := range record {
+		if wrapped[i] = t.wrap(i, v); len(wrapped[i]) > 1 {
+			h = maxFloat(h, t.style.RowHeight+float64(len(wrapped[i])-1)*t.lineHeight())
+		}
+	}
+	t.breakPage(h)
+	pdf := t.pdf
+	t.rowY, t.rowHeight = pdf.GetY(), h
+	var aged RGB
+	isAged := false
+	if t.age != nil && t.ageIdx >= 0 && t.ageIdx < len(record) {
+		aged, isAged = t.age.color(record[t.ageIdx])
+	}
+
+	for _, i := range t.columns(len(record)) {
+		v := record[i]
+		align := "L"
+		if t.rtl {
+			align = "R"
+		}
+		if i < len(t.part.aligns) && t.part.aligns[i] != "" {
+			align = t.part.aligns[i]
+		}
+		if i < len(t.part.forms) && t.part.forms[i] != formNone {
+			if t.forms != nil {
+				t.forms.add(pdf, t.part.forms[i], t.part.head[i], v, t.colwidths[i], h)
+			}
+			drawFormCell(pdf, t.part.forms[i], t.colwidths[i], h, t.style.rowBorder(), t.fill)
+			continue
+		}
+		r, g, b, shaded := t.part.heatColor(i, v)
+		if !shaded && isAged {
+			r, g, b, shaded = aged.R, aged.G, aged.B, true
+		}
+		fill := t.fill || shaded
+		if shaded {
+			pdf.SetFillColor(t.rgb(r, g, b))
+		}
+		raw := v
+		fontStyle, color := t.part.column(i).textStyle(!t.noItalic)
+		if fontStyle != "" {
+			pdf.SetFontStyle(fontStyle)
+		}
+		outlier := t.part.isOutlier(i, v)
+		if outlier {
+			pdf.SetTextColor(t.rgb(200, 0, 0))
+		} else if color != nil {
+			pdf.SetTextColor(t.rgb(color.R, color.G, color.B))
+		}
+		highlighted := outlier || color != nil
+		v = t.encode(v)
+		if t.part.isVertical(i) {
+			v = t.truncate(v, h-2*pdf.GetCellMargin(), truncEnd)
+			t.verticalCell(fontStyle, t.style.BodyFontSize, t.colwidths[i], h, v, t.style.rowBorder(), "C", fill)
+			t.resetFill(shaded)
+			t.resetText(highlighted, fontStyle)
+			continue
+		}
+		if wrapped[i] != nil {
+			t.wrappedCell(wrapped[i], fontStyle, t.colwidths[i], h, t.style.rowBorder(), align, fill)
+			t.resetFill(shaded)
+			t.resetText(highlighted, fontStyle)
+			continue
+		}
+		if i < len(t.truncs) && t.truncs[i] != truncNone {
+			orig := v
+			v = t.truncate(v, t.colwidths[i]-2*pdf.GetCellMargin(), t.truncs[i])
+			if t.trace != nil && v != orig {
+				t.trace.Printf("page %d: row %d column %q: truncated %q to %q", pdf.PageNo(), t.rows+1, t.part.head[i], orig, v)
+			}
+		} else if t.trace != nil && t.textWidth(v) > t.colwidths[i]-2*pdf.GetCellMargin() {
+			t.trace.Printf("page %d: row %d column %q: %q overflows the %.1f mm cell", pdf.PageNo(), t.rows+1, t.part.head[i], v, t.colwidths[i])
+		}
+		if ir := t.part.iconRule(i); ir != nil {
+			t.drawIconCell(ir, t.colwidths[i], h, raw, v, t.style.rowBorder(), align, fill)
+		} else {
+			t.fallback.cellFormat(pdf, fontStyle, t.style.BodyFontSize, t.colwidths[i], h, v, t.style.rowBorder(), align, fill)
+		}
+		t.resetFill(shaded)
+		t.resetText(highlighted, fontStyle)
+	}
+	t.drawGroupRules(pdf.GetY(), h)
+	t.drawFrame(pdf.GetY(), h, false)
+	t.drawRuler(pdf.GetY(), h)
+	pdf.Ln(h)
+	t.fill = t.style.Fill && !t.fill
+	t.rows++
+
+	for j, i := range t.totalIdx {
+		if i >= len(record) {
+			continue
+		}
+		if f, decimals, ok := parseNumber(record[i]); ok {
+			t.totals[j] += f
+			if decimals > t.decimals[j] {
+				t.decimals[j] = decimals
+			}
+		}
+	}
+}
+
+// columns returns the indexes of the n columns in the order they are
+// drawn: from right to left in the rtl mode.
+func (t *pdfTable) columns(n int) []int {
+	idx := make([]int, n)
+	for i := range idx {
+		if t.rtl {
+			idx[i] = n - 1 - i
+		} else {
+			idx[i] = i
+		}
+	}
+	return idx
+}
+
+// columnX returns the offset of the i-th column from the left margin.
+func (t *pdfTable) columnX(i int) float64 {
+	var x float64
+	for _, j := range t.columns(len(t.colwidths)) {
+		if j == i {
+			break
+		}
+		x += t.colwidths[j]
+	}
+	return x
+}
+
+// wrap returns the lines of the i-th value of a row, if its column wraps.
+func (t *pdfTable) wrap(i int, v string) []string {
+	if i >= len(t.truncs) || t.truncs[i] != truncWrap || t.part.isVertical(i) ||
+		i < len(t.part.forms) && t.part.forms[i] != formNone || t.part.iconRule(i) != nil {
+		return nil
+	}
+	return wrapMeasured(t.encode(v), t.colwidths[i]-2*t.pdf.GetCellMargin(), t.textWidth)
+}
+
+// lineHeight returns the height of the wrapped lines of the rows.
+func (t *pdfTable) lineHeight() float64 {
+	return minFloat(t.pdf.PointConvert(t.style.BodyFontSize)*1.2, t.style.RowHeight)
+}
+
+// wrappedCell draws a cell of height h (the border and the fill), and the
+// lines in it, the first where the single line of a row is.
+func (t *pdfTable) wrappedCell(lines []string, fontStyle string, w, h float64, border, align string, fill bool) {
+	pdf := t.pdf
+	x, y := pdf.GetXY()
+	pdf.CellFormat(w, h, "", border, 0, "", fill, 0, "")
+	lineHt := t.lineHeight()
+	top := y + (t.style.RowHeight-lineHt)/2
+	for j, line := range lines {
+		pdf.SetXY(x, top+float64(j)*lineHt)
+		t.fallback.cellFormat(pdf, fontStyle, t.style.BodyFontSize, w, lineHt, line, "", align, false)
+	}
+	pdf.SetXY(x+w, y)
+}
+
+// breakPage starts a new page, with the header, if a row of height h would
+// not fit on the current one.
+func (t *pdfTable) breakPage(h float64) {
+	pdf := t.pdf
+	_, pageHeight := pdf.GetPageSize()
+	_, bMargin := pdf.GetAutoPageBreak()
+	if !t.fits(h) {
+		if t.trace != nil {
+			t.trace.Printf("page %d: break before row %d (y=%.1f + row %.1f + totals > %.1f mm)",
+				pdf.PageNo(), t.rows+1, pdf.GetY(), h, pageHeight-bMargin)
+		}
+		t.closeRule()
+		if len(t.totalIdx) != 0 {
+			t.totalRow("Carried forward")
+		}
+		if t.beforeBreak != nil {
+			t.beforeBreak()
+			pdf = t.pdf
+		}
+		pdf.AddPageFormat(t.orientation, t.pageSize)
+		t.drawHeader()
+		if len(t.totalIdx) != 0 {
+			t.totalRow("Brought forward")
+		}
+	}
+}
+
+// fits reports whether a row of height h (and the totals after it, if any)
+// fits on the page.
+func (t *pdfTable) fits(h float64) bool {
+	_, pageHeight := t.pdf.GetPageSize()
+	_, bMargin := t.pdf.GetAutoPageBreak()
+	reserve := 0.0
+	if len(t.totalIdx) != 0 {
+		reserve = h
+	}
+	return t.pdf.GetY()+h+reserve <= pageHeight-bMargin
+}
+
+// setTotals sets the columns to be summed.
+func (t *pdfTable) setTotals(idx []int) {
+	t.totalIdx = idx
+	t.totals = make([]float64, len(idx))
+	t.decimals = make([]int, len(idx))
+}
+
+// finish closes the table, printing the totals, if any: carried forward
+// to the next file, if there is one.
+func (t *pdfTable) finish() {
+	t.closeRule()
+	if len(t.totalIdx) == 0 {
+		return
+	}
+	label := "Total"
+	if t.carried != nil {
+		t.carried.keep(t)
+		if t.carryOn {
+			label = "Carried forward"
+		}
+	}
+	t.totalRow(label)
+}
+
+// totalRow prints a row with the current totals, and the label in a cell
+// spanning the columns before the first total column.
+func (t *pdfTable) totalRow(label string) {
+	pdf := t.pdf
+	pdf.SetFont(t.font, "B", t.style.BodyFontSize)
+	values := make([]string, len(t.colwidths))
+	first := len(values)
+	for j, i := range t.totalIdx {
+		values[i] = strconv.FormatFloat(t.totals[j], 'f', t.decimals[j], 64)
+		if i < first {
+			first = i
+		}
+	}
+	var labelWidth float64
+	for _, w := range t.colwidths[:first] {
+		labelWidth += w
+	}
+	drawLabel := func(align string) {
+		if labelWidth > 0 {
+			pdf.CellFormat(labelWidth, t.style.RowHeight, t.translator(label), t.style.totalBorder(), 0, align, false, 0, "")
+		}
+	}
+	if !t.rtl {
+		drawLabel("L")
+	}
+	for _, i := range t.columns(len(values)) {
+		if i < first {
+			continue
+		}
+		align := ""
+		if values[i] != "" {
+			align = "R"
+		}
+		pdf.CellFormat(t.colwidths[i], t.style.RowHeight, values[i], t.style.totalBorder(), 0, align, false, 0, "")
+	}
+	if t.rtl {
+		drawLabel("R")
+	}
+	pdf.Ln(-1)
+	pdf.SetFont(t.font, "", t.style.BodyFontSize)
+}
+
+// parseNumber parses a number, accepting a decimal comma and thousand
+// separator spaces, returning the number of decimals, too.
+func parseNumber(s string) (float64, int, bool) {
+	s = strings.Map(func(r rune) rune {
+		if r == ' ' || r == '\u00a0' || r == '\u202f' {
+			return -1
+		}
+		return r
+	}, s)
+	if strings.IndexByte(s, ',') >= 0 && strings.IndexByte(s, '.') < 0 {
+		s = strings.Replace(s, ",", ".", 1)
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, 0, false
+	}
+	var decimals int
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		decimals = len(s) - i - 1
+	}
+	return f, decimals, true
+}
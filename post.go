@@ -0,0 +1,145 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	// do not create/read the pdfcpu config in the user's config dir
+	api.DisableConfigDir()
+}
+
+// postStep is a post-processing step of the generated PDF, reading it from
+// rs and writing the result to w.
+type postStep struct {
+	name string
+	run  func(rs io.ReadSeeker, w io.Writer, conf *model.Configuration) error
+}
+
+// parsePostSteps parses the "step[:args];..." post-processing pipeline:
+//
+//	rotate:90               rotate the pages (by a multiple of 90°)
+//	crop:box                crop the pages to the pdfcpu box (in mm), e.g. "10" or "[0 0 200 280]"
+//	stamp:text[:desc]       stamp the text on the pages, with the pdfcpu description, e.g. "sc:.5 rel, op:.3"
+//	optimize                optimize (deduplicate) the resources
+//	encrypt:user[:owner]    encrypt with AES-256, the owner password defaults to the user's
+func parsePostSteps(spec string) ([]postStep, error) {
+	var steps []postStep
+	for _, item := range strings.Split(spec, ";") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(item), ":")
+		var run func(rs io.ReadSeeker, w io.Writer, conf *model.Configuration) error
+		switch name {
+		case "":
+			continue
+		case "rotate":
+			deg, err := strconv.Atoi(arg)
+			if err != nil || deg%90 != 0 {
+				return nil, errors.Errorf("%s: rotate needs a multiple of 90 degrees", item)
+			}
+			run = func(rs io.ReadSeeker, w io.Writer, conf *model.Configuration) error {
+				return api.Rotate(rs, w, deg, nil, conf)
+			}
+		case "crop":
+			box, err := api.Box(arg, types.MILLIMETRES)
+			if err != nil {
+				return nil, errors.Wrap(err, item)
+			}
+			run = func(rs io.ReadSeeker, w io.Writer, conf *model.Configuration) error {
+				return api.Crop(rs, w, nil, box, conf)
+			}
+		case "stamp":
+			text, desc, _ := strings.Cut(arg, ":")
+			if text == "" {
+				return nil, errors.Errorf("%s: stamp needs a text", item)
+			}
+			wm, err := api.TextWatermark(text, desc, true, false, types.POINTS)
+			if err != nil {
+				return nil, errors.Wrap(err, item)
+			}
+			run = func(rs io.ReadSeeker, w io.Writer, conf *model.Configuration) error {
+				return api.AddWatermarks(rs, w, nil, wm, conf)
+			}
+		case "optimize":
+			run = api.Optimize
+		case "encrypt":
+			user, owner, _ := strings.Cut(arg, ":")
+			if user == "" {
+				return nil, errors.Errorf("%s: encrypt needs a password", item)
+			}
+			if owner == "" {
+				owner = user
+			}
+			run = func(rs io.ReadSeeker, w io.Writer, _ *model.Configuration) error {
+				return api.Encrypt(rs, w, model.NewAESConfiguration(user, owner, 256))
+			}
+		default:
+			return nil, errors.Errorf("%s: unknown step %q (rotate, crop, stamp, optimize or encrypt)", item, name)
+		}
+		steps = append(steps, postStep{name: name, run: run})
+	}
+	return steps, nil
+}
+
+// postProcess runs the steps on the spooled PDF, in order.
+func postProcess(out *spoolFile, steps []postStep) error {
+	for _, step := range steps {
+		step := step
+		if err := out.Rewrite(func(rs io.ReadSeeker, w io.Writer) error {
+			conf := model.NewDefaultConfiguration()
+			conf.ValidationMode = model.ValidationRelaxed
+			return step.run(rs, w, conf)
+		}); err != nil {
+			return errors.Wrap(err, step.name)
+		}
+	}
+	return nil
+}
+
+// stationeryStep returns the step laying the first page of the PDF fn under
+// the pages. pdfcpu reads the file by name, so if it is in an FS (inFS),
+// it is copied to a temporary file, removed by cleanup.
+func stationeryStep(readFile func(string) ([]byte, error), fn string, inFS bool) (postStep, func(), error) {
+	cleanup := func() {}
+	if inFS {
+		b, err := readFile(fn)
+		if err != nil {
+			return postStep{}, cleanup, err
+		}
+		fh, err := os.CreateTemp("", "csv2pdf-stationery-*.pdf")
+		if err != nil {
+			return postStep{}, cleanup, errors.Wrap(err, "creating tempfile")
+		}
+		cleanup = func() { os.Remove(fh.Name()) }
+		if _, err = fh.Write(b); err == nil {
+			err = fh.Close()
+		}
+		if err != nil {
+			fh.Close()
+			cleanup()
+			return postStep{}, func() {}, errors.Wrap(err, "writing tempfile")
+		}
+		fn = fh.Name()
+	} else if _, err := os.Stat(fn); err != nil {
+		return postStep{}, cleanup, err
+	}
+	wm, err := api.PDFWatermark(fn+":1", "sc:1 rel, rot:0, op:1", false, false, types.POINTS)
+	if err != nil {
+		cleanup()
+		return postStep{}, func() {}, err
+	}
+	return postStep{name: "stationery", run: func(rs io.ReadSeeker, w io.Writer, conf *model.Configuration) error {
+		return api.AddWatermarks(rs, w, nil, wm, conf)
+	}}, cleanup, nil
+}
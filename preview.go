@@ -0,0 +1,63 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// renderPreview renders the first page of pdfFn to dest, using the poppler
+// utilities as rasterizing backend: pdftoppm for PNG/JPEG, pdftocairo for SVG.
+// size is the length of the longer side in pixels (ignored for SVG).
+func renderPreview(dest, pdfFn string, size int) error {
+	cmd, written, err := previewCommand(dest, pdfFn, size)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	cmd.Stderr = &buf
+	if err := cmd.Run(); err != nil {
+		return errors.Wrapf(err, "%s: %s", cmd.Args, buf.String())
+	}
+	if written != dest {
+		return errors.Wrapf(os.Rename(written, dest), "renaming %q", written)
+	}
+	return nil
+}
+
+// previewCommand returns the command rendering the preview and the name of
+// the file it writes, or an error naming the missing tool if it is not on
+// the PATH.
+func previewCommand(dest, pdfFn string, size int) (cmd *exec.Cmd, written string, err error) {
+	var args []string
+	written = dest
+	switch ext := strings.ToLower(filepath.Ext(dest)); ext {
+	case ".png", ".jpg", ".jpeg":
+		typ, suffix := "-png", ".png"
+		if ext != ".png" {
+			typ, suffix = "-jpeg", ".jpg"
+		}
+		// pdftoppm appends the extension (.png or .jpg) itself
+		base := strings.TrimSuffix(dest, filepath.Ext(dest))
+		written = base + suffix
+		args = []string{"pdftoppm", typ, "-f", "1", "-l", "1", "-singlefile",
+			"-scale-to", strconv.Itoa(size), pdfFn, base}
+	case ".svg":
+		args = []string{"pdftocairo", "-svg", "-f", "1", "-l", "1", pdfFn, dest}
+	default:
+		return nil, "", errors.Errorf("unknown preview format %q (known: .png, .jpg, .jpeg, .svg)", ext)
+	}
+	if _, err := exec.LookPath(args[0]); err != nil {
+		return nil, "", errors.Errorf("the preview needs %s of poppler-utils, not found in the PATH", args[0])
+	}
+	return exec.Command(args[0], args[1:]...), written, nil
+}
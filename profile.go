@@ -0,0 +1,182 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+// columnProfiler measures the information content (entropy) of the columns
+// in the pre-pass.
+//
+// It counts at most maxDistinct distinct values per column, the rest are
+// taken as unique.
+type columnProfiler struct {
+	rows   int
+	counts []map[string]int
+	// others counts the values not counted for the full columns.
+	others []int
+}
+
+// maxDistinct is the maximal number of distinct values counted per column.
+const maxDistinct = 1 << 14
+
+// columnProfile is the entropy of a column; Constant is set if all its
+// values are the same Value.
+type columnProfile struct {
+	Entropy  float64 `json:"entropy"`
+	Constant bool    `json:"constant,omitempty"`
+	Value    string  `json:"value,omitempty"`
+}
+
+func (cp *columnProfiler) startPart(head []string) {
+	cp.rows = 0
+	cp.counts, cp.others = make([]map[string]int, len(head)), make([]int, len(head))
+	for i := range cp.counts {
+		cp.counts[i] = make(map[string]int)
+	}
+}
+
+func (cp *columnProfiler) observe(line int, record []string) {
+	cp.rows++
+	for i, v := range record {
+		if i >= len(cp.counts) {
+			break
+		}
+		if _, ok := cp.counts[i][v]; ok || len(cp.counts[i]) < maxDistinct {
+			cp.counts[i][v]++
+		} else {
+			cp.others[i]++
+		}
+	}
+}
+
+func (cp *columnProfiler) finishPart(part *partDesc) {
+	if len(cp.counts) != len(part.head) {
+		return
+	}
+	part.profile = make([]columnProfile, len(cp.counts))
+	n := float64(cp.rows)
+	for i, counts := range cp.counts {
+		var h float64
+		for _, c := range counts {
+			p := float64(c) / n
+			h -= p * math.Log2(p)
+		}
+		h += float64(cp.others[i]) / n * math.Log2(n)
+		part.profile[i].Entropy = h
+		if len(counts) == 1 && cp.others[i] == 0 {
+			for v := range counts {
+				part.profile[i].Constant, part.profile[i].Value = true, v
+			}
+		}
+	}
+	cp.counts, cp.others = nil, nil
+}
+
+// entropyOrder returns the column order putting the identifying columns
+// (almost all values distinct) first, the near-constant ones last, keeping
+// the original order otherwise; nil if the order does not change.
+func (part partDesc) entropyOrder() []int {
+	if len(part.profile) != len(part.head) {
+		return nil
+	}
+	rows := part.lastLine - part.firstLine
+	if rows < 2 {
+		return nil
+	}
+	maxH := math.Log2(float64(rows))
+	rank := func(i int) int {
+		switch h := part.profile[i].Entropy / maxH; {
+		case h >= 0.9:
+			return 0
+		case h < 0.1:
+			return 2
+		default:
+			return 1
+		}
+	}
+	order := make([]int, len(part.head))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return rank(order[i]) < rank(order[j]) })
+	for i, j := range order {
+		if i != j {
+			return order
+		}
+	}
+	return nil
+}
+
+// dropConstant returns the order (nil means the original) without the
+// constant columns, keeping at least one column.
+func (part partDesc) dropConstant(order []int) []int {
+	if len(part.profile) != len(part.head) || part.lastLine-part.firstLine < 2 {
+		return order
+	}
+	cols := order
+	if cols == nil {
+		cols = make([]int, len(part.head))
+		for i := range cols {
+			cols[i] = i
+		}
+	}
+	kept := make([]int, 0, len(cols))
+	for _, i := range cols {
+		if !part.profile[i].Constant {
+			kept = append(kept, i)
+		}
+	}
+	if len(kept) == 0 || len(kept) == len(cols) {
+		return order
+	}
+	return kept
+}
+
+// constantNote returns the "head: value" notes of the constant columns.
+func (part partDesc) constantNote() string {
+	var notes []string
+	for i, p := range part.profile {
+		if p.Constant && i < len(part.head) {
+			notes = append(notes, strings.TrimSpace(part.head[i])+": "+p.Value)
+		}
+	}
+	return strings.Join(notes, "; ")
+}
+
+// reorder returns the part with the columns in the order.
+func (part partDesc) reorder(order []int) partDesc {
+	part.head = permute(part.head, order)
+	part.widths = permute(part.widths, order)
+	part.fontWidths = permute(part.fontWidths, order)
+	part.aligns = permute(part.aligns, order)
+	part.forms = permute(part.forms, order)
+	part.vertical = permute(part.vertical, order)
+	part.groups = permute(part.groups, order)
+	part.columns = permute(part.columns, order)
+	part.icons = permute(part.icons, order)
+	part.heat = permute(part.heat, order)
+	part.outliers = permute(part.outliers, order)
+	part.fields = permute(part.fields, order)
+	part.profile = permute(part.profile, order)
+	return part
+}
+
+// permute returns the elements of s in the order; nil for nil.
+func permute[T any](s []T, order []int) []T {
+	if s == nil {
+		return nil
+	}
+	p := make([]T, len(order))
+	for i, j := range order {
+		if j < len(s) {
+			p[i] = s[j]
+		}
+	}
+	return p
+}
@@ -0,0 +1,66 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// setProvenance records who, where, when and how generated the document:
+// in the metadata (the full command line as the Creator), and in a tiny
+// footer line on each page.
+func (pr *pdfRenderer) setProvenance(args []string) {
+	userName := "unknown"
+	if u, err := user.Current(); err == nil {
+		userName = u.Username
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	now := time.Now()
+	cmd := commandLine(args)
+
+	pdf := pr.pdf
+	pdf.SetAuthor(userName+"@"+host, true)
+	pdf.SetCreator(cmd, true)
+	pdf.SetCreationDate(now)
+	pr.provenance = pr.translator("Generated by " + userName + "@" + host +
+		" at " + now.Format("2006-01-02 15:04:05 -0700") + ": " + cmd)
+	pr.footerHooks = append(pr.footerHooks, pr.drawProvenance)
+}
+
+// drawProvenance prints the provenance line at the bottom of the page.
+func (pr *pdfRenderer) drawProvenance() {
+	pdf := pr.pdf
+	w, h := pdf.GetPageSize()
+	lm, _, rm, _ := pdf.GetMargins()
+	pdf.SetFont(pr.font, "", 4)
+	pdf.SetTextColor(128, 128, 128)
+	if pr.pageMAC != nil {
+		// left of the page HMAC
+		rm += pageMACWidth
+	}
+	pdf.SetXY(lm, h-3)
+	pdf.CellFormat(w-lm-rm, 2, truncateText(pdf, pr.provenance, w-lm-rm-2*pdf.GetCellMargin(), truncEnd, "..."),
+		"", 0, "L", false, 0, "")
+	pdf.SetTextColor(0, 0, 0)
+}
+
+// commandLine returns the args as a shell command line, quoting where needed.
+func commandLine(args []string) string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		if a == "" || strings.ContainsAny(a, " \t\n\"'\\$`*?;&|<>()") {
+			a = strconv.Quote(a)
+		}
+		quoted[i] = a
+	}
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,58 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// qrSize is the side of the QR code, in mm.
+const qrSize = 14
+
+// setQR sets the QR code printed in the bottom right corner of every page,
+// so a printed page can be traced back to its source.
+func (pr *pdfRenderer) setQR(content string) error {
+	q, err := qrcode.New(content, qrcode.Medium)
+	if err != nil {
+		return errors.Wrapf(err, "encoding %q as QR code", content)
+	}
+	pr.qr, pr.qrContent = q.Bitmap(), content
+	pr.footerHooks = append(pr.footerHooks, pr.drawQR)
+	pr.pdf.SetAutoPageBreak(true, pr.bottomMargin())
+	return nil
+}
+
+// drawQR draws the QR code (with its quiet zone) as black squares, merging
+// the runs of dark modules in a row; a URL is linked, too.
+func (pr *pdfRenderer) drawQR() {
+	pdf := pr.pdf
+	w, h := pdf.GetPageSize()
+	_, _, rm, _ := pdf.GetMargins()
+	x0, y0 := w-rm-qrSize, h-qrSize-4
+	if pr.footerImage != nil {
+		y0 -= pr.footerImage.heightAt(w)
+	}
+	module := float64(qrSize) / float64(len(pr.qr))
+	pdf.SetFillColor(0, 0, 0)
+	for i, row := range pr.qr {
+		for j := 0; j < len(row); j++ {
+			if !row[j] {
+				continue
+			}
+			k := j
+			for k < len(row) && row[k] {
+				k++
+			}
+			pdf.Rect(x0+float64(j)*module, y0+float64(i)*module, float64(k-j)*module, module, "F")
+			j = k
+		}
+	}
+	if strings.Contains(pr.qrContent, "://") {
+		pdf.LinkString(x0, y0, qrSize, qrSize, pr.qrContent)
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// qualityChecker scores the parts in the pre-pass: the empty cells and the
+// type consistency per column, the malformed dates and the duplicate keys.
+//
+// It keeps the keys of the current part in memory.
+type qualityChecker struct {
+	// key is the name of the key column; the first column if empty.
+	key string
+
+	keyIdx int
+	keys   map[string]struct{}
+	q      *partQuality
+}
+
+// partQuality is the data-quality scorecard of a part.
+type partQuality struct {
+	Rows    int             `json:"rows"`
+	Columns []columnQuality `json:"columns"`
+	// Key is the key column, DupKeys the number of repeated keys in it.
+	Key     string `json:"key"`
+	DupKeys int    `json:"dupKeys"`
+}
+
+// columnQuality counts the empty cells, the cells per kind and the
+// malformed dates of a column.
+type columnQuality struct {
+	Empty    int            `json:"empty"`
+	Kinds    map[string]int `json:"kinds"`
+	BadDates int            `json:"badDates"`
+}
+
+func newQualityChecker(key string) *qualityChecker {
+	return &qualityChecker{key: key}
+}
+
+// startPart starts scoring a part with the head.
+func (qc *qualityChecker) startPart(head []string) {
+	if qc == nil {
+		return
+	}
+	qc.keyIdx = 0
+	for i, h := range head {
+		if strings.TrimSpace(h) == qc.key {
+			qc.keyIdx = i
+			break
+		}
+	}
+	qc.keys = make(map[string]struct{})
+	qc.q = &partQuality{Columns: make([]columnQuality, len(head))}
+	if len(head) != 0 {
+		qc.q.Key = strings.TrimSpace(head[qc.keyIdx])
+	}
+	for i := range qc.q.Columns {
+		qc.q.Columns[i].Kinds = make(map[string]int)
+	}
+}
+
+// observe scores the data row.
+func (qc *qualityChecker) observe(line int, record []string) {
+	if qc == nil || qc.q == nil {
+		return
+	}
+	qc.q.Rows++
+	for i, v := range record {
+		if i >= len(qc.q.Columns) {
+			break
+		}
+		c := &qc.q.Columns[i]
+		kind, ok := valueKind(v)
+		if kind == "" {
+			c.Empty++
+			continue
+		}
+		c.Kinds[kind]++
+		if !ok {
+			c.BadDates++
+		}
+	}
+	if qc.keyIdx < len(record) {
+		k := record[qc.keyIdx]
+		if _, dup := qc.keys[k]; dup {
+			qc.q.DupKeys++
+		} else {
+			qc.keys[k] = struct{}{}
+		}
+	}
+}
+
+// finishPart sets the scorecard of the part.
+func (qc *qualityChecker) finishPart(part *partDesc) {
+	if qc == nil || qc.q == nil {
+		return
+	}
+	part.quality, qc.q, qc.keys = qc.q, nil, nil
+}
+
+var dateLike = regexp.MustCompile(`^\d{1,4}[-./]\d{1,2}[-./]\d{1,4}([ T]\d{1,2}:\d{2}(:\d{2})?)?$`)
+
+var dateLayouts = []string{
+	"2006-01-02", "2006.01.02", "2006/01/02", "02.01.2006", "01/02/2006", "02/01/2006", "2006.01.02.",
+}
+
+// valueKind returns the kind of v: "" for empty, "number", "date" or "text";
+// ok is false for malformed dates.
+func valueKind(v string) (kind string, ok bool) {
+	if v = strings.TrimSpace(v); v == "" {
+		return "", true
+	}
+	if _, _, isNum := parseNumber(v); isNum {
+		return "number", true
+	}
+	if !dateLike.MatchString(v) {
+		return "text", true
+	}
+	date, _, _ := strings.Cut(strings.Replace(v, "T", " ", 1), " ")
+	for _, layout := range dateLayouts {
+		if _, err := time.Parse(layout, date); err == nil {
+			return "date", true
+		}
+	}
+	return "date", false
+}
+
+// dominant returns the most frequent kind of the column and its count.
+func (c columnQuality) dominant() (string, int) {
+	var kind string
+	var n int
+	for k, m := range c.Kinds {
+		if m > n || m == n && k < kind {
+			kind, n = k, m
+		}
+	}
+	return kind, n
+}
+
+// score is the percentage of the cells not empty, of their column's kind
+// and not malformed.
+func (q *partQuality) score() float64 {
+	if q.Rows == 0 || len(q.Columns) == 0 {
+		return 100
+	}
+	var good int
+	for _, c := range q.Columns {
+		kind, n := c.dominant()
+		if kind == "date" {
+			n -= c.BadDates
+		}
+		good += n
+	}
+	return 100 * float64(good) / float64(q.Rows*len(q.Columns))
+}
+
+// percent formats n/total as percentage.
+func percent(n, total int) string {
+	if total == 0 {
+		return "-"
+	}
+	return strconv.FormatFloat(100*float64(n)/float64(total), 'f', 1, 64) + "%"
+}
+
+// addQualityReport adds the data-quality scorecard of the parts.
+func (pr *pdfRenderer) addQualityReport(parts []partDesc) {
+	pdf := pr.pdf
+	pdf.AddPageFormat("P", pr.defPageSize)
+	pr.tocSection("Data quality")
+	pdf.SetFont(pr.font, "B", 14)
+	pdf.SetTextColor(0, 0, 0)
+	pdf.CellFormat(0, 10, pr.translator("Data quality"), "", 1, "L", false, 0, "")
+	widths := []float64{70, 25, 25, 30, 30}
+	for i, part := range parts {
+		q := part.quality
+		if q == nil {
+			continue
+		}
+		title := fmt.Sprintf("Part %d", i+1)
+		if part.title != "" {
+			title += ": " + part.title
+		}
+		pdf.Ln(2)
+		pdf.SetFont(pr.font, "B", 10)
+		pdf.CellFormat(0, 7, pr.translator(fmt.Sprintf("%s (%d rows), score %.1f%%", title, q.Rows, q.score())), "", 1, "L", false, 0, "")
+		pdf.SetFont(pr.font, "", 8)
+		pdf.CellFormat(0, 6, pr.translator(fmt.Sprintf("Duplicate keys in %q: %d", q.Key, q.DupKeys)), "", 1, "L", false, 0, "")
+
+		pdf.SetFont(pr.font, "B", 8)
+		pr.colors.setHeader(pdf)
+		for j, h := range []string{"Column", "Empty", "Type", "Consistent", "Malformed dates"} {
+			pdf.CellFormat(widths[j], 6, pr.translator(h), "1", 0, "C", true, 0, "")
+		}
+		pdf.Ln(-1)
+		pdf.SetTextColor(0, 0, 0)
+		pdf.SetFont(pr.font, "", 8)
+		for j, c := range q.Columns {
+			kind, n := c.dominant()
+			bad := "-"
+			if kind == "date" || c.BadDates != 0 {
+				bad = strconv.Itoa(c.BadDates)
+			}
+			for k, v := range []string{
+				pr.translator(part.head[j]), percent(c.Empty, q.Rows), kind, percent(n, q.Rows-c.Empty), bad,
+			} {
+				align := "R"
+				if k == 0 || k == 2 {
+					align = "L"
+				}
+				pdf.CellFormat(widths[k], 5, v, "1", 0, align, false, 0, "")
+			}
+			pdf.Ln(-1)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/pkg/errors"
+)
+
+// receiptRenderer prints the records for thermal receipt printers, on a
+// narrow roll: one "head: value" line per field (wrapped if needed), the
+// records separated by dashed lines.
+//
+// A part is printed on one page as long as its records (continuous roll),
+// or each record on its own page; so the records of a part are kept in
+// memory till its end.
+type receiptRenderer struct {
+	w          io.Writer
+	pdf        *gofpdf.Fpdf
+	translator func(string) string
+	width      float64
+	perRecord  bool
+
+	title   string
+	head    []string
+	records [][]string
+}
+
+// receipt layout, in mm and points.
+const (
+	receiptMargin   = 3
+	receiptFontSize = 8
+	receiptLineHt   = 3.6
+	receiptSepHt    = 3
+)
+
+// parseReceipt parses the "58|80[:record]" receipt profile: the roll width
+// in mm, and whether each record gets its own page.
+func parseReceipt(spec string) (width float64, perRecord bool, err error) {
+	w, mode, _ := strings.Cut(spec, ":")
+	switch mode {
+	case "":
+	case "record":
+		perRecord = true
+	default:
+		return 0, false, errors.Errorf("unknown receipt mode %q (record)", mode)
+	}
+	if width, err = strconv.ParseFloat(w, 64); err != nil || width < 2*receiptMargin+20 {
+		return 0, false, errors.Errorf("bad roll width %q (58 or 80 mm)", w)
+	}
+	return width, perRecord, nil
+}
+
+func newReceiptRenderer(w io.Writer, fontDir string, translator func(string) string, width float64, perRecord bool) *receiptRenderer {
+	pdf := gofpdf.New("P", "mm", "A4", fontDir)
+	pdf.SetMargins(receiptMargin, receiptMargin, receiptMargin)
+	pdf.SetAutoPageBreak(false, 0)
+	pdf.SetCatalogSort(true)
+	pdf.SetFont("Arial", "", receiptFontSize)
+	return &receiptRenderer{w: w, pdf: pdf, translator: translator, width: width, perRecord: perRecord}
+}
+
+func (rr *receiptRenderer) StartPart(part partDesc) error {
+	rr.flush()
+	rr.title, rr.head = part.title, part.head
+	return rr.pdf.Error()
+}
+
+func (rr *receiptRenderer) Row(record []string) error {
+	rr.records = append(rr.records, append([]string(nil), record...))
+	if rr.perRecord {
+		rr.flush()
+	}
+	return rr.pdf.Error()
+}
+
+func (rr *receiptRenderer) Close() error {
+	rr.flush()
+	if rr.pdf.PageNo() == 0 {
+		rr.addPage(2 * receiptMargin)
+	}
+	return rr.pdf.Output(rr.w)
+}
+
+// lines returns the (translated) "head: value" lines of the record.
+func (rr *receiptRenderer) lines(record []string) []string {
+	lines := make([]string, 0, len(record))
+	for i, v := range record {
+		if i < len(rr.head) {
+			v = strings.TrimSpace(rr.head[i]) + ": " + v
+		}
+		lines = append(lines, rr.translator(v))
+	}
+	return lines
+}
+
+// height returns the height of the wrapped lines.
+func (rr *receiptRenderer) height(lines []string) float64 {
+	cw := rr.width - 2*receiptMargin
+	var n int
+	for _, line := range lines {
+		n += len(rr.pdf.SplitLines([]byte(line), cw))
+	}
+	return float64(n) * receiptLineHt
+}
+
+func (rr *receiptRenderer) addPage(height float64) {
+	rr.pdf.AddPageFormat("P", gofpdf.SizeType{Wd: rr.width, Ht: height})
+}
+
+// flush prints the kept records on a page fitting them.
+func (rr *receiptRenderer) flush() {
+	if len(rr.records) == 0 {
+		return
+	}
+	pdf := rr.pdf
+	records := make([][]string, len(rr.records))
+	height := float64(2 * receiptMargin)
+	if rr.title != "" {
+		height += receiptLineHt + receiptSepHt
+	}
+	for i, record := range rr.records {
+		records[i] = rr.lines(record)
+		height += rr.height(records[i])
+		if i != 0 {
+			height += receiptSepHt
+		}
+	}
+	rr.records = rr.records[:0]
+	rr.addPage(height)
+	cw := rr.width - 2*receiptMargin
+	if rr.title != "" {
+		pdf.SetFont("Arial", "B", receiptFontSize)
+		pdf.CellFormat(cw, receiptLineHt, rr.translator(rr.title), "", 1, "C", false, 0, "")
+		rr.separator()
+	}
+	pdf.SetFont("Arial", "", receiptFontSize)
+	for i, lines := range records {
+		if i != 0 {
+			rr.separator()
+		}
+		for _, line := range lines {
+			pdf.MultiCell(cw, receiptLineHt, line, "", "L", false)
+		}
+	}
+}
+
+// separator draws a dashed line in the middle of the separator gap.
+func (rr *receiptRenderer) separator() {
+	pdf := rr.pdf
+	y := pdf.GetY() + receiptSepHt/2
+	pdf.SetDashPattern([]float64{1, 1}, 0)
+	pdf.SetLineWidth(0.1)
+	pdf.Line(receiptMargin, y, rr.width-receiptMargin, y)
+	pdf.SetDashPattern(nil, 0)
+	pdf.SetY(pdf.GetY() + receiptSepHt)
+}
@@ -0,0 +1,295 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"strings"
+
+	"golang.org/x/text/unicode/bidi"
+)
+
+// bidiClass returns the bidirectional class of r.
+func bidiClass(r rune) bidi.Class {
+	p, _ := bidi.LookupRune(r)
+	return p.Class()
+}
+
+// hasRTL reports whether s has right-to-left (Hebrew, Arabic) letters.
+func hasRTL(s string) bool {
+	for _, r := range s {
+		if r >= 0x0590 {
+			if c := bidiClass(r); c == bidi.R || c == bidi.AL {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// mirrored are the pairs of the mirrored glyphs.
+var mirrored = map[rune]rune{
+	'(': ')', ')': '(', '[': ']', ']': '[', '{': '}', '}': '{',
+	'<': '>', '>': '<', '«': '»', '»': '«',
+}
+
+// visualOrder returns the single line s in visual (left to right) order,
+// with a simplified Unicode bidirectional algorithm: no explicit
+// embeddings, the paragraph direction is that of the first strong letter.
+// The numbers stay left to right, the neutrals between letters of the same
+// direction take it, the others the paragraph's; the brackets of the right
+// to left runs are mirrored.
+func visualOrder(s string) string {
+	if !hasRTL(s) {
+		return s
+	}
+	runes := []rune(s)
+	classes := make([]bidi.Class, len(runes))
+	var base uint8
+	baseSet := false
+	for i, r := range runes {
+		classes[i] = bidiClass(r)
+		if !baseSet {
+			switch classes[i] {
+			case bidi.L:
+				baseSet = true
+			case bidi.R, bidi.AL:
+				base, baseSet = 1, true
+			}
+		}
+	}
+
+	// the separators between digits and the terminators next to them
+	// (1,000.5 and 25%) are parts of the numbers
+	isNum := func(c bidi.Class) bool { return c == bidi.EN || c == bidi.AN }
+	for i := 1; i+1 < len(classes); i++ {
+		if (classes[i] == bidi.CS || classes[i] == bidi.ES) && isNum(classes[i-1]) && isNum(classes[i+1]) {
+			classes[i] = classes[i-1]
+		}
+	}
+	for i, c := range classes {
+		if c != bidi.ET {
+			continue
+		}
+		j := i
+		for j < len(classes) && classes[j] == bidi.ET {
+			j++
+		}
+		if (i > 0 && classes[i-1] == bidi.EN) || (j < len(classes) && classes[j] == bidi.EN) {
+			for k := i; k < j; k++ {
+				classes[k] = bidi.EN
+			}
+		}
+	}
+	// the numbers after a left to right letter are left to right letters
+	lastStrong := bidi.L
+	if base == 1 {
+		lastStrong = bidi.R
+	}
+	for i, c := range classes {
+		switch c {
+		case bidi.L, bidi.R, bidi.AL:
+			lastStrong = c
+		case bidi.EN:
+			if lastStrong == bidi.L {
+				classes[i] = bidi.L
+			}
+		}
+	}
+
+	// the direction of the strong and number classes, -1 for the neutrals
+	dir := func(c bidi.Class) int {
+		switch c {
+		case bidi.L:
+			return 0
+		case bidi.R, bidi.AL, bidi.EN, bidi.AN:
+			return 1
+		}
+		return -1
+	}
+	levels := make([]uint8, len(runes))
+	for i := 0; i < len(runes); {
+		d := dir(classes[i])
+		if d >= 0 {
+			switch {
+			case classes[i] == bidi.L:
+				levels[i] = base + base
+			case classes[i] == bidi.R || classes[i] == bidi.AL:
+				levels[i] = 1
+			default: // numbers
+				levels[i] = 2
+			}
+			i++
+			continue
+		}
+		// a run of neutrals takes the direction of the surrounding letters,
+		// if they agree, else the paragraph's
+		j := i
+		for j < len(runes) && dir(classes[j]) < 0 {
+			j++
+		}
+		before, after := int(base), int(base)
+		if i > 0 {
+			before = dir(classes[i-1])
+		}
+		if j < len(runes) {
+			after = dir(classes[j])
+		}
+		lvl := base
+		if before == after {
+			lvl = uint8(before)
+			if base == 1 && lvl == 0 {
+				lvl = 2
+			}
+		}
+		// the trailing whitespace is at the paragraph level
+		if j == len(runes) {
+			lvl = base
+		}
+		for k := i; k < j; k++ {
+			levels[k] = lvl
+		}
+		i = j
+	}
+
+	// reverse the runs from the highest level to the lowest odd one
+	var highest uint8
+	for _, l := range levels {
+		if l > highest {
+			highest = l
+		}
+	}
+	for i, r := range runes {
+		if levels[i]%2 == 1 {
+			if m, ok := mirrored[r]; ok {
+				runes[i] = m
+			}
+		}
+	}
+	for lvl := highest; lvl >= 1; lvl-- {
+		for i := 0; i < len(runes); {
+			if levels[i] < lvl {
+				i++
+				continue
+			}
+			j := i
+			for j < len(runes) && levels[j] >= lvl {
+				j++
+			}
+			for a, b := i, j-1; a < b; a, b = a+1, b-1 {
+				runes[a], runes[b] = runes[b], runes[a]
+				levels[a], levels[b] = levels[b], levels[a]
+			}
+			i = j
+		}
+	}
+	return string(runes)
+}
+
+// arabicForms are the presentation forms of the Arabic letters: the
+// isolated form, followed by the final, and for the dual joining letters
+// the initial and the medial forms.
+var arabicForms = map[rune]struct {
+	isolated rune
+	dual     bool
+}{
+	0x0621: {0xFE80, false}, 0x0622: {0xFE81, false}, 0x0623: {0xFE83, false},
+	0x0624: {0xFE85, false}, 0x0625: {0xFE87, false}, 0x0626: {0xFE89, true},
+	0x0627: {0xFE8D, false}, 0x0628: {0xFE8F, true}, 0x0629: {0xFE93, false},
+	0x062A: {0xFE95, true}, 0x062B: {0xFE99, true}, 0x062C: {0xFE9D, true},
+	0x062D: {0xFEA1, true}, 0x062E: {0xFEA5, true}, 0x062F: {0xFEA9, false},
+	0x0630: {0xFEAB, false}, 0x0631: {0xFEAD, false}, 0x0632: {0xFEAF, false},
+	0x0633: {0xFEB1, true}, 0x0634: {0xFEB5, true}, 0x0635: {0xFEB9, true},
+	0x0636: {0xFEBD, true}, 0x0637: {0xFEC1, true}, 0x0638: {0xFEC5, true},
+	0x0639: {0xFEC9, true}, 0x063A: {0xFECD, true}, 0x0641: {0xFED1, true},
+	0x0642: {0xFED5, true}, 0x0643: {0xFED9, true}, 0x0644: {0xFEDD, true},
+	0x0645: {0xFEE1, true}, 0x0646: {0xFEE5, true}, 0x0647: {0xFEE9, true},
+	0x0648: {0xFEED, false}, 0x0649: {0xFEEF, false}, 0x064A: {0xFEF1, true},
+	// Persian
+	0x067E: {0xFB56, true}, 0x0686: {0xFB7A, true}, 0x0698: {0xFB8A, false},
+	0x06A9: {0xFB8E, true}, 0x06AF: {0xFB92, true}, 0x06CC: {0xFBFC, true},
+}
+
+// lamAlef are the isolated lam-alef ligatures of the alefs, the final
+// form follows each.
+var lamAlef = map[rune]rune{0x0622: 0xFEF5, 0x0623: 0xFEF7, 0x0625: 0xFEF9, 0x0627: 0xFEFB}
+
+const (
+	arabicLam     = 0x0644
+	arabicTatweel = 0x0640
+	// the hamza (0621) does not join
+	arabicHamza = 0x0621
+)
+
+// isHarakat reports whether r is an Arabic vowel mark, transparent for joining.
+func isHarakat(r rune) bool { return r >= 0x064B && r <= 0x065F || r == 0x0670 }
+
+// shapeArabic replaces the Arabic letters with their presentation forms
+// joining their neighbours, and lam-alef with its ligature, as the simple
+// fonts have no shaping tables. s is in logical order.
+func shapeArabic(s string) string {
+	if strings.IndexFunc(s, func(r rune) bool { return r >= 0x0600 && r <= 0x06FF }) < 0 {
+		return s
+	}
+	runes := []rune(s)
+	// neighbour returns the index of the letter before (step -1) or after
+	// (step 1) i, skipping the vowel marks, or -1.
+	neighbour := func(i, step int) int {
+		for i += step; i >= 0 && i < len(runes); i += step {
+			if !isHarakat(runes[i]) {
+				return i
+			}
+		}
+		return -1
+	}
+	joinsNext := func(i int) bool { // i joins the letter after it
+		if i < 0 {
+			return false
+		}
+		f, ok := arabicForms[runes[i]]
+		return runes[i] == arabicTatweel || ok && f.dual
+	}
+	joinsPrev := func(i int) bool { // i joins the letter before it
+		if i < 0 {
+			return false
+		}
+		_, ok := arabicForms[runes[i]]
+		return runes[i] == arabicTatweel || ok && runes[i] != arabicHamza
+	}
+	out := make([]rune, 0, len(runes))
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		f, ok := arabicForms[r]
+		if !ok || r == arabicHamza {
+			out = append(out, r)
+			continue
+		}
+		prev, next := neighbour(i, -1), neighbour(i, 1)
+		fromPrev := joinsNext(prev)
+		if r == arabicLam && next >= 0 {
+			if lig, ok := lamAlef[runes[next]]; ok {
+				if fromPrev {
+					lig++
+				}
+				out = append(out, lig)
+				// keep the vowel marks between them
+				out = append(out, runes[i+1:next]...)
+				i = next
+				continue
+			}
+		}
+		toNext := f.dual && joinsPrev(next)
+		switch {
+		case fromPrev && toNext:
+			out = append(out, f.isolated+3)
+		case toNext:
+			out = append(out, f.isolated+2)
+		case fromPrev:
+			out = append(out, f.isolated+1)
+		default:
+			out = append(out, f.isolated)
+		}
+	}
+	return string(out)
+}
@@ -0,0 +1,50 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import "strconv"
+
+// rulerLineWidth is the width of the guide lines under the ruler rows, in mm.
+const rulerLineWidth = 0.1
+
+// drawRuler prints the number of the row (from y down h) in the margin
+// before the table (after it in rtl), if it is every ruler-th row, and a
+// thin guide line under it if rulerLines is set.
+func (t *pdfTable) drawRuler(y, h float64) {
+	n := t.rows + 1
+	if t.ruler <= 0 || n%t.ruler != 0 {
+		return
+	}
+	pdf := t.pdf
+	left, _, _, _ := pdf.GetMargins()
+	var width float64
+	for _, w := range t.colwidths {
+		width += w
+	}
+	x := pdf.GetX()
+	size := t.style.BodyFontSize * 0.7
+	pdf.SetFontSize(size)
+	pdf.SetTextColor(t.rgb(128, 128, 128))
+	if t.rtl {
+		pageWidth, _ := pdf.GetPageSize()
+		pdf.SetXY(left+width+1, y)
+		pdf.CellFormat(pageWidth-left-width-1, h, strconv.Itoa(n), "", 0, "L", false, 0, "")
+	} else {
+		pdf.SetXY(0, y)
+		pdf.CellFormat(left-1, h, strconv.Itoa(n), "", 0, "R", false, 0, "")
+	}
+	pdf.SetTextColor(0, 0, 0)
+	pdf.SetFontSize(t.style.BodyFontSize)
+	if t.rulerLines {
+		lw := pdf.GetLineWidth()
+		r, g, b := pdf.GetDrawColor()
+		pdf.SetLineWidth(rulerLineWidth)
+		pdf.SetDrawColor(t.rgb(160, 160, 160))
+		pdf.Line(left, y+h, left+width, y+h)
+		pdf.SetDrawColor(r, g, b)
+		pdf.SetLineWidth(lw)
+	}
+	pdf.SetXY(x, y)
+}
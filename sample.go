@@ -0,0 +1,110 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// rowSampler keeps a uniform random sample of at most n rows of a part
+// (reservoir sampling), and the statistics of all the rows.
+type rowSampler struct {
+	n    int
+	rnd  *rand.Rand
+	seen int
+	rows []sampledRow
+	cols []columnStats
+}
+
+type sampledRow struct {
+	line   int
+	record []string
+}
+
+// columnStats are the statistics of the numbers of a column;
+// a column with text is not numeric.
+type columnStats struct {
+	numbers, empty int
+	text           bool
+	min, max, sum  float64
+}
+
+func newRowSampler(part partDesc, n int, rnd *rand.Rand) *rowSampler {
+	return &rowSampler{n: n, rnd: rnd, rows: make([]sampledRow, 0, n), cols: make([]columnStats, len(part.head))}
+}
+
+// Add offers the record at line to the sample.
+func (rs *rowSampler) Add(line int, record []string) {
+	rs.seen++
+	if len(rs.rows) < rs.n {
+		rs.rows = append(rs.rows, sampledRow{line: line, record: record})
+	} else if j := rs.rnd.Intn(rs.seen); j < rs.n {
+		rs.rows[j] = sampledRow{line: line, record: record}
+	}
+	for i, v := range record {
+		if i >= len(rs.cols) {
+			break
+		}
+		c := &rs.cols[i]
+		if strings.TrimSpace(v) == "" {
+			c.empty++
+			continue
+		}
+		f, _, ok := parseNumber(v)
+		if !ok {
+			c.text = true
+			continue
+		}
+		if c.numbers == 0 || f < c.min {
+			c.min = f
+		}
+		if c.numbers == 0 || f > c.max {
+			c.max = f
+		}
+		c.sum += f
+		c.numbers++
+	}
+}
+
+// Each calls f with the sampled records, in their input order.
+func (rs *rowSampler) Each(f func([]string) error) error {
+	sort.Slice(rs.rows, func(i, j int) bool { return rs.rows[i].line < rs.rows[j].line })
+	for _, row := range rs.rows {
+		if err := f(row.record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sampleTitle returns the title of the part labeled as a sample.
+func sampleTitle(title string, n, total int, seed int64) string {
+	if n > total {
+		n = total
+	}
+	if title != "" {
+		title += " - "
+	}
+	return title + fmt.Sprintf("SAMPLE: %d random rows of %d (seed %d)", n, total, seed)
+}
+
+// Stats returns the statistics of all the rows of the part, a line per
+// numeric column.
+func (rs *rowSampler) Stats(head []string) []string {
+	lines := []string{fmt.Sprintf("Statistics of all the %d rows:", rs.seen)}
+	format := func(f float64) string { return strconv.FormatFloat(f, 'g', 10, 64) }
+	for i, c := range rs.cols {
+		if c.text || c.numbers == 0 {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s: min %s, max %s, mean %s, %d empty",
+			strings.TrimSpace(head[i]), format(c.min), format(c.max), format(c.sum/float64(c.numbers)), c.empty))
+	}
+	return lines
+}
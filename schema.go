@@ -0,0 +1,334 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"encoding/json"
+	"log"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// tableSchema is the common subset of a Frictionless Data Table Schema
+// and a CSVW table description.
+type tableSchema struct {
+	Fields []schemaField
+
+	violations int
+}
+
+type schemaField struct {
+	Name, Title, Type, Format string
+	Required                  bool
+	MinLength, MaxLength      int
+	Minimum, Maximum          *float64
+	Enum                      []string
+	Pattern                   *regexp.Regexp
+}
+
+// maxReportedViolations limits the number of constraint violations logged.
+const maxReportedViolations = 20
+
+// loadSchema reads the schema from fn, which may be a datapackage.json,
+// a bare Table Schema or a CSVW metadata document.
+// csvFn is used to find the matching resource/table, if there are several.
+// The files are read by readFile.
+func loadSchema(readFile func(string) ([]byte, error), fn, csvFn string) (*tableSchema, error) {
+	b, err := readFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	var doc struct {
+		// Table Schema
+		Fields []frictionlessField `json:"fields"`
+		// Data Package
+		Resources []struct {
+			Name   string          `json:"name"`
+			Path   json.RawMessage `json:"path"`
+			Schema json.RawMessage `json:"schema"`
+		} `json:"resources"`
+		// CSVW
+		csvwTable
+		Tables []csvwTable `json:"tables"`
+	}
+	if err = json.Unmarshal(b, &doc); err != nil {
+		return nil, errors.Wrap(err, "parse")
+	}
+	base := filepath.Base(csvFn)
+	switch {
+	case len(doc.Fields) != 0:
+		return newFrictionlessSchema(doc.Fields)
+
+	case len(doc.Resources) != 0:
+		res := doc.Resources[0]
+		for _, r := range doc.Resources[1:] {
+			var path string
+			if json.Unmarshal(r.Path, &path) == nil && filepath.Base(path) == base {
+				res = r
+				break
+			}
+		}
+		var ts struct {
+			Fields []frictionlessField `json:"fields"`
+		}
+		var schemaFn string
+		if json.Unmarshal(res.Schema, &schemaFn) == nil {
+			// the schema is referenced, not inlined
+			if res.Schema, err = readFile(filepath.Join(filepath.Dir(fn), schemaFn)); err != nil {
+				return nil, err
+			}
+		}
+		if err = json.Unmarshal(res.Schema, &ts); err != nil {
+			return nil, errors.Wrapf(err, "parse schema of resource %q", res.Name)
+		}
+		return newFrictionlessSchema(ts.Fields)
+
+	case len(doc.TableSchema.Columns) != 0:
+		return newCSVWSchema(doc.csvwTable)
+
+	case len(doc.Tables) != 0:
+		tbl := doc.Tables[0]
+		for _, t := range doc.Tables[1:] {
+			if filepath.Base(t.URL) == base {
+				tbl = t
+				break
+			}
+		}
+		return newCSVWSchema(tbl)
+	}
+	return nil, errors.New("no fields/columns found")
+}
+
+type frictionlessField struct {
+	Name        string `json:"name"`
+	Title       string `json:"title"`
+	Type        string `json:"type"`
+	Format      string `json:"format"`
+	Constraints struct {
+		Required  bool              `json:"required"`
+		MinLength int               `json:"minLength"`
+		MaxLength int               `json:"maxLength"`
+		Minimum   json.RawMessage   `json:"minimum"`
+		Maximum   json.RawMessage   `json:"maximum"`
+		Pattern   string            `json:"pattern"`
+		Enum      []json.RawMessage `json:"enum"`
+	} `json:"constraints"`
+}
+
+func newFrictionlessSchema(fields []frictionlessField) (*tableSchema, error) {
+	ts := tableSchema{Fields: make([]schemaField, len(fields))}
+	for i, f := range fields {
+		c := f.Constraints
+		sf := schemaField{
+			Name: f.Name, Title: f.Title, Type: f.Type, Format: f.Format,
+			Required: c.Required, MinLength: c.MinLength, MaxLength: c.MaxLength,
+			Minimum: rawFloat(c.Minimum), Maximum: rawFloat(c.Maximum),
+		}
+		for _, e := range c.Enum {
+			var s string
+			if json.Unmarshal(e, &s) != nil {
+				s = string(e)
+			}
+			sf.Enum = append(sf.Enum, s)
+		}
+		if c.Pattern != "" {
+			var err error
+			// Table Schema patterns must match the whole value
+			if sf.Pattern, err = regexp.Compile("^(?:" + c.Pattern + ")$"); err != nil {
+				return nil, errors.Wrapf(err, "pattern of %q", f.Name)
+			}
+		}
+		ts.Fields[i] = sf
+	}
+	return &ts, nil
+}
+
+type csvwTable struct {
+	URL         string `json:"url"`
+	TableSchema struct {
+		Columns []struct {
+			Name     string          `json:"name"`
+			Titles   json.RawMessage `json:"titles"`
+			Datatype json.RawMessage `json:"datatype"`
+			Required bool            `json:"required"`
+		} `json:"columns"`
+	} `json:"tableSchema"`
+}
+
+func newCSVWSchema(tbl csvwTable) (*tableSchema, error) {
+	ts := tableSchema{Fields: make([]schemaField, 0, len(tbl.TableSchema.Columns))}
+	for _, c := range tbl.TableSchema.Columns {
+		sf := schemaField{Name: c.Name, Required: c.Required}
+		// titles may be a string, an array or a language map
+		var titles []string
+		var title string
+		var langTitles map[string]json.RawMessage
+		if json.Unmarshal(c.Titles, &title) == nil {
+			titles = []string{title}
+		} else if json.Unmarshal(c.Titles, &titles) != nil && json.Unmarshal(c.Titles, &langTitles) == nil {
+			for _, v := range langTitles {
+				if json.Unmarshal(v, &title) == nil {
+					titles = append(titles, title)
+				} else {
+					var ss []string
+					_ = json.Unmarshal(v, &ss)
+					titles = append(titles, ss...)
+				}
+			}
+		}
+		if len(titles) != 0 {
+			sf.Title = titles[0]
+		}
+		// datatype may be a string or an object with a "base"
+		var dt struct {
+			Base      string          `json:"base"`
+			Format    string          `json:"format"`
+			MinLength int             `json:"minLength"`
+			MaxLength int             `json:"maxLength"`
+			Minimum   json.RawMessage `json:"minimum"`
+			Maximum   json.RawMessage `json:"maximum"`
+		}
+		if json.Unmarshal(c.Datatype, &sf.Type) != nil && json.Unmarshal(c.Datatype, &dt) == nil {
+			sf.Type, sf.Format = dt.Base, dt.Format
+			sf.MinLength, sf.MaxLength = dt.MinLength, dt.MaxLength
+			sf.Minimum, sf.Maximum = rawFloat(dt.Minimum), rawFloat(dt.Maximum)
+		}
+		sf.Type = csvwTypes[sf.Type]
+		ts.Fields = append(ts.Fields, sf)
+	}
+	return &ts, nil
+}
+
+// csvwTypes maps the XSD based CSVW datatypes to Table Schema types.
+var csvwTypes = map[string]string{
+	"": "string", "string": "string", "normalizedString": "string", "token": "string",
+	"number": "number", "double": "number", "float": "number", "decimal": "number",
+	"integer": "integer", "long": "integer", "int": "integer", "short": "integer", "byte": "integer",
+	"nonNegativeInteger": "integer", "positiveInteger": "integer",
+	"boolean": "boolean", "date": "date", "dateTime": "datetime", "time": "time",
+}
+
+func rawFloat(raw json.RawMessage) *float64 {
+	if len(raw) == 0 {
+		return nil
+	}
+	var f float64
+	if json.Unmarshal(raw, &f) != nil {
+		return nil
+	}
+	return &f
+}
+
+// apply matches the fields to the columns of the part (by name, or by position
+// if no name matches and the count is the same), replaces the header with the
+// field titles and right-aligns numeric columns.
+func (ts *tableSchema) apply(part *partDesc) {
+	byName := make(map[string]*schemaField, len(ts.Fields))
+	for i := range ts.Fields {
+		byName[ts.Fields[i].Name] = &ts.Fields[i]
+	}
+	fields := make([]*schemaField, len(part.head))
+	var found bool
+	for i, h := range part.head {
+		if fields[i] = byName[strings.TrimSpace(h)]; fields[i] != nil {
+			found = true
+		}
+	}
+	if !found {
+		if len(ts.Fields) != len(part.head) {
+			log.Printf("schema does not match part with head %q", part.head)
+			return
+		}
+		for i := range ts.Fields {
+			fields[i] = &ts.Fields[i]
+		}
+	}
+	part.fields = fields
+	if part.aligns == nil {
+		part.aligns = make([]string, len(part.head))
+	}
+	for i, f := range fields {
+		if f == nil {
+			continue
+		}
+		if f.Title != "" {
+			part.head[i] = f.Title
+		}
+		if f.Type == "number" || f.Type == "integer" {
+			part.aligns[i] = "R"
+		}
+	}
+}
+
+// check the record against the field constraints, logging the violations.
+func (ts *tableSchema) check(line int, part partDesc, record []string) {
+	for i, f := range part.fields {
+		if f == nil || i >= len(record) {
+			continue
+		}
+		if msg := f.check(record[i]); msg != "" {
+			ts.violations++
+			if ts.violations <= maxReportedViolations {
+				log.Printf("line %d: column %q: %s", line, part.head[i], msg)
+			}
+		}
+	}
+}
+
+func (f *schemaField) check(v string) string {
+	if v == "" {
+		if f.Required {
+			return "required value is missing"
+		}
+		return ""
+	}
+	if n := len([]rune(v)); f.MinLength > 0 && n < f.MinLength {
+		return "value " + strconv.Quote(v) + " is shorter than " + strconv.Itoa(f.MinLength)
+	} else if f.MaxLength > 0 && n > f.MaxLength {
+		return "value " + strconv.Quote(v) + " is longer than " + strconv.Itoa(f.MaxLength)
+	}
+	if f.Pattern != nil && !f.Pattern.MatchString(v) {
+		return "value " + strconv.Quote(v) + " does not match " + f.Pattern.String()
+	}
+	if len(f.Enum) != 0 {
+		var ok bool
+		for _, e := range f.Enum {
+			if ok = e == v; ok {
+				break
+			}
+		}
+		if !ok {
+			return "value " + strconv.Quote(v) + " is not one of " + strings.Join(f.Enum, ", ")
+		}
+	}
+	switch f.Type {
+	case "number", "integer":
+		x, err := strconv.ParseFloat(v, 64)
+		if err == nil && f.Type == "integer" {
+			_, err = strconv.ParseInt(v, 10, 64)
+		}
+		if err != nil {
+			return "value " + strconv.Quote(v) + " is not a valid " + f.Type
+		}
+		if f.Minimum != nil && x < *f.Minimum {
+			return "value " + v + " is less than " + strconv.FormatFloat(*f.Minimum, 'f', -1, 64)
+		}
+		if f.Maximum != nil && x > *f.Maximum {
+			return "value " + v + " is greater than " + strconv.FormatFloat(*f.Maximum, 'f', -1, 64)
+		}
+	}
+	return ""
+}
+
+// report the number of violations, if there are more than reported.
+func (ts *tableSchema) report() {
+	if ts.violations > maxReportedViolations {
+		log.Printf("%d schema constraint violations (first %d reported)", ts.violations, maxReportedViolations)
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// script levels of the text pieces
+const (
+	scriptSub   = -1
+	scriptNone  = 0
+	scriptSuper = 1
+)
+
+// superscripts and subscripts map the Unicode super- and subscript
+// characters to the base characters, printed smaller and shifted.
+var superscripts, subscripts = map[rune]rune{
+	'⁰': '0', '¹': '1', '²': '2', '³': '3', '⁴': '4', '⁵': '5', '⁶': '6', '⁷': '7', '⁸': '8', '⁹': '9',
+	'⁺': '+', '⁻': '-', '⁼': '=', '⁽': '(', '⁾': ')',
+	'ᵃ': 'a', 'ᵇ': 'b', 'ᶜ': 'c', 'ᵈ': 'd', 'ᵉ': 'e', 'ᶠ': 'f', 'ᵍ': 'g', 'ʰ': 'h', 'ⁱ': 'i', 'ʲ': 'j',
+	'ᵏ': 'k', 'ˡ': 'l', 'ᵐ': 'm', 'ⁿ': 'n', 'ᵒ': 'o', 'ᵖ': 'p', 'ʳ': 'r', 'ˢ': 's', 'ᵗ': 't', 'ᵘ': 'u',
+	'ᵛ': 'v', 'ʷ': 'w', 'ˣ': 'x', 'ʸ': 'y', 'ᶻ': 'z',
+}, map[rune]rune{
+	'₀': '0', '₁': '1', '₂': '2', '₃': '3', '₄': '4', '₅': '5', '₆': '6', '₇': '7', '₈': '8', '₉': '9',
+	'₊': '+', '₋': '-', '₌': '=', '₍': '(', '₎': ')',
+	'ₐ': 'a', 'ₑ': 'e', 'ₕ': 'h', 'ᵢ': 'i', 'ⱼ': 'j', 'ₖ': 'k', 'ₗ': 'l', 'ₘ': 'm', 'ₙ': 'n', 'ₒ': 'o',
+	'ₚ': 'p', 'ᵣ': 'r', 'ₛ': 's', 'ₜ': 't', 'ᵤ': 'u', 'ᵥ': 'v', 'ₓ': 'x',
+}
+
+// textPiece is a piece of text on one script level.
+type textPiece struct {
+	text  string
+	level int
+}
+
+// splitScripts splits s to pieces by script level: the Unicode super- and
+// subscript characters, and with markup, the "^{...}" and "_{...}" parts.
+func splitScripts(s string, markup bool) []textPiece {
+	var pieces []textPiece
+	var buf strings.Builder
+	level := scriptNone
+	add := func(r rune, lvl int) {
+		if lvl != level && buf.Len() != 0 {
+			pieces = append(pieces, textPiece{text: buf.String(), level: level})
+			buf.Reset()
+		}
+		level = lvl
+		buf.WriteRune(r)
+	}
+	for i := 0; i < len(s); {
+		if markup && i+1 < len(s) && (s[i] == '^' || s[i] == '_') && s[i+1] == '{' {
+			if end := strings.IndexByte(s[i+2:], '}'); end >= 0 {
+				lvl := scriptSuper
+				if s[i] == '_' {
+					lvl = scriptSub
+				}
+				for _, r := range s[i+2 : i+2+end] {
+					add(r, lvl)
+				}
+				i += 2 + end + 1
+				continue
+			}
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+		if b, ok := superscripts[r]; ok {
+			add(b, scriptSuper)
+		} else if b, ok := subscripts[r]; ok {
+			add(b, scriptSub)
+		} else {
+			add(r, scriptNone)
+		}
+	}
+	if buf.Len() != 0 {
+		pieces = append(pieces, textPiece{text: buf.String(), level: level})
+	}
+	return pieces
+}
+
+// hasScripts reports whether s may have super- or subscripts.
+func hasScripts(s string, markup bool) bool {
+	if markup && (strings.Contains(s, "^{") || strings.Contains(s, "_{")) {
+		return true
+	}
+	for _, r := range s {
+		if r < 0x80 {
+			continue
+		}
+		if _, ok := superscripts[r]; ok {
+			return true
+		}
+		if _, ok := subscripts[r]; ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,158 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/pkg/errors"
+)
+
+// selftestSample is the built-in sample, with some accented letters
+// and a second part.
+const selftestSample = `id;name;amount
+1;Árvíztűrő tükörfúrógép;12.5
+2;Ça va? Größe, Ørsted, Łódź;7
+3;Ελληνικά, кириллица, ČŠŽ;-1.25
+code;note
+x;"quoted; with delimiter"
+`
+
+// SelfTest renders the built-in sample with all the charset maps and fonts
+// found in fontDir (default: the embedded fonts), and checks the structure
+// of the resulting PDFs, writing the report to w. With verbose, it reports
+// the successful checks, too.
+func SelfTest(w io.Writer, fontDir string, verbose bool) error {
+	fontDir, closeFontDir, err := prepareFontDir(fontDir)
+	if err != nil {
+		return errors.Wrapf(err, "prepare font dir %q", fontDir)
+	}
+	defer closeFontDir()
+
+	var failed int
+	report := func(what string, err error) {
+		if err != nil {
+			failed++
+			fmt.Fprintf(w, "FAIL\t%s: %v\n", what, err)
+		} else if verbose {
+			fmt.Fprintf(w, "ok\t%s\n", what)
+		}
+	}
+
+	maps, err := filepath.Glob(filepath.Join(fontDir, "*.map"))
+	if err != nil {
+		return err
+	}
+	if len(maps) == 0 {
+		report("charsets", errors.Errorf("no charset maps (*.map) in %q", fontDir))
+	}
+	sort.Strings(maps)
+	for _, fn := range maps {
+		report("charset "+strings.TrimSuffix(filepath.Base(fn), ".map"), selftestCharset(fontDir, fn))
+	}
+
+	fonts, err := filepath.Glob(filepath.Join(fontDir, "*.json"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(fonts)
+	for _, fn := range fonts {
+		report("font "+strings.TrimSuffix(filepath.Base(fn), ".json"), selftestFont(fontDir, fn))
+	}
+
+	if failed != 0 {
+		return errors.Errorf("%d checks failed", failed)
+	}
+	fmt.Fprintf(w, "ok\t%d charsets, %d fonts in %s\n", len(maps), len(fonts), fontDir)
+	return nil
+}
+
+// selftestCharset renders the sample through the table renderer with the
+// charset map in fn.
+func selftestCharset(fontDir, fn string) error {
+	tr, err := gofpdf.UnicodeTranslatorFromFile(fn)
+	if err != nil {
+		return err
+	}
+	parts, err := parseCsv(newRecordReader(strings.NewReader(selftestSample), ';', false), ';', nil)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	a4, _ := parsePageSize("A4")
+	pr := newPDFRenderer(&buf, fontDir, tr, defaultStyle, a4)
+	cr := newRecordReader(strings.NewReader(selftestSample), ';', false)
+	n := 0
+	for _, part := range parts {
+		if err = pr.StartPart(part); err != nil {
+			return err
+		}
+		for ; n < part.lastLine; n++ {
+			record, err := cr.Read()
+			if err != nil {
+				return err
+			}
+			if n < part.firstLine {
+				continue
+			}
+			if err = pr.Row(record); err != nil {
+				return err
+			}
+		}
+	}
+	if err = pr.Close(); err != nil {
+		return err
+	}
+	return selftestCheck(buf.Bytes(), 2)
+}
+
+// selftestFont renders the sample text with the font described by fn.
+// Core fonts (with "Tp":"Core") are built into gofpdf, the others are embedded.
+func selftestFont(fontDir, fn string) error {
+	b, err := os.ReadFile(fn)
+	if err != nil {
+		return err
+	}
+	pdf := gofpdf.New("P", "mm", "A4", fontDir)
+	family, style := strings.TrimSuffix(filepath.Base(fn), ".json"), ""
+	if bytes.Contains(b, []byte(`"Tp":"Core"`)) {
+		for _, sfx := range []string{"bi", "b", "i"} {
+			if base := strings.TrimSuffix(family, sfx); base != family && base != "zapfdingbats" {
+				family, style = base, strings.ToUpper(sfx)
+				break
+			}
+		}
+	} else {
+		pdf.AddFont(family, "", filepath.Base(fn))
+	}
+	pdf.AddPage()
+	pdf.SetFont(family, style, 12)
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	for _, line := range strings.Split(selftestSample, "\n") {
+		pdf.CellFormat(0, 6, tr(line), "", 1, "L", false, 0, "")
+	}
+	var buf bytes.Buffer
+	if err = pdf.Output(&buf); err != nil {
+		return err
+	}
+	return selftestCheck(buf.Bytes(), 1)
+}
+
+func selftestCheck(b []byte, pages int) error {
+	if _, err := checkPDFStructure(b); err != nil {
+		return err
+	}
+	if n := bytes.Count(b, []byte("/Type /Page\n")); n != pages {
+		return errors.Errorf("got %d pages, wanted %d", n, pages)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// ligatures are the standard ligatures, longest first.
+var ligatures = []struct {
+	seq string
+	lig rune
+}{
+	{"ffi", 'ﬃ'}, {"ffl", 'ﬄ'}, {"ff", 'ﬀ'}, {"fi", 'ﬁ'}, {"fl", 'ﬂ'},
+}
+
+// shaper does the basic text shaping the PDF fonts need: the combining
+// characters are composed to their precomposed forms (NFC), as a simple
+// font cannot position the combining marks, and the charsets have only the
+// precomposed letters; the Arabic letters are replaced by their joining
+// presentation forms (the right to left text is reordered when printed).
+//
+// If ligatures is set, the standard ligatures the font has are used.
+type shaper struct {
+	ligatures *strings.Replacer
+}
+
+// newShaper returns a shaper, using the ligatures of the font if has is not nil.
+func newShaper(has func(rune) bool) *shaper {
+	var sh shaper
+	if has != nil {
+		var oldnew []string
+		for _, l := range ligatures {
+			if has(l.lig) {
+				oldnew = append(oldnew, l.seq, string(l.lig))
+			}
+		}
+		if len(oldnew) != 0 {
+			sh.ligatures = strings.NewReplacer(oldnew...)
+		}
+	}
+	return &sh
+}
+
+func (sh *shaper) shape(s string) string {
+	s = shapeArabic(norm.NFC.String(s))
+	if sh.ligatures != nil {
+		s = sh.ligatures.Replace(s)
+	}
+	return s
+}
+
+// shapeRecord returns the shaped copy of the record.
+func (sh *shaper) shapeRecord(record []string) []string {
+	shaped := make([]string, len(record))
+	for i, v := range record {
+		shaped[i] = sh.shape(v)
+	}
+	return shaped
+}
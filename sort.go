@@ -0,0 +1,359 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/csv"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// sortKey is a column to sort by.
+type sortKey struct {
+	Column string
+	Desc   bool
+}
+
+// parseSortKeys parses the "column[:desc],..." spec.
+func parseSortKeys(spec string) ([]sortKey, error) {
+	var keys []sortKey
+	for _, s := range strings.Split(spec, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		k := sortKey{Column: s}
+		if i := strings.LastIndexByte(s, ':'); i >= 0 {
+			k.Column = s[:i]
+			switch strings.ToLower(s[i+1:]) {
+			case "asc":
+			case "desc":
+				k.Desc = true
+			default:
+				return nil, errors.Errorf("unknown sort order %q of %q", s[i+1:], k.Column)
+			}
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+// parseSize parses a byte size with an optional K, M, G (or KiB, MiB, GiB) suffix.
+func parseSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, func(r rune) bool { return !unicode.IsDigit(r) })
+	if i < 0 {
+		return strconv.ParseInt(s, 10, 64)
+	}
+	n, err := strconv.ParseInt(s[:i], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	switch strings.TrimSuffix(strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(s[i:])), "B"), "I") {
+	case "":
+		return n, nil
+	case "K":
+		return n << 10, nil
+	case "M":
+		return n << 20, nil
+	case "G":
+		return n << 30, nil
+	}
+	return 0, errors.Errorf("unknown size unit in %q", s)
+}
+
+// rowSorter sorts the rows of a part stably.
+// When the rows in memory exceed the memory limit, they are sorted
+// and spilled to a temporary file, and these runs are merged at the end.
+type rowSorter struct {
+	less  func(a, b []string) bool
+	limit int64
+	rows  [][]string
+	size  int64
+	runs  []*os.File
+}
+
+// newRowSorter returns a sorter for the part by the keys, comparing the
+// values not numbers with compare, or nil if none of the key columns are in
+// the part.
+func newRowSorter(part partDesc, keys []sortKey, compare func(a, b string) int, limit int64) *rowSorter {
+	type colKey struct {
+		idx  int
+		desc bool
+	}
+	var cols []colKey
+	for _, k := range keys {
+		if i := part.columnIndex(k.Column); i >= 0 {
+			cols = append(cols, colKey{idx: i, desc: k.Desc})
+		}
+	}
+	if len(cols) == 0 {
+		return nil
+	}
+	return &rowSorter{
+		limit: limit,
+		less: func(a, b []string) bool {
+			for _, c := range cols {
+				var x, y string
+				if c.idx < len(a) {
+					x = a[c.idx]
+				}
+				if c.idx < len(b) {
+					y = b[c.idx]
+				}
+				if d := compareValues(x, y, compare); d != 0 {
+					return d < 0 != c.desc
+				}
+			}
+			return false
+		},
+	}
+}
+
+// compareValues compares numerically if both are numbers, with compare otherwise.
+func compareValues(a, b string, compare func(a, b string) int) int {
+	if x, _, ok := parseNumber(a); ok {
+		if y, _, ok := parseNumber(b); ok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	}
+	return compare(a, b)
+}
+
+// newCollation returns the string comparison of the "natural" and/or
+// language tag (e.g. "hu") comma separated spec; byte-wise if empty.
+func newCollation(spec string) (func(a, b string) int, error) {
+	var natural bool
+	var tag string
+	for _, s := range strings.Split(spec, ",") {
+		switch s = strings.TrimSpace(s); s {
+		case "":
+		case "natural":
+			natural = true
+		default:
+			tag = s
+		}
+	}
+	if tag == "" {
+		if natural {
+			return compareNatural, nil
+		}
+		return strings.Compare, nil
+	}
+	lang, err := language.Parse(tag)
+	if err != nil {
+		return nil, errors.Wrap(err, tag)
+	}
+	opts := []collate.Option{collate.Loose}
+	if natural {
+		opts = append(opts, collate.Numeric)
+	}
+	c := collate.New(lang, opts...)
+	return func(a, b string) int {
+		if d := c.CompareString(a, b); d != 0 {
+			return d
+		}
+		return strings.Compare(a, b)
+	}, nil
+}
+
+// compareNatural compares the digit runs numerically and the rest
+// byte-wise: "file2" < "file10".
+func compareNatural(a, b string) int {
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if !isDigit(a[i]) || !isDigit(b[j]) {
+			if a[i] != b[j] {
+				if a[i] < b[j] {
+					return -1
+				}
+				return 1
+			}
+			i++
+			j++
+			continue
+		}
+		si, sj := i, j
+		for i < len(a) && isDigit(a[i]) {
+			i++
+		}
+		for j < len(b) && isDigit(b[j]) {
+			j++
+		}
+		x, y := strings.TrimLeft(a[si:i], "0"), strings.TrimLeft(b[sj:j], "0")
+		if len(x) != len(y) {
+			if len(x) < len(y) {
+				return -1
+			}
+			return 1
+		}
+		if d := strings.Compare(x, y); d != 0 {
+			return d
+		}
+	}
+	switch {
+	case len(a)-i < len(b)-j:
+		return -1
+	case len(a)-i > len(b)-j:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func isDigit(c byte) bool { return '0' <= c && c <= '9' }
+
+// Add the record to the sorter.
+func (rs *rowSorter) Add(record []string) error {
+	rs.rows = append(rs.rows, record)
+	rs.size += recordSize(record)
+	if rs.limit > 0 && rs.size > rs.limit {
+		return rs.spill()
+	}
+	return nil
+}
+
+// recordSize approximates the memory used by the record.
+func recordSize(record []string) int64 {
+	n := int64(24 + 16*len(record))
+	for _, s := range record {
+		n += int64(len(s))
+	}
+	return n
+}
+
+func (rs *rowSorter) spill() error {
+	fh, err := os.CreateTemp("", "csv2pdf-sort-")
+	if err != nil {
+		return err
+	}
+	os.Remove(fh.Name())
+	rs.runs = append(rs.runs, fh)
+	sort.SliceStable(rs.rows, func(i, j int) bool { return rs.less(rs.rows[i], rs.rows[j]) })
+	bw := bufio.NewWriter(fh)
+	cw := csv.NewWriter(bw)
+	if err = cw.WriteAll(rs.rows); err != nil {
+		return errors.Wrap(err, "spill")
+	}
+	if err = bw.Flush(); err != nil {
+		return errors.Wrap(err, "spill")
+	}
+	rs.rows, rs.size = rs.rows[:0], 0
+	return nil
+}
+
+// Each calls f with the rows in sorted order, and releases the resources.
+func (rs *rowSorter) Each(f func([]string) error) error {
+	defer func() {
+		for _, fh := range rs.runs {
+			fh.Close()
+		}
+		rs.runs, rs.rows = nil, nil
+	}()
+	sort.SliceStable(rs.rows, func(i, j int) bool { return rs.less(rs.rows[i], rs.rows[j]) })
+	if len(rs.runs) == 0 {
+		for _, r := range rs.rows {
+			if err := f(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// k-way merge of the runs and the in-memory rest, which is the last run
+	mh := mergeHeap{less: rs.less}
+	for i, fh := range rs.runs {
+		if _, err := fh.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		cr := csv.NewReader(bufio.NewReader(fh))
+		cr.FieldsPerRecord = -1
+		next := func() ([]string, error) { return cr.Read() }
+		if err := mh.push(i, next); err != nil {
+			return err
+		}
+	}
+	rest := rs.rows
+	if err := mh.push(len(rs.runs), func() ([]string, error) {
+		if len(rest) == 0 {
+			return nil, io.EOF
+		}
+		r := rest[0]
+		rest = rest[1:]
+		return r, nil
+	}); err != nil {
+		return err
+	}
+	for mh.Len() != 0 {
+		it := &mh.items[0]
+		if err := f(it.record); err != nil {
+			return err
+		}
+		var err error
+		if it.record, err = it.next(); err == io.EOF {
+			heap.Pop(&mh)
+		} else if err != nil {
+			return errors.Wrap(err, "read spilled rows")
+		} else {
+			heap.Fix(&mh, 0)
+		}
+	}
+	return nil
+}
+
+type mergeItem struct {
+	record []string
+	run    int
+	next   func() ([]string, error)
+}
+
+// mergeHeap orders by the records, then by the run index for stability.
+type mergeHeap struct {
+	items []mergeItem
+	less  func(a, b []string) bool
+}
+
+func (h mergeHeap) Len() int { return len(h.items) }
+func (h mergeHeap) Less(i, j int) bool {
+	a, b := h.items[i], h.items[j]
+	if h.less(a.record, b.record) {
+		return true
+	} else if h.less(b.record, a.record) {
+		return false
+	}
+	return a.run < b.run
+}
+func (h mergeHeap) Swap(i, j int)       { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *mergeHeap) Push(x interface{}) { h.items = append(h.items, x.(mergeItem)) }
+func (h *mergeHeap) Pop() interface{} {
+	x := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return x
+}
+
+func (h *mergeHeap) push(run int, next func() ([]string, error)) error {
+	record, err := next()
+	if err == io.EOF {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	heap.Push(h, mergeItem{record: record, run: run, next: next})
+	return nil
+}
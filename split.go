@@ -0,0 +1,152 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// splitRenderer renders each part into its own file, named by the
+// template (e.g. "report-%02d.pdf") from the number of the part.
+//
+// The text before a part goes into the part's file, the text after the
+// last part into the last file.
+type splitRenderer struct {
+	template string
+	// newRenderer returns the renderer of the n-th part (from 0).
+	newRenderer func(w io.Writer, n int) (tableRenderer, func(), error)
+	// finish is called with the complete output of a part, and its renderer.
+	finish func(out *spoolFile, rend tableRenderer) error
+
+	n       int
+	cur     tableRenderer
+	closer  func()
+	out     *spoolFile
+	pending [][]string
+}
+
+// checkSplitTemplate checks that the template names the parts differently.
+func checkSplitTemplate(template string) error {
+	if a := fmt.Sprintf(template, 1); strings.Contains(a, "%!") || a == fmt.Sprintf(template, 2) {
+		return errors.Errorf("%q needs one verb for the part number (e.g. report-%%02d.pdf)", template)
+	}
+	return nil
+}
+
+func (sr *splitRenderer) StartPart(part partDesc) error {
+	if err := sr.finishPart(); err != nil {
+		return err
+	}
+	var err error
+	if sr.out, err = newSpool(); err != nil {
+		return errors.Wrap(err, "creating output spool file")
+	}
+	if sr.cur, sr.closer, err = sr.newRenderer(sr.out, sr.n); err != nil {
+		sr.out.Remove()
+		return err
+	}
+	sr.n++
+	for _, text := range sr.pending {
+		if err = renderText(sr.cur, text); err != nil {
+			return err
+		}
+	}
+	sr.pending = nil
+	return sr.cur.StartPart(part)
+}
+
+func (sr *splitRenderer) Row(record []string) error { return sr.cur.Row(record) }
+
+func (sr *splitRenderer) Paragraphs(text []string) error {
+	if sr.cur == nil {
+		sr.pending = append(sr.pending, text)
+		return nil
+	}
+	return renderText(sr.cur, text)
+}
+
+func (sr *splitRenderer) Close() error { return sr.finishPart() }
+
+// finishPart closes the renderer of the current part, and writes its file.
+func (sr *splitRenderer) finishPart() error {
+	if sr.cur == nil {
+		return nil
+	}
+	// the text after the last part is in pending
+	for _, text := range sr.pending {
+		if err := renderText(sr.cur, text); err != nil {
+			return err
+		}
+	}
+	sr.pending = nil
+	cur, out := sr.cur, sr.out
+	defer sr.abort()
+	if err := cur.Close(); err != nil {
+		return errors.Wrap(err, "writing output")
+	}
+	if err := sr.finish(out, cur); err != nil {
+		return err
+	}
+	fn := fmt.Sprintf(sr.template, sr.n)
+	af, err := createAtomic(fn)
+	if err != nil {
+		return errors.Wrapf(err, "creating %q", fn)
+	}
+	defer af.Abort()
+	if err = out.CopyTo(af); err != nil {
+		return errors.Wrapf(err, "writing %q", fn)
+	}
+	if err = af.Commit(); err != nil {
+		return errors.Wrapf(err, "writing %q", fn)
+	}
+	log.Printf("part %d written to %q", sr.n, fn)
+	return nil
+}
+
+// abort releases the current part, without writing its file.
+func (sr *splitRenderer) abort() {
+	if sr.cur == nil {
+		return
+	}
+	sr.closer()
+	sr.out.Remove()
+	sr.cur, sr.closer, sr.out = nil, nil, nil
+}
+
+// runningTotals are the totals of the total columns, by their names,
+// carried from a split file to the next one.
+type runningTotals struct {
+	sums     map[string]float64
+	decimals map[string]int
+}
+
+func newRunningTotals() *runningTotals {
+	return &runningTotals{sums: make(map[string]float64), decimals: make(map[string]int)}
+}
+
+// bring sets the totals of t to the ones carried, and reports whether there
+// was any.
+func (rt *runningTotals) bring(t *pdfTable) bool {
+	var found bool
+	for j, name := range t.totalNames {
+		if sum, ok := rt.sums[name]; ok {
+			t.totals[j], t.decimals[j] = sum, rt.decimals[name]
+			found = true
+		}
+	}
+	return found
+}
+
+// keep records the totals of t, to be brought forward.
+func (rt *runningTotals) keep(t *pdfTable) {
+	for j, name := range t.totalNames {
+		rt.sums[name], rt.decimals[name] = t.totals[j], t.decimals[j]
+	}
+}
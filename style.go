@@ -0,0 +1,166 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/pkg/errors"
+)
+
+// tableStyle holds the dimensions and decorations of the PDF tables.
+type tableStyle struct {
+	HeaderFontSize, BodyFontSize float64
+	// HeaderHeight and RowHeight are the cell heights, in mm.
+	HeaderHeight, RowHeight float64
+	// Padding is the space between the cell borders and the text, in mm
+	// (0: the 1 mm of gofpdf).
+	Padding float64 `json:",omitempty"`
+	// HeaderAngle rotates the heads by 90° or 45°, for the narrow columns
+	// of long heads.
+	HeaderAngle float64 `json:",omitempty"`
+	// Margin is the page margin (the bottom margin is twice this), in mm.
+	Margin float64
+	// CharWidth and HeaderCharWidth are the column width per character
+	// of the widest value and of the header, in mm.
+	CharWidth, HeaderCharWidth float64
+	// Fill the header band and stripe the rows.
+	Fill bool
+	// Borders around the cells; without them only the header is underlined.
+	Borders bool
+	// Rules are thin lines under the rows, for the ink saver layout without
+	// fills and borders.
+	Rules bool `json:",omitempty"`
+	// Grid borders are around every cell, not just between the columns.
+	Grid bool `json:",omitempty"`
+	// Frame is drawn around the table, without borders inside.
+	Frame bool `json:",omitempty"`
+	// Borderless tables have no lines at all, not even under the header.
+	Borderless bool `json:",omitempty"`
+}
+
+var (
+	defaultStyle = tableStyle{
+		HeaderFontSize: 10, BodyFontSize: 8,
+		HeaderHeight: 7, RowHeight: 6,
+		Margin:    10,
+		CharWidth: 1.75, HeaderCharWidth: 2,
+		Fill: true, Borders: true,
+	}
+
+	// compactStyle saves paper, still readable on print.
+	compactStyle = tableStyle{
+		HeaderFontSize: 7.5, BodyFontSize: 6.5,
+		HeaderHeight: 4.5, RowHeight: 3.6,
+		Margin:    5,
+		CharWidth: 1.45, HeaderCharWidth: 1.5,
+	}
+)
+
+// RGB is a color.
+type RGB struct{ R, G, B int }
+
+// ParseRGB parses the "#RRGGBB" (or the short "#RGB") hex color.
+func ParseRGB(s string) (RGB, error) {
+	hex := strings.TrimPrefix(strings.TrimSpace(s), "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) != 6 {
+		return RGB{}, errors.Errorf("%q: should be #RRGGBB", s)
+	}
+	n, err := strconv.ParseUint(hex, 16, 24)
+	if err != nil {
+		return RGB{}, errors.Errorf("%q: should be #RRGGBB", s)
+	}
+	return RGB{R: int(n >> 16), G: int(n >> 8 & 0xff), B: int(n & 0xff)}, nil
+}
+
+// String returns the color as #RRGGBB.
+func (c RGB) String() string { return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B) }
+
+// Colors are the colors of the tables.
+type Colors struct {
+	// HeaderFill and HeaderText are the colors of the header band.
+	HeaderFill, HeaderText RGB
+	// Border is the color of the cell borders.
+	Border RGB
+	// Stripe is the fill of every second row.
+	Stripe RGB
+}
+
+// DefaultColors returns the default colors: red header band, light blue stripes.
+func DefaultColors() Colors {
+	return Colors{
+		HeaderFill: RGB{255, 0, 0}, HeaderText: RGB{0, 0, 0},
+		Border: RGB{128, 0, 0},
+		Stripe: RGB{224, 235, 255},
+	}
+}
+
+// setHeader sets the fill, text and draw colors of a header.
+func (c Colors) setHeader(pdf *gofpdf.Fpdf) {
+	pdf.SetFillColor(c.HeaderFill.R, c.HeaderFill.G, c.HeaderFill.B)
+	pdf.SetTextColor(c.HeaderText.R, c.HeaderText.G, c.HeaderText.B)
+	pdf.SetDrawColor(c.Border.R, c.Border.G, c.Border.B)
+}
+
+func (st tableStyle) headerBorder() string {
+	if st.Borders {
+		return "1"
+	}
+	if st.Borderless {
+		return ""
+	}
+	return "B"
+}
+
+func (st tableStyle) rowBorder() string {
+	if st.Grid {
+		return "1"
+	}
+	if st.Borders {
+		return "LR"
+	}
+	if st.Rules {
+		return "B"
+	}
+	return ""
+}
+
+// inkSaver returns the style without fills and borders, with thin rules
+// under the rows and the header.
+func (st tableStyle) inkSaver() tableStyle {
+	st.Fill, st.Borders, st.Rules = false, false, true
+	st.Grid, st.Frame, st.Borderless = false, false, false
+	return st
+}
+
+// scaled returns the style with the fonts, the cell heights and the widths
+// per character scaled by f; the margins stay.
+func (st tableStyle) scaled(f float64) tableStyle {
+	st.HeaderFontSize, st.BodyFontSize = st.HeaderFontSize*f, st.BodyFontSize*f
+	st.HeaderHeight, st.RowHeight = st.HeaderHeight*f, st.RowHeight*f
+	st.CharWidth, st.HeaderCharWidth = st.CharWidth*f, st.HeaderCharWidth*f
+	return st
+}
+
+func (st tableStyle) totalBorder() string {
+	if st.Borders {
+		return "1"
+	}
+	if st.Borderless {
+		return ""
+	}
+	return "T"
+}
+
+// rowsPerPage returns the number of rows that fit on a page of the given height.
+func (st tableStyle) rowsPerPage(pageHeight float64) int {
+	return int((pageHeight - 3*st.Margin - st.HeaderHeight) / st.RowHeight)
+}
@@ -0,0 +1,75 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// addSummary adds a summary page listing the parts with their row counts.
+// Each summary row links to the first page of its part, and shows that page
+// number (filled in by StartPart through an alias).
+func (pr *pdfRenderer) addSummary(parts []partDesc) {
+	pdf := pr.pdf
+	pdf.AddPageFormat("P", pr.defPageSize)
+	pr.tocSection("Summary")
+	pdf.SetFont(pr.font, "B", 14)
+	pdf.CellFormat(0, 10, pr.translator("Summary"), "", 1, "L", false, 0, "")
+	pdf.Ln(2)
+
+	pr.colors.setHeader(pdf)
+	pdf.SetLineWidth(.3)
+	pdf.SetFont(pr.font, "B", 10)
+	widths := []float64{20, 120, 25, 25}
+	for i, h := range []string{"Part", "Columns", "Rows", "Page"} {
+		pdf.CellFormat(widths[i], 7, pr.translator(h), "1", 0, "C", true, 0, "")
+	}
+	pdf.Ln(-1)
+
+	pdf.SetFont(pr.font, "", 8)
+	pdf.SetTextColor(pr.rgb(0, 0, 255))
+	pr.partLinks = make([]int, len(parts))
+	for i, part := range parts {
+		link := pdf.AddLink()
+		pr.partLinks[i] = link
+		cols := strings.Join(part.head, ", ")
+		if part.title != "" {
+			cols = part.title + ": " + cols
+		}
+		cols = pr.translator(cols)
+		for pdf.GetStringWidth(cols) > widths[1]-2 && len(cols) > 3 {
+			cols = cols[:len(cols)-4] + "..."
+		}
+		for j, v := range []string{
+			strconv.Itoa(i + 1), cols, strconv.Itoa(part.lastLine - part.firstLine), summaryPageAlias(i),
+		} {
+			align := "R"
+			if j == 1 {
+				align = "L"
+			}
+			pdf.CellFormat(widths[j], 6, v, "1", 0, align, false, link, "")
+		}
+		pdf.Ln(-1)
+	}
+	pdf.SetTextColor(0, 0, 0)
+	pr.addOutlierSummary(parts)
+}
+
+// summaryPageAlias is the placeholder of the first page number of part i.
+func summaryPageAlias(i int) string { return fmt.Sprintf("{part%dpage}", i+1) }
+
+// linkPart sets the summary link target and page number of the current part.
+func (pr *pdfRenderer) linkPart() {
+	if pr.partIdx < len(pr.partLinks) {
+		pr.pdf.SetLink(pr.partLinks[pr.partIdx], 0, -1)
+		// pad to the alias length, as the cell is already right-aligned
+		page := strconv.Itoa(pr.pdf.PageNo())
+		alias := summaryPageAlias(pr.partIdx)
+		pr.pdf.RegisterAlias(alias, strings.Repeat(" ", len(alias)-len(page))+page)
+	}
+	pr.partIdx++
+}
@@ -0,0 +1,44 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import "strings"
+
+// dingbats maps the common status symbols and emoji to the ZapfDingbats
+// core font, to print them (monochrome) with any font and charset.
+var dingbats = map[rune]byte{
+	'✓': 0x33, '✔': 0x34, '✕': 0x35, '✖': 0x36, '✗': 0x37, '✘': 0x38,
+	'✅': 0x34, '☑': 0x34,
+	'❌': 0x38, '❎': 0x38, '☒': 0x38,
+	'⚠': 0x73, '▲': 0x73, '▼': 0x74, '◆': 0x75, '❖': 0x76,
+	'●': 0x6c, '⚫': 0x6c, '⚪': 0x6d, '❍': 0x6d, '○': 0x6d,
+	'🔴': 0x6c, '🟠': 0x6c, '🟡': 0x6c, '🟢': 0x6c, '🔵': 0x6c, '🟣': 0x6c,
+	'■': 0x6e, '❏': 0x6f, '⬛': 0x6e, '🟥': 0x6e, '🟩': 0x6e, '🟨': 0x6e,
+	'★': 0x48, '⭐': 0x48, '☆': 0x49, '✩': 0x49, '✂': 0x22, '☎': 0x25, '📞': 0x25,
+	'✈': 0x28, '✉': 0x29, '📧': 0x29, '☛': 0x2a, '☞': 0x2b, '✌': 0x2c,
+	'✍': 0x2d, '✎': 0x2e, '✏': 0x2f, '❤': 0xa4, '♥': 0xaa, '♦': 0xa9,
+	'♣': 0xa8, '♠': 0xab, '→': 0xd5, '↔': 0xd6, '↕': 0xd7, '➔': 0xd4,
+}
+
+// isDingbat reports whether r is printed with ZapfDingbats.
+func isDingbat(r rune) bool {
+	_, ok := dingbats[r]
+	return ok || r == 0xfe0f || r == 0xfe0e
+}
+
+// encodeDingbats returns the ZapfDingbats codes of the symbols in s,
+// dropping the emoji variation selectors.
+func encodeDingbats(s string) string {
+	var buf strings.Builder
+	for _, r := range s {
+		if c, ok := dingbats[r]; ok {
+			buf.WriteByte(c)
+		}
+	}
+	return buf.String()
+}
+
+// symbolFont is the chain element printing the symbols with ZapfDingbats.
+var symbolFont = chainFont{family: "ZapfDingbats", has: isDingbat, encode: encodeDingbats}
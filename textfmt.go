@@ -0,0 +1,236 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"bufio"
+	"encoding/csv"
+	"io"
+	"strings"
+)
+
+// textRenderer renders the parts as fixed-width text or Markdown tables.
+type textRenderer struct {
+	w        *bufio.Writer
+	markdown bool
+	widths   []int
+	aligns   []string
+	parts    int
+}
+
+func newTextRenderer(w io.Writer, markdown bool) *textRenderer {
+	return &textRenderer{w: bufio.NewWriter(w), markdown: markdown}
+}
+
+func (tr *textRenderer) StartPart(part partDesc) error {
+	if tr.parts != 0 {
+		tr.w.WriteByte('\n')
+	}
+	tr.parts++
+	if part.title != "" {
+		if tr.markdown {
+			tr.w.WriteString("## ")
+		}
+		tr.w.WriteString(part.title)
+		tr.w.WriteString("\n\n")
+	}
+	if part.caption != "" {
+		tr.w.WriteString(part.caption)
+		tr.w.WriteString("\n\n")
+	}
+	tr.widths = make([]int, len(part.head))
+	for i, h := range part.head {
+		tr.widths[i] = part.widths[i]
+		if tr.markdown {
+			h = markdownEscape(h)
+		}
+		if n := displayWidth(h); n > tr.widths[i] {
+			tr.widths[i] = n
+		}
+		if tr.markdown && tr.widths[i] < 3 {
+			tr.widths[i] = 3
+		}
+	}
+	tr.aligns = part.aligns
+	tr.writeRow(part.head, true)
+	sep := "-+-"
+	if tr.markdown {
+		tr.w.WriteString("| ")
+		sep = " | "
+	}
+	for i, w := range tr.widths {
+		if i != 0 {
+			tr.w.WriteString(sep)
+		}
+		if tr.markdown && tr.align(i) == "R" {
+			tr.w.WriteString(strings.Repeat("-", w-1) + ":")
+		} else {
+			tr.w.WriteString(strings.Repeat("-", w))
+		}
+	}
+	if tr.markdown {
+		tr.w.WriteString(" |")
+	}
+	_, err := tr.w.WriteString("\n")
+	return err
+}
+
+func (tr *textRenderer) Row(record []string) error {
+	return tr.writeRow(record, false)
+}
+
+// Paragraphs writes the text, wrapped at textWidth if not Markdown.
+func (tr *textRenderer) Paragraphs(text []string) error {
+	if tr.parts != 0 {
+		tr.w.WriteByte('\n')
+	}
+	tr.parts++
+	for i, p := range text {
+		if i != 0 {
+			tr.w.WriteByte('\n')
+		}
+		if tr.markdown {
+			tr.w.WriteString(p)
+			tr.w.WriteByte('\n')
+			continue
+		}
+		for _, line := range wrapText(p, textWidth) {
+			tr.w.WriteString(line)
+			tr.w.WriteByte('\n')
+		}
+	}
+	return nil
+}
+
+func (tr *textRenderer) Close() error {
+	return tr.w.Flush()
+}
+
+func (tr *textRenderer) align(i int) string {
+	if i < len(tr.aligns) {
+		return tr.aligns[i]
+	}
+	return ""
+}
+
+func (tr *textRenderer) writeRow(record []string, head bool) error {
+	sep := " | "
+	if tr.markdown {
+		tr.w.WriteString("| ")
+	}
+	for i, v := range record {
+		if i != 0 {
+			tr.w.WriteString(sep)
+		}
+		v = strings.Map(func(r rune) rune {
+			if r == '\n' || r == '\r' || r == '\t' {
+				return ' '
+			}
+			return r
+		}, v)
+		if tr.markdown {
+			v = markdownEscape(v)
+		}
+		pad := strings.Repeat(" ", maxInt(0, tr.widths[i]-displayWidth(v)))
+		if !head && tr.align(i) == "R" {
+			tr.w.WriteString(pad + v)
+		} else if i == len(record)-1 && !tr.markdown {
+			tr.w.WriteString(v)
+		} else {
+			tr.w.WriteString(v + pad)
+		}
+	}
+	if tr.markdown {
+		tr.w.WriteString(" |")
+	}
+	_, err := tr.w.WriteString("\n")
+	return err
+}
+
+// markdownEscape escapes the | in v, not to end the Markdown table cell.
+func markdownEscape(v string) string {
+	return strings.ReplaceAll(v, "|", `\|`)
+}
+
+// markdownWidths measures the widths of the values escaped for Markdown,
+// as the escapes widen the columns.
+type markdownWidths struct {
+	widths []int
+}
+
+func (mw *markdownWidths) startPart(head []string) { mw.widths = make([]int, len(head)) }
+
+func (mw *markdownWidths) observe(_ int, record []string) {
+	for i, v := range record {
+		if i < len(mw.widths) && strings.Contains(v, "|") {
+			mw.widths[i] = maxInt(mw.widths[i], cellWidth(markdownEscape(v)))
+		}
+	}
+}
+
+func (mw *markdownWidths) finishPart(part *partDesc) {
+	for i, w := range mw.widths {
+		if i < len(part.widths) && w > part.widths[i] {
+			part.widths[i] = w
+		}
+	}
+}
+
+// csvRenderer writes the rendered rows back as CSV.
+type csvRenderer struct {
+	w *csv.Writer
+}
+
+func newCSVRenderer(w io.Writer, comma rune) *csvRenderer {
+	cw := csv.NewWriter(w)
+	cw.Comma = comma
+	return &csvRenderer{w: cw}
+}
+
+func (cr *csvRenderer) StartPart(part partDesc) error { return cr.w.Write(part.head) }
+func (cr *csvRenderer) Row(record []string) error     { return cr.w.Write(record) }
+func (cr *csvRenderer) Close() error {
+	cr.w.Flush()
+	return cr.w.Error()
+}
+
+// textWidth is the line length of the wrapped text.
+const textWidth = 80
+
+// wrapText splits s into lines of at most width runes, at spaces if possible.
+func wrapText(s string, width int) []string {
+	var lines []string
+	var line []rune
+	for _, word := range strings.Fields(s) {
+		w := []rune(word)
+		if len(line) != 0 && len(line)+1+len(w) > width {
+			lines = append(lines, string(line))
+			line = line[:0]
+		}
+		for len(w) > width {
+			lines = append(lines, string(w[:width]))
+			w = w[width:]
+		}
+		if len(line) != 0 {
+			line = append(line, ' ')
+		}
+		line = append(line, w...)
+	}
+	return append(lines, string(line))
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
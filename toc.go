@@ -0,0 +1,84 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// tocDoc is the navigation of the rendered PDF, saved as a JSON sidecar:
+// the page numbers of the sections, the parts and the index entries.
+type tocDoc struct {
+	Pages    int          `json:"pages"`
+	Sections []tocSection `json:"sections,omitempty"`
+	Parts    []tocPart    `json:"parts"`
+	Index    []tocEntry   `json:"index,omitempty"`
+}
+
+// tocSection is a section (summary, data quality, index) starting on Page.
+type tocSection struct {
+	Name string `json:"name"`
+	Page int    `json:"page"`
+}
+
+type tocPart struct {
+	Title     string   `json:"title,omitempty"`
+	Head      []string `json:"head"`
+	FirstPage int      `json:"firstPage"`
+	LastPage  int      `json:"lastPage"`
+	Rows      int      `json:"rows"`
+}
+
+type tocEntry struct {
+	Value string `json:"value"`
+	Pages []int  `json:"pages"`
+}
+
+// tocSection records the start of the section on the current page.
+func (pr *pdfRenderer) tocSection(name string) {
+	if pr.toc != nil {
+		pr.toc.Sections = append(pr.toc.Sections, tocSection{Name: name, Page: pr.pdf.PageNo()})
+	}
+}
+
+// tocPart records the start of the part on the current page.
+func (pr *pdfRenderer) tocPart(part partDesc) {
+	if pr.toc != nil {
+		page := pr.pdf.PageNo()
+		pr.toc.Parts = append(pr.toc.Parts, tocPart{Title: part.title, Head: part.head, FirstPage: page, LastPage: page})
+	}
+}
+
+// tocFinishPart records the last page and the row count of the current part.
+func (pr *pdfRenderer) tocFinishPart() {
+	if pr.toc != nil && len(pr.toc.Parts) != 0 {
+		p := &pr.toc.Parts[len(pr.toc.Parts)-1]
+		p.LastPage, p.Rows = pr.pdf.PageNo(), pr.rows
+	}
+}
+
+// saveTOC writes the navigation, with the index entries, to tocFn.
+func (pr *pdfRenderer) saveTOC() error {
+	pr.toc.Pages = pr.pdf.PageCount()
+	pr.toc.Index = make([]tocEntry, 0, len(pr.index))
+	for v, pages := range pr.index {
+		pr.toc.Index = append(pr.toc.Index, tocEntry{Value: v, Pages: pages})
+	}
+	sort.Slice(pr.toc.Index, func(i, j int) bool { return pr.toc.Index[i].Value < pr.toc.Index[j].Value })
+	b, err := json.MarshalIndent(pr.toc, "", "  ")
+	if err != nil {
+		return err
+	}
+	af, err := createAtomic(pr.tocFn)
+	if err != nil {
+		return err
+	}
+	defer af.Abort()
+	if _, err = af.Write(b); err != nil {
+		return err
+	}
+	return af.Commit()
+}
@@ -0,0 +1,78 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"fmt"
+	"log"
+)
+
+// transposedNote is the caption of the transposed tables.
+const transposedNote = "transposed: the columns are printed as rows"
+
+// transposable reports whether the part has at most maxRows rows, but more
+// than twice as many columns, to be printed transposed.
+func (part partDesc) transposable(maxRows int) bool {
+	rows := part.lastLine - part.firstLine
+	return maxRows > 0 && rows > 0 && rows <= maxRows && len(part.head) > 2*rows
+}
+
+// transposer collects the records of a part, to print them transposed: a
+// row per column, headed by the column head, and a column per record,
+// headed by its first value.
+type transposer struct {
+	part    partDesc
+	records [][]string
+}
+
+func (tp *transposer) Add(record []string) error {
+	tp.records = append(tp.records, append([]string(nil), record...))
+	return nil
+}
+
+// render starts the transposed part on rend, and renders its rows.
+func (tp *transposer) render(rend tableRenderer) error {
+	part := tp.part
+	log.Printf("%d rows of %d columns: printed transposed", len(tp.records), len(part.head))
+	t := partDesc{
+		firstLine: part.firstLine, lastLine: part.lastLine,
+		head:   make([]string, 1+len(tp.records)),
+		widths: make([]int, 1+len(tp.records)),
+		title:  part.title, caption: transposedNote,
+	}
+	if part.caption != "" {
+		t.caption = fmt.Sprintf("%s; %s", part.caption, transposedNote)
+	}
+	if len(part.head) != 0 {
+		t.head[0] = part.head[0]
+	}
+	for _, h := range part.head {
+		t.widths[0] = maxInt(t.widths[0], cellWidth(h))
+	}
+	for k, record := range tp.records {
+		for j, v := range record {
+			if j == 0 {
+				t.head[k+1] = v
+			}
+			t.widths[k+1] = maxInt(t.widths[k+1], cellWidth(v))
+		}
+	}
+	if err := rend.StartPart(t); err != nil {
+		return err
+	}
+	for j := 1; j < len(part.head); j++ {
+		row := make([]string, len(t.head))
+		row[0] = part.head[j]
+		for k, record := range tp.records {
+			if j < len(record) {
+				row[k+1] = record[j]
+			}
+		}
+		if err := rend.Row(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,271 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"unicode/utf8"
+
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/pkg/errors"
+)
+
+// truncMode is how the too long cell values are cut.
+type truncMode uint8
+
+const (
+	// truncNone lets the text overflow the cell.
+	truncNone truncMode = iota
+	// truncEnd cuts the tail: "ABCDEFG…".
+	truncEnd
+	// truncMiddle keeps the start and the end, as those distinguish
+	// long identifiers the most: "ABCD…WXYZ".
+	truncMiddle
+	// truncWrap does not cut, but wraps the text into more lines, making
+	// the row higher.
+	truncWrap
+)
+
+func parseTruncMode(s string) (truncMode, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "none":
+		return truncNone, nil
+	case "end":
+		return truncEnd, nil
+	case "middle":
+		return truncMiddle, nil
+	case "wrap":
+		return truncWrap, nil
+	}
+	return truncNone, errors.Errorf("unknown truncation mode %q (none, end, middle or wrap)", s)
+}
+
+// truncSpec is the default truncation mode and the per-column exceptions.
+type truncSpec struct {
+	Default truncMode
+	Columns map[string]truncMode
+}
+
+// parseTruncSpec parses "mode,column=mode,...".
+func parseTruncSpec(spec string) (truncSpec, error) {
+	var ts truncSpec
+	for _, s := range strings.Split(spec, ",") {
+		name, mode := "", s
+		if i := strings.LastIndexByte(s, '='); i >= 0 {
+			name, mode = strings.TrimSpace(s[:i]), s[i+1:]
+		}
+		m, err := parseTruncMode(mode)
+		if err != nil {
+			return ts, err
+		}
+		if name == "" {
+			ts.Default = m
+			continue
+		}
+		if ts.Columns == nil {
+			ts.Columns = make(map[string]truncMode)
+		}
+		ts.Columns[name] = m
+	}
+	return ts, nil
+}
+
+// modes returns the truncation mode of each column of the part.
+func (ts truncSpec) modes(part partDesc) []truncMode {
+	modes := make([]truncMode, len(part.head))
+	for i := range modes {
+		modes[i] = ts.Default
+	}
+	for name, m := range ts.Columns {
+		if i := part.columnIndex(name); i >= 0 {
+			modes[i] = m
+		}
+	}
+	return modes
+}
+
+// truncateText cuts the (already translated, single-byte encoded) s to fit
+// into width with the current font, marking the cut with ellipsis.
+func truncateText(pdf *gofpdf.Fpdf, s string, width float64, mode truncMode, ellipsis string) string {
+	return truncateMeasured(s, width, mode, ellipsis, pdf.GetStringWidth, false)
+}
+
+// truncateMeasured cuts s to fit into width as measured, marking the cut
+// with ellipsis. If isUTF8, s is cut at rune boundaries, else at any byte.
+func truncateMeasured(s string, width float64, mode truncMode, ellipsis string,
+	measure func(string) float64, isUTF8 bool,
+) string {
+	if mode == truncNone || mode == truncWrap || measure(s) <= width {
+		return s
+	}
+	width -= measure(ellipsis)
+	if width <= 0 {
+		return ""
+	}
+	// the possible cut positions
+	cuts := make([]int, 0, len(s)+1)
+	for i := range s {
+		if !isUTF8 || utf8.RuneStart(s[i]) {
+			cuts = append(cuts, i)
+		}
+	}
+	cuts = append(cuts, len(s))
+	if mode == truncEnd {
+		n := len(cuts) - 1
+		for n > 0 && measure(s[:cuts[n]]) > width {
+			n--
+		}
+		return s[:cuts[n]] + ellipsis
+	}
+	// keep the head and the tail, dropping characters from the middle
+	h, t := len(cuts)/2, len(cuts)/2
+	for h > 0 || t < len(cuts)-1 {
+		if measure(s[:cuts[h]])+measure(s[cuts[t]:]) <= width {
+			break
+		}
+		if h > len(cuts)-1-t {
+			h--
+		} else {
+			t++
+		}
+	}
+	return s[:cuts[h]] + ellipsis + s[cuts[t]:]
+}
+
+// wrapMeasured breaks s into lines fitting into width as measured: at the
+// spaces and around the wide (CJK) runes, and the words longer than width
+// at rune boundaries.
+func wrapMeasured(s string, width float64, measure func(string) float64) []string {
+	var lines []string
+	for _, para := range strings.Split(s, "\n") {
+		var line string
+		segs, glues := wrapSegments(para)
+		for k, word := range segs {
+			if line != "" && measure(line+glues[k]+word) <= width {
+				line += glues[k] + word
+				continue
+			}
+			if line != "" {
+				lines = append(lines, line)
+			}
+			for measure(word) > width {
+				n := len(word)
+				for n > 0 && measure(word[:n]) > width {
+					_, size := utf8.DecodeLastRuneInString(word[:n])
+					n -= size
+				}
+				if n == 0 {
+					_, n = utf8.DecodeRuneInString(word)
+				}
+				lines = append(lines, word[:n])
+				word = word[n:]
+			}
+			line = word
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// hasWrap reports whether any of the columns wraps.
+func hasWrap(modes []truncMode) bool {
+	for _, m := range modes {
+		if m == truncWrap {
+			return true
+		}
+	}
+	return false
+}
+
+// fitWrapped narrows the wrapping columns so the table fits the page width:
+// a portrait page if possible, unless the orientation is forced.
+func (pr *pdfRenderer) fitWrapped(part partDesc, colwidths []float64, modes []truncMode, forced string) (string, []float64) {
+	if forced == "L" {
+		return "L", fitWidths(part, colwidths, modes, pr.defPageSize.Ht-2*pr.style.Margin, pr.style)
+	}
+	fitted := fitWidths(part, colwidths, modes, pr.defPageSize.Wd-2*pr.style.Margin, pr.style)
+	if forced == "P" || sumFloats(fitted) <= pr.defPageSize.Wd-2*pr.style.Margin {
+		return "P", fitted
+	}
+	return "L", fitWidths(part, colwidths, modes, pr.defPageSize.Ht-2*pr.style.Margin, pr.style)
+}
+
+// fontStep is the step of shrinking the fonts of the tables too wide.
+const fontStep = 0.5
+
+// shrinkToFit steps the body font size (and with it the header font, the
+// cell heights and the column widths) down while the table is wider than
+// the printable width of the orientation, but not below the minimum; returns
+// the column widths and the scale of the style.
+func (pr *pdfRenderer) shrinkToFit(part partDesc, colwidths []float64, orientation string) ([]float64, float64) {
+	width := pr.printableWidth(orientation)
+	body, scale := pr.style.BodyFontSize, 1.0
+	for pr.minFontSize > 0 && sumFloats(colwidths) > width && body-fontStep >= pr.minFontSize {
+		body -= fontStep
+		scale = body / pr.style.BodyFontSize
+		colwidths = pr.columnWidths(part, pr.style.scaled(scale))
+	}
+	if scale != 1 && pr.trace != nil {
+		pr.trace.Printf("part %d %q: fonts shrunk to %.1f pt to fit the %.1f mm width", len(pr.layout.Parts)+1, part.head, body, width)
+	}
+	return colwidths, scale
+}
+
+// printableWidth returns the width between the margins of the pages of
+// the orientation.
+func (pr *pdfRenderer) printableWidth(orientation string) float64 {
+	if orientation == "L" {
+		return pr.defPageSize.Ht - 2*pr.style.Margin
+	}
+	return pr.defPageSize.Wd - 2*pr.style.Margin
+}
+
+// fitWidths returns the column widths with the wrapping columns sharing the
+// width left by the others, in proportion to their widths; but not
+// narrower than their header or 10 characters.
+func fitWidths(part partDesc, colwidths []float64, modes []truncMode, width float64, style tableStyle) []float64 {
+	if sumFloats(colwidths) <= width {
+		return colwidths
+	}
+	wraps := func(i int) bool { return i < len(modes) && modes[i] == truncWrap }
+	fitted := append([]float64(nil), colwidths...)
+	// the columns narrowed to their minimum are left out of the next round
+	clamped := make([]bool, len(colwidths))
+	for {
+		room, share := width, 0.0
+		for i, w := range colwidths {
+			if wraps(i) && !clamped[i] {
+				share += w
+			} else {
+				room -= fitted[i]
+			}
+		}
+		if share == 0 {
+			return fitted
+		}
+		again := false
+		for i, w := range colwidths {
+			if !wraps(i) || clamped[i] {
+				continue
+			}
+			fitted[i] = w * room / share
+			if min := minFloat(w, maxFloat(float64(cellWidth(part.head[i]))*style.HeaderCharWidth, 10*style.CharWidth)); fitted[i] < min {
+				fitted[i], clamped[i], again = min, true, true
+			}
+		}
+		if !again {
+			return fitted
+		}
+	}
+}
+
+func sumFloats(fs []float64) float64 {
+	var sum float64
+	for _, f := range fs {
+		sum += f
+	}
+	return sum
+}
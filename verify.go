@@ -0,0 +1,115 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"strconv"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pkg/errors"
+)
+
+var (
+	rStartXref = regexp.MustCompile(`startxref\s+(\d+)\s+%%EOF\s*$`)
+	rXrefHead  = regexp.MustCompile(`^xref\s+(\d+)\s+(\d+)\s+`)
+	rObjHead   = regexp.MustCompile(`^(\d+)\s+(\d+)\s+obj\b`)
+)
+
+// checkPDFStructure does a structural check of the PDF in b:
+// the header, the trailer, the cross-reference table and that each
+// referenced object is where the table says.
+// It returns the number of objects.
+func checkPDFStructure(b []byte) (int, error) {
+	if !bytes.HasPrefix(b, []byte("%PDF-1.")) {
+		return 0, errors.New("no %PDF-1.x header")
+	}
+	m := rStartXref.FindSubmatch(b)
+	if m == nil {
+		return 0, errors.New("no startxref/%%EOF trailer")
+	}
+	off, err := strconv.Atoi(string(m[1]))
+	if err != nil || off >= len(b) {
+		return 0, errors.Errorf("bad startxref offset %q", m[1])
+	}
+	xref := b[off:]
+	m = rXrefHead.FindSubmatch(xref)
+	if m == nil {
+		return 0, errors.Errorf("no xref table at %d", off)
+	}
+	first, _ := strconv.Atoi(string(m[1]))
+	count, _ := strconv.Atoi(string(m[2]))
+	xref = xref[len(m[0]):]
+	// each entry is exactly 20 bytes: "nnnnnnnnnn ggggg n\r\n"
+	if len(xref) < 20*count {
+		return 0, errors.Errorf("xref table is truncated (%d entries)", count)
+	}
+	var objects int
+	for i := 0; i < count; i++ {
+		entry := xref[20*i : 20*i+18]
+		if entry[17] != 'n' {
+			continue
+		}
+		objOff, err := strconv.Atoi(string(entry[:10]))
+		if err != nil || objOff >= len(b) {
+			return objects, errors.Errorf("bad offset in xref entry %q", entry)
+		}
+		om := rObjHead.FindSubmatch(b[objOff:])
+		if om == nil || string(om[1]) != strconv.Itoa(first+i) {
+			return objects, errors.Errorf("object %d is not at offset %d", first+i, objOff)
+		}
+		objects++
+	}
+	if !bytes.Contains(b[off:], []byte("trailer")) {
+		return objects, errors.New("no trailer dictionary")
+	}
+	return objects, nil
+}
+
+// pageCounter is implemented by the renderers knowing the number of the
+// pages written.
+type pageCounter interface {
+	Pages() int
+}
+
+// Pages returns the number of the pages written.
+func (pr *pdfRenderer) Pages() int { return pr.flushed + pr.pdf.PageCount() }
+
+// verifyPDF reads the PDF back from rs, validating its structure,
+// checking its page count (if pages is not 0), and that its first page has
+// text.
+func verifyPDF(rs io.ReadSeeker, pages int) error {
+	conf := model.NewDefaultConfiguration()
+	conf.ValidationMode = model.ValidationRelaxed
+	ctx, err := api.ReadContext(rs, conf)
+	if err != nil {
+		return errors.Wrap(err, "reading")
+	}
+	if err = api.ValidateContext(ctx); err != nil {
+		return errors.Wrap(err, "validating")
+	}
+	if ctx.PageCount == 0 {
+		return errors.New("no pages")
+	}
+	if pages != 0 && ctx.PageCount != pages {
+		return errors.Errorf("%d pages instead of %d", ctx.PageCount, pages)
+	}
+	r, err := pdfcpu.ExtractPageContent(ctx, 1)
+	if err != nil {
+		return errors.Wrap(err, "extracting the content of the first page")
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return errors.Wrap(err, "extracting the content of the first page")
+	}
+	if !bytes.Contains(b, []byte("Tj")) && !bytes.Contains(b, []byte("TJ")) {
+		return errors.New("no text on the first page")
+	}
+	return nil
+}
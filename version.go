@@ -0,0 +1,51 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// pdfVersions are the PDF versions that can be written.
+var pdfVersions = []string{"1.3", "1.4", "1.5", "1.6", "1.7", "2.0"}
+
+// checkPDFVersion returns an error if version is not a known PDF version.
+func checkPDFVersion(version string) error {
+	for _, v := range pdfVersions {
+		if v == version {
+			return nil
+		}
+	}
+	return errors.Errorf("unknown PDF version %q (known: %q)", version, pdfVersions)
+}
+
+// setPDFVersion rewrites the version in the header of the PDF in out.
+//
+// The version can be raised freely, but it cannot be lowered below what
+// the features used in the document (transparency, layers, object streams,
+// encryption) need.
+func setPDFVersion(out io.ReadWriteSeeker, version string) error {
+	if _, err := out.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(out, header); err != nil {
+		return err
+	}
+	if !bytes.HasPrefix(header, []byte("%PDF-")) {
+		return errors.Errorf("no PDF header: %q", header)
+	}
+	if have := string(header[5:]); have > version {
+		return errors.Errorf("the document needs PDF %s, cannot write it as %s", have, version)
+	}
+	if _, err := out.Seek(5, io.SeekStart); err != nil {
+		return err
+	}
+	_, err := io.WriteString(out, version)
+	return err
+}
@@ -0,0 +1,113 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"math"
+	"strings"
+)
+
+// verticalColumns is the set of the columns printed rotated by 90°.
+type verticalColumns map[string]bool
+
+func parseVerticalColumns(spec string) verticalColumns {
+	vc := make(verticalColumns)
+	for _, name := range strings.Split(spec, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			vc[name] = true
+		}
+	}
+	return vc
+}
+
+// apply marks the matching columns of the part vertical.
+func (vc verticalColumns) apply(part *partDesc) {
+	for i, h := range part.head {
+		ok := vc[strings.TrimSpace(h)]
+		if !ok && i < len(part.fields) && part.fields[i] != nil {
+			ok = vc[part.fields[i].Name]
+		}
+		if !ok {
+			continue
+		}
+		if part.vertical == nil {
+			part.vertical = make([]bool, len(part.head))
+		}
+		part.vertical[i] = true
+	}
+}
+
+// isVertical reports whether the i-th column is vertical.
+func (part partDesc) isVertical(i int) bool {
+	return i < len(part.vertical) && part.vertical[i]
+}
+
+// verticalWidth is the width of a vertical column: one line of the header
+// font, with the cell margins.
+func (st tableStyle) verticalWidth() float64 {
+	return st.HeaderFontSize*25.4/72 + 2
+}
+
+// headerHeight returns the height of the header: the vertical and the
+// rotated headers need their length.
+func (t *pdfTable) headerHeight() float64 {
+	hh := t.style.HeaderHeight
+	cm := t.pdf.GetCellMargin()
+	for i, v := range t.part.head {
+		switch {
+		case t.part.isVertical(i):
+			hh = maxFloat(hh, t.textWidth(v)+2*cm)
+		case t.style.HeaderAngle != 0:
+			a := t.style.HeaderAngle * math.Pi / 180
+			lh := t.pdf.PointConvert(t.style.HeaderFontSize)
+			hh = maxFloat(hh, (t.textWidth(v)+2*cm)*math.Sin(a)+lh*math.Cos(a)+cm)
+		}
+	}
+	return hh
+}
+
+// rotatedHeads draws the heads of the columns (but the vertical ones) of
+// the header band starting at x, from y down hh, rotated by the
+// HeaderAngle, reading upwards from the bottom of their columns; the heads
+// rotated by less than 90° reach over the next columns.
+func (t *pdfTable) rotatedHeads(x, y, hh float64) {
+	pdf := t.pdf
+	cm := pdf.GetCellMargin()
+	lh := pdf.PointConvert(t.style.HeaderFontSize)
+	for _, i := range t.columns(len(t.part.head)) {
+		w := t.colwidths[i]
+		if t.part.isVertical(i) {
+			x += w
+			continue
+		}
+		v := t.encode(t.part.head[i])
+		// the middle of the text starts at the bottom middle of the cell
+		px, py := x+w/2, y+hh-cm
+		pdf.TransformBegin()
+		pdf.TransformRotate(t.style.HeaderAngle, px, py)
+		pdf.SetXY(px, py-lh/2)
+		t.fallback.cellFormat(pdf, "B", t.style.HeaderFontSize, t.textWidth(t.part.head[i])+2*cm, lh, v, "", "L", false)
+		pdf.TransformEnd()
+		x += w
+	}
+	pdf.SetXY(x, y)
+}
+
+// verticalCell draws the next cell with the text rotated by 90°,
+// reading upwards.
+func (t *pdfTable) verticalCell(fontStyle string, size, w, h float64, v, border, align string, fill bool) {
+	pdf := t.pdf
+	pdf.CellFormat(w, h, "", border, 0, "", fill, 0, "")
+	x, y := pdf.GetXY()
+	x -= w
+	// rotate the cell of h width and w height standing on the bottom right
+	// corner to the left, to cover this cell
+	pdf.TransformBegin()
+	pdf.TransformRotate(90, x+w, y+h)
+	pdf.SetXY(x+w, y+h-w)
+	t.fallback.cellFormat(pdf, fontStyle, size, h, w, v, "", align, false)
+	pdf.TransformEnd()
+	pdf.SetXY(x+w, y)
+}
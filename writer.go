@@ -0,0 +1,174 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// widthRows is the number of the first rows TableWriter measures the column
+// widths on.
+const widthRows = 100
+
+// TableWriter renders the rows written to it as one table, without a csv
+// input: for rows produced on the fly (database cursors, message queues).
+//
+// The column widths are measured on the first rows (widthRows), the later
+// rows are rendered as they are written. As there is no pre-pass, the
+// options needing all the rows (Sort, Sample, Head, Tail, Outliers) and
+// those rewriting or checking the finished document (Post, PDFVersion,
+// Preview, AlsoCSV, Grayscale) are refused by NewWriter with a
+// *CapabilityError; OrderColumns, CollapseConstant, the heatmap ranges,
+// Quality and Summary are ignored.
+//
+// With FlushPages, the finished pages are written to w on the way, so the
+// memory use does not grow with the number of rows.
+type TableWriter struct {
+	opts       Options
+	rend       tableRenderer
+	part       partDesc
+	disclaimer string
+	// pending are the rows buffered for measuring the widths.
+	pending [][]string
+	started bool
+	rowNo   int
+
+	closeFontDir func() error
+	closeRend    func()
+}
+
+// writerConflicts are the options TableWriter cannot print.
+var writerConflicts = []conflict{
+	{"sort, sample, head, tail, outliers", "TableWriter has no pre-pass over all the rows",
+		func(o Options, _ bool) bool {
+			return o.Sort != "" || o.Sample > 0 || o.Head > 0 || o.Tail > 0 || o.Outliers != ""
+		}},
+	{"post, pdf-version, preview, also-csv, grayscale", "TableWriter does not rewrite nor check the finished document",
+		func(o Options, _ bool) bool {
+			return o.Post != "" || o.PDFVersion != "" || o.Preview != "" || o.AlsoCSV != "" || o.Grayscale
+		}},
+}
+
+// checkWriter returns a *CapabilityError if a feature requested is not
+// printed by the format, or not by TableWriter.
+func (opts Options) checkWriter() error {
+	e, _ := opts.checkCapabilities().(*CapabilityError)
+	if e == nil {
+		e = &CapabilityError{Format: opts.Format}
+	}
+	for _, c := range writerConflicts {
+		if c.found(opts, false) {
+			e.Conflicts = append(e.Conflicts, c.features+": "+c.reason)
+		}
+	}
+	if e.Unsupported == nil && e.Conflicts == nil {
+		return nil
+	}
+	return e
+}
+
+// NewWriter returns a TableWriter writing the table with the header to w.
+// The document is complete only after Close.
+func NewWriter(w io.Writer, header []string, opts Options) (*TableWriter, error) {
+	opts.setDefaults()
+	// no pre-pass results for these pages
+	opts.Summary, opts.Quality = false, false
+	if err := opts.checkWriter(); err != nil {
+		return nil, err
+	}
+	fontDir, pdfTranslator, closeFontDir, err := opts.prepareFonts()
+	if err != nil {
+		return nil, err
+	}
+	tw := &TableWriter{opts: opts, closeFontDir: closeFontDir}
+	parts := []partDesc{{head: append([]string(nil), header...), widths: make([]int, len(header))}}
+	if err = opts.applyColumns(parts); err != nil {
+		closeFontDir()
+		return nil, err
+	}
+	tw.part = parts[0]
+	if tw.disclaimer, err = opts.loadDisclaimer(); err != nil {
+		closeFontDir()
+		return nil, err
+	}
+	if tw.rend, tw.closeRend, err = opts.newRenderer(w, fontDir, pdfTranslator, nil, tw.disclaimer); err != nil {
+		closeFontDir()
+		return nil, err
+	}
+	return tw, nil
+}
+
+// WriteRow writes the row; it must have as many columns as the header.
+func (tw *TableWriter) WriteRow(record []string) error {
+	if len(record) != len(tw.part.head) {
+		return errors.Errorf("row has %d columns, the header has %d", len(record), len(tw.part.head))
+	}
+	if tw.started {
+		return tw.row(record)
+	}
+	tw.pending = append(tw.pending, append([]string(nil), record...))
+	for i, v := range record {
+		if tw.opts.Format == "md" {
+			v = markdownEscape(v)
+		}
+		if w := cellWidth(v); w > tw.part.widths[i] {
+			tw.part.widths[i] = w
+		}
+	}
+	if len(tw.pending) < widthRows {
+		return nil
+	}
+	return tw.start()
+}
+
+// start starts the table with the measured widths, and renders the pending rows.
+func (tw *TableWriter) start() error {
+	tw.started = true
+	part := tw.part
+	if tw.opts.RowNumbers {
+		// the row count is unknown: room for 5 digits
+		part.lastLine = 99999
+		part = part.withRowNumbers()
+	}
+	if err := tw.rend.StartPart(part); err != nil {
+		return errors.Wrap(err, "starting part")
+	}
+	for _, record := range tw.pending {
+		if err := tw.row(record); err != nil {
+			return err
+		}
+	}
+	tw.pending = nil
+	return nil
+}
+
+func (tw *TableWriter) row(record []string) error {
+	if tw.opts.RowNumbers {
+		tw.rowNo++
+		record = append([]string{strconv.Itoa(tw.rowNo)}, record...)
+	}
+	return tw.rend.Row(record)
+}
+
+// Close renders the pending rows and finishes the document.
+func (tw *TableWriter) Close() error {
+	defer tw.closeFontDir()
+	defer tw.closeRend()
+	if !tw.started {
+		if err := tw.start(); err != nil {
+			return err
+		}
+	}
+	if tw.disclaimer != "" && tw.opts.Format != "pdf" {
+		if err := renderText(tw.rend, strings.Split(tw.disclaimer, "\n")); err != nil {
+			return errors.Wrap(err, "rendering disclaimer")
+		}
+	}
+	return errors.Wrap(tw.rend.Close(), "writing output")
+}
@@ -0,0 +1,243 @@
+// Copyright 2014 The Tamás Gulácsi. All rights reserved.
+// Use of this source code is governed by an Apache 2.0
+// license that can be found in the LICENSE file.
+
+package csv2pdf
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// xlsxRenderer writes a minimal Office Open XML workbook, one sheet per part,
+// with a frozen, bold header row and an auto-filter on it; the free text
+// blocks (and the disclaimer) go to their own sheets, a paragraph per row.
+//
+// The sheets are streamed into the zip, so memory use does not depend on the
+// number of rows.
+type xlsxRenderer struct {
+	zw    *zip.Writer
+	sheet *bufio.Writer
+	names []string
+	// filters are the auto-filter ranges of the sheets, empty for the text
+	// sheets
+	filters []string
+	cols    int
+	rows    int
+	aligns  []string
+}
+
+func newXLSXRenderer(w io.Writer) *xlsxRenderer {
+	return &xlsxRenderer{zw: zip.NewWriter(w)}
+}
+
+func (xr *xlsxRenderer) StartPart(part partDesc) error {
+	if err := xr.finishSheet(); err != nil {
+		return err
+	}
+	xr.names = append(xr.names, xr.sheetName(part.title))
+	w, err := xr.zw.Create(fmt.Sprintf("xl/worksheets/sheet%d.xml", len(xr.names)))
+	if err != nil {
+		return err
+	}
+	xr.sheet = bufio.NewWriter(w)
+	xr.cols, xr.rows, xr.aligns = len(part.head), 0, part.aligns
+	xr.sheet.WriteString(xml.Header + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+		`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>` +
+		`<cols>`)
+	for i, h := range part.head {
+		w := maxInt(part.widths[i], len([]rune(h))) + 2
+		if w > 80 {
+			w = 80
+		}
+		fmt.Fprintf(xr.sheet, `<col min="%d" max="%d" width="%d" customWidth="1"/>`, i+1, i+1, w)
+	}
+	xr.sheet.WriteString(`</cols><sheetData>`)
+	return xr.writeRow(part.head, 1)
+}
+
+func (xr *xlsxRenderer) Row(record []string) error {
+	return xr.writeRow(record, 0)
+}
+
+func (xr *xlsxRenderer) writeRow(record []string, style int) error {
+	xr.rows++
+	fmt.Fprintf(xr.sheet, `<row r="%d">`, xr.rows)
+	for i, v := range record {
+		ref := xlsxColName(i) + strconv.Itoa(xr.rows)
+		if style == 0 && xlsxIsNumber(v, i < len(xr.aligns) && xr.aligns[i] == "R") {
+			fmt.Fprintf(xr.sheet, `<c r="%s"><v>%s</v></c>`, ref, v)
+			continue
+		}
+		fmt.Fprintf(xr.sheet, `<c r="%s" s="%d" t="inlineStr"><is><t xml:space="preserve">`, ref, style)
+		xml.EscapeText(xr.sheet, []byte(v))
+		xr.sheet.WriteString(`</t></is></c>`)
+	}
+	_, err := xr.sheet.WriteString(`</row>`)
+	return err
+}
+
+// Paragraphs writes the text to a new sheet, a paragraph per row.
+func (xr *xlsxRenderer) Paragraphs(text []string) error {
+	if err := xr.finishSheet(); err != nil {
+		return err
+	}
+	xr.names = append(xr.names, xr.sheetName("Text"))
+	xr.filters = append(xr.filters, "")
+	w, err := xr.zw.Create(fmt.Sprintf("xl/worksheets/sheet%d.xml", len(xr.names)))
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	bw.WriteString(xml.Header + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+		`<cols><col min="1" max="1" width="100" customWidth="1"/></cols><sheetData>`)
+	for i, t := range text {
+		fmt.Fprintf(bw, `<row r="%d"><c r="A%d" t="inlineStr"><is><t xml:space="preserve">`, i+1, i+1)
+		xml.EscapeText(bw, []byte(t))
+		bw.WriteString(`</t></is></c></row>`)
+	}
+	bw.WriteString(`</sheetData></worksheet>`)
+	return bw.Flush()
+}
+
+func (xr *xlsxRenderer) finishSheet() error {
+	if xr.sheet == nil {
+		return nil
+	}
+	lastCol := xlsxColName(maxInt(xr.cols, 1) - 1)
+	xr.filters = append(xr.filters, fmt.Sprintf("$A$1:$%s$%d", lastCol, xr.rows))
+	fmt.Fprintf(xr.sheet, `</sheetData><autoFilter ref="A1:%s%d"/></worksheet>`, lastCol, xr.rows)
+	err := xr.sheet.Flush()
+	xr.sheet = nil
+	return err
+}
+
+func (xr *xlsxRenderer) Close() error {
+	if err := xr.finishSheet(); err != nil {
+		return err
+	}
+	var ct, wb, rels strings.Builder
+	ct.WriteString(xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`)
+	wb.WriteString(xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	rels.WriteString(xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	for i, name := range xr.names {
+		name = xlsxEscape(name)
+		fmt.Fprintf(&ct, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i+1)
+		fmt.Fprintf(&wb, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, name, i+1, i+1)
+		fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, i+1, i+1)
+	}
+	ct.WriteString(`</Types>`)
+	wb.WriteString(`</sheets><definedNames>`)
+	for i, name := range xr.names {
+		if xr.filters[i] == "" {
+			continue
+		}
+		fmt.Fprintf(&wb, `<definedName name="_xlnm._FilterDatabase" localSheetId="%d" hidden="1">'%s'!%s</definedName>`,
+			i, xlsxEscape(name), xr.filters[i])
+	}
+	wb.WriteString(`</definedNames></workbook>`)
+	fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/></Relationships>`, len(xr.names)+1)
+
+	for _, f := range []struct{ name, content string }{
+		{"[Content_Types].xml", ct.String()},
+		{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`},
+		{"xl/workbook.xml", wb.String()},
+		{"xl/_rels/workbook.xml.rels", rels.String()},
+		{"xl/styles.xml", xlsxStyles},
+	} {
+		w, err := xr.zw.Create(f.name)
+		if err != nil {
+			return err
+		}
+		if _, err = io.WriteString(w, f.content); err != nil {
+			return err
+		}
+	}
+	return xr.zw.Close()
+}
+
+// sheetName returns a valid, unique sheet name from the title;
+// "Part N" if the title is empty.
+func (xr *xlsxRenderer) sheetName(title string) string {
+	name := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\'`, r) {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(title))
+	if name == "" {
+		name = fmt.Sprintf("Part %d", len(xr.names)+1)
+	}
+	for i := 2; ; i++ {
+		if r := []rune(name); len(r) > 31 {
+			name = string(r[:31])
+		}
+		var dup bool
+		for _, n := range xr.names {
+			if dup = strings.EqualFold(n, name); dup {
+				break
+			}
+		}
+		if !dup {
+			return name
+		}
+		suffix := fmt.Sprintf(" (%d)", i)
+		base := []rune(strings.TrimSuffix(name, fmt.Sprintf(" (%d)", i-1)))
+		if len(base) > 31-len(suffix) {
+			base = base[:31-len(suffix)]
+		}
+		name = string(base) + suffix
+	}
+}
+
+// xlsxStyles has two cell formats: 0 is the default, 1 is the bold, filled header.
+const xlsxStyles = xml.Header + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<fonts count="2"><font><sz val="10"/><name val="Arial"/></font><font><b/><sz val="10"/><name val="Arial"/></font></fonts>` +
+	`<fills count="3"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill>` +
+	`<fill><patternFill patternType="solid"><fgColor rgb="FFE0EBFF"/></patternFill></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="0" fontId="1" fillId="2" borderId="0" xfId="0" applyFont="1" applyFill="1"/></cellXfs>` +
+	`</styleSheet>`
+
+func xlsxEscape(s string) string {
+	var buf strings.Builder
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+// xlsxColName returns the column name (A, B, ..., Z, AA, ...) of the 0-based index.
+func xlsxColName(i int) string {
+	var name []byte
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = append([]byte{byte('A' + (i-1)%26)}, name...)
+	}
+	return string(name)
+}
+
+// xlsxIsNumber reports whether v should be written as a number:
+// it must parse, and must not have a leading zero (as IDs often have),
+// unless the column is known to be numeric.
+func xlsxIsNumber(v string, numeric bool) bool {
+	if v == "" {
+		return false
+	}
+	if _, err := strconv.ParseFloat(v, 64); err != nil || strings.ContainsAny(v, "nNxX_") { // Inf, NaN, hex
+		return false
+	}
+	if numeric {
+		return true
+	}
+	return !(len(v) > 1 && v[0] == '0' && v[1] != '.') && !strings.ContainsAny(v, "eE+")
+}